@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE codes ADD COLUMN type VARCHAR NOT NULL DEFAULT 'url';
+ALTER TABLE codes ADD COLUMN payload VARCHAR NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE codes DROP COLUMN payload;
+ALTER TABLE codes DROP COLUMN type;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE codes ADD COLUMN slug VARCHAR NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_codes_slug ON codes(slug COLLATE NOCASE);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS idx_codes_slug;
+ALTER TABLE codes DROP COLUMN slug;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE users ADD COLUMN domain VARCHAR NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_domain ON users(domain COLLATE NOCASE);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS idx_users_domain;
+ALTER TABLE users DROP COLUMN domain;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE codes ADD COLUMN name VARCHAR NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name VARCHAR NOT NULL,
+    UNIQUE(user_id, name),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE);
+
+CREATE TABLE IF NOT EXISTS code_tags (
+    code_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY(code_id, tag_id),
+    FOREIGN KEY(code_id) REFERENCES codes(id) ON DELETE CASCADE,
+    FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE);
+
+CREATE INDEX IF NOT EXISTS idx_code_tags_tag_id ON code_tags(tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE code_tags;
+DROP TABLE tags;
+ALTER TABLE codes DROP COLUMN name;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE codes ADD COLUMN description VARCHAR NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_codes_user_id ON codes(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS idx_codes_user_id;
+ALTER TABLE codes DROP COLUMN description;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS campaigns (
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name VARCHAR NOT NULL,
+    description VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_user_id ON campaigns(user_id);
+
+CREATE TRIGGER IF NOT EXISTS on_update_campaign_update_time AFTER UPDATE ON campaigns FOR EACH ROW BEGIN
+    UPDATE campaigns SET updated_at = CURRENT_TIMESTAMP WHERE id = old.id;
+END;
+
+ALTER TABLE codes ADD COLUMN campaign_id INTEGER NULL;
+ALTER TABLE codes ADD COLUMN scan_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_codes_campaign_id ON codes(campaign_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS idx_codes_campaign_id;
+ALTER TABLE codes DROP COLUMN scan_count;
+ALTER TABLE codes DROP COLUMN campaign_id;
+DROP TABLE campaigns;
+-- +goose StatementEnd
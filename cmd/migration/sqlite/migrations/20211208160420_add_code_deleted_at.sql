@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- codes is rebuilt with AUTOINCREMENT, so ids (and hashes based on them) of purged codes are never reused
+CREATE TABLE codes_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    link VARCHAR NOT NULL,
+    hash VARCHAR NULL UNIQUE ,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    type VARCHAR NOT NULL DEFAULT 'url',
+    payload VARCHAR NOT NULL DEFAULT '',
+    slug VARCHAR NULL,
+    name VARCHAR NOT NULL DEFAULT '',
+    description VARCHAR NOT NULL DEFAULT '',
+    campaign_id INTEGER NULL,
+    scan_count INTEGER NOT NULL DEFAULT 0,
+    deleted_at TIMESTAMP NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE);
+
+INSERT INTO codes_new(id, user_id, link, hash, created_at, updated_at, type, payload, slug, name, description, campaign_id, scan_count)
+    SELECT id, user_id, link, hash, created_at, updated_at, type, payload, slug, name, description, campaign_id, scan_count FROM codes;
+
+DROP TABLE codes;
+ALTER TABLE codes_new RENAME TO codes;
+
+CREATE INDEX IF NOT EXISTS idx_codes_hash ON codes(hash);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_codes_slug ON codes(slug COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_codes_user_id ON codes(user_id);
+CREATE INDEX IF NOT EXISTS idx_codes_campaign_id ON codes(campaign_id);
+CREATE INDEX IF NOT EXISTS idx_codes_deleted_at ON codes(deleted_at);
+
+CREATE TRIGGER IF NOT EXISTS on_update_code_update_time AFTER UPDATE ON codes FOR EACH ROW BEGIN
+    UPDATE codes SET updated_at = CURRENT_TIMESTAMP WHERE id = old.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS idx_codes_deleted_at;
+ALTER TABLE codes DROP COLUMN deleted_at;
+-- +goose StatementEnd
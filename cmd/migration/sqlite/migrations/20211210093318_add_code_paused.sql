@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE codes ADD COLUMN paused BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE codes ADD COLUMN paused_url VARCHAR NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE codes DROP COLUMN paused_url;
+ALTER TABLE codes DROP COLUMN paused;
+-- +goose StatementEnd
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS logos (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR NOT NULL,
+    data BLOB NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE);
+
+CREATE INDEX IF NOT EXISTS idx_logos_user_id ON logos(user_id);
+
+CREATE TABLE IF NOT EXISTS styles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR NOT NULL,
+    foreground VARCHAR NOT NULL DEFAULT '#000000',
+    background VARCHAR NOT NULL DEFAULT '#ffffff',
+    shape VARCHAR NOT NULL DEFAULT 'square',
+    quiet_zone INTEGER NOT NULL DEFAULT 4,
+    error_correction VARCHAR NOT NULL DEFAULT 'Q',
+    logo_id INTEGER NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(logo_id) REFERENCES logos(id));
+
+CREATE INDEX IF NOT EXISTS idx_styles_user_id ON styles(user_id);
+
+CREATE TRIGGER IF NOT EXISTS on_update_style_update_time AFTER UPDATE ON styles FOR EACH ROW BEGIN
+    UPDATE styles SET updated_at = CURRENT_TIMESTAMP WHERE id = old.id;
+END;
+
+ALTER TABLE codes ADD COLUMN style_id INTEGER NULL;
+ALTER TABLE users ADD COLUMN default_style_id INTEGER NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE users DROP COLUMN default_style_id;
+ALTER TABLE codes DROP COLUMN style_id;
+DROP TABLE styles;
+DROP TABLE logos;
+-- +goose StatementEnd
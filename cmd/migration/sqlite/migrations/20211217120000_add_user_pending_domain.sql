@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE users ADD COLUMN pending_domain VARCHAR NULL;
+ALTER TABLE users ADD COLUMN domain_token VARCHAR NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE users DROP COLUMN domain_token;
+ALTER TABLE users DROP COLUMN pending_domain;
+-- +goose StatementEnd
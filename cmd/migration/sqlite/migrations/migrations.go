@@ -0,0 +1,35 @@
+// Package migrations contains goose migrations of SQLite database.
+// It is shared by migrator and server, which checks that DB is migrated.
+package migrations
+
+import (
+	"embed"
+	"github.com/pkg/errors"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// FS contains migrations in its root
+//
+//go:embed *.sql
+var FS embed.FS
+
+// Latest returns version of the newest migration
+func Latest() (int64, error) {
+	files, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest int64
+	for _, name := range files {
+		version, err := strconv.ParseInt(strings.SplitN(name, "_", 2)[0], 10, 64)
+		if err != nil {
+			return 0, errors.Errorf("migration %s has no version", name)
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+	return latest, nil
+}
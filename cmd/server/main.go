@@ -1,153 +1,332 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"github.com/hotafrika/griz-backend/cmd/migration/sqlite/migrations"
 	"github.com/hotafrika/griz-backend/internal/server/app"
 	"github.com/hotafrika/griz-backend/internal/server/app/authtoken"
+	"github.com/hotafrika/griz-backend/internal/server/app/health"
+	"github.com/hotafrika/griz-backend/internal/server/app/lockout"
 	"github.com/hotafrika/griz-backend/internal/server/app/password"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
 	"github.com/hotafrika/griz-backend/internal/server/app/token"
+	"github.com/hotafrika/griz-backend/internal/server/config"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/infrastructure/api"
 	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache/inmemory"
 	"github.com/hotafrika/griz-backend/internal/server/infrastructure/database/sqlite"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/instagram"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/instagram/photo"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/metrics"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/ratelimit"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/safehttp"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/tracing"
 	"github.com/rs/zerolog"
 	zlog "github.com/rs/zerolog/log"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// Build info is set by -ldflags "-X main.version=v1.2.3 -X main.commit=abc1234 -X main.buildDate=2021-12-20"
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
 func main() {
-	bindAddr := ":8081"
-	reqTimeout := 10 * time.Second
-	parseTimeout := 20 * time.Second
-	logLevel := zerolog.TraceLevel
-	authTokenTTL := 1800 * time.Second
-	hashTTL := 1800 * time.Second
-	socialLinkTTL := 1800 * time.Second
-	encryptionPassString := "abc"
-	encryptionAuthTokenString := "abc"
-	encryptionHashString := "1234567812345678" // 16symbols
-
-	// ENV parsing
-	ba, ok := os.LookupEnv("BACKEND_ADDRESS")
-	if ok {
-		bindAddr = ba
-	}
-	rt, ok := os.LookupEnv("REQUEST_TIMEOUT")
-	if ok {
-		rti, err := strconv.Atoi(rt)
-		if err == nil {
-			reqTimeout = time.Duration(rti) * time.Second
-		}
-	}
-	prt, ok := os.LookupEnv("PARSE_REQUEST_TIMEOUT")
-	if ok {
-		prti, err := strconv.Atoi(prt)
-		if err == nil {
-			parseTimeout = time.Duration(prti) * time.Second
-		}
-	}
-	ll, ok := os.LookupEnv("LOG_LEVEL")
-	if ok {
-		lli, err := strconv.Atoi(ll)
-		if err == nil {
-			if lli < -1 || lli > 6 {
-				log.Fatal("LOG_LEVEL must be between -1 and 6")
-			}
-			logLevel = zerolog.Level(lli)
-		}
-	}
-	catt, ok := os.LookupEnv("CACHE_AUTH_TOKEN_TTL")
-	if ok {
-		catti, err := strconv.Atoi(catt)
-		if err == nil {
-			authTokenTTL = time.Duration(catti) * time.Second
-		}
-	}
-	cht, ok := os.LookupEnv("CACHE_HASH_TTL")
-	if ok {
-		chti, err := strconv.Atoi(cht)
-		if err == nil {
-			hashTTL = time.Duration(chti) * time.Second
-		}
-	}
-	cslt, ok := os.LookupEnv("CACHE_SOCIAL_LINK_TTL")
-	if ok {
-		cslti, err := strconv.Atoi(cslt)
-		if err == nil {
-			socialLinkTTL = time.Duration(cslti) * time.Second
-		}
-	}
-	pek, ok := os.LookupEnv("PASSWORD_ENCRYPTION_KEY")
-	if ok {
-		encryptionPassString = pek
-	}
-	atek, ok := os.LookupEnv("AUTH_TOKEN_ENCRYPTION_KEY")
-	if ok {
-		encryptionAuthTokenString = atek
-	}
-	hek, ok := os.LookupEnv("HASH_ENCRYPTION_KEY")
-	if ok {
-		if len(hek) != 16 {
-			log.Fatal("len of HASH_ENCRYPTION_KEY is not 16 bytes")
+	cfg := config.MustLoad()
+
+	maintenancePage := app.DefaultMaintenancePage
+	if cfg.MaintenancePage != "" {
+		b, err := os.ReadFile(cfg.MaintenancePage)
+		if err != nil {
+			log.Fatalf("unable to read maintenance page: %v", err)
 		}
-		encryptionAuthTokenString = hek
+		maintenancePage = string(b)
 	}
 
 	// Work with SQL
-	dbDriver := "sqlite3"
-	dbString := "db.sqlite3"
-	dbd, ok := os.LookupEnv("DB_DRIVER")
-	if ok {
-		dbDriver = dbd
-	}
-	dbs, ok := os.LookupEnv("DB_CONNECTION_STRING")
-	if ok {
-		dbString = dbs
-	}
-	db, err := sql.Open(dbDriver, dbString)
+	db, err := sql.Open(cfg.DBDriver, cfg.DBConnectionString)
 	if err != nil {
 		panic(err)
 	}
 
-	logger := zlog.Level(logLevel)
+	logger := zlog.Level(zerolog.Level(cfg.LogLevel))
+	build := health.NewBuildInfo(version, commit, buildDate)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Endpoint:       cfg.TracingEndpoint,
+		SampleRatio:    cfg.TracingSampleRatio,
+		ServiceName:    "griz-server",
+		ServiceVersion: build.Version,
+	})
+	if err != nil {
+		log.Fatalf("unable to initialize tracing: %v", err)
+	}
 	cache := inmemory.NewCache()
+	imageCache := inmemory.NewImageCache(cfg.ImageCacheMB << 20)
 
 	// Inmemory repos
 	//codeRepo := inmemory2.NewCodeRepository()
 	//userRepo := inmemory2.NewUserRepository()
+	//campaignRepo := inmemory2.NewCampaignRepository(codeRepo)
+	//styleRepo := inmemory2.NewStyleRepository(codeRepo, userRepo)
 
 	// SQL repos
-	codeRepo := sqlite.NewCodeRepository(db)
-	userRepo := sqlite.NewUserRepository(db)
+	var codeRepo domain.CodeRepository = sqlite.NewCodeRepository(db)
+	var userRepo domain.UserRepository = sqlite.NewUserRepository(db)
+	var campaignRepo domain.CampaignRepository = sqlite.NewCampaignRepository(db)
+	var styleRepo domain.StyleRepository = sqlite.NewStyleRepository(db)
+
+	// QR codes of instagram posts
+	var photoSource domain.PhotoSourcer = photo.NewPhotoSource()
+	var qrDecoder domain.QRDecoder = qrdecoder.Makiuchi{}
+	imageClient := safehttp.NewClient(safehttp.Config{
+		AllowedHosts: cfg.InstagramImageHosts,
+		MaxRedirects: cfg.ImageMaxRedirects,
+		MaxBytes:     int64(cfg.ImageMaxMB) << 20,
+		DialTimeout:  5 * time.Second,
+	})
 
-	passEncryptor := password.NewEncryptorByString(encryptionPassString)
-	authTokenEncryptor := authtoken.NewJWTFromString(encryptionAuthTokenString, authTokenTTL)
-	hashEncryptor, err := token.NewAES(encryptionHashString)
+	proxies, err := ratelimit.ParseTrustedProxies(cfg.TrustedProxies)
 	if err != nil {
-		log.Fatal("unable to initialize hash encryptor")
+		log.Fatalf("unable to parse trusted proxies: %v", err)
 	}
 
+	// Metrics and spans are collected by decorators, readiness checks use undecorated cache
+	var serviceCache domain.Cacher = cache
+	var serviceImageCache domain.ImageCacher = imageCache
+	var middlewares []func(http.Handler) http.Handler
+	if cfg.TracingEndpoint != "" {
+		codeRepo = tracing.NewCodeRepository(codeRepo)
+		userRepo = tracing.NewUserRepository(userRepo)
+		campaignRepo = tracing.NewCampaignRepository(campaignRepo)
+		styleRepo = tracing.NewStyleRepository(styleRepo)
+		middlewares = append(middlewares, tracing.HTTPMiddleware(proxies.FromProxy))
+	}
+	var m *metrics.Metrics
+	if cfg.MetricsEnabled {
+		m = metrics.New()
+		serviceCache = m.NewCacher(serviceCache)
+		serviceImageCache = m.NewImageCacher(serviceImageCache)
+		codeRepo = m.NewCodeRepository(codeRepo)
+		userRepo = m.NewUserRepository(userRepo)
+		campaignRepo = m.NewCampaignRepository(campaignRepo)
+		styleRepo = m.NewStyleRepository(styleRepo)
+		photoSource = m.NewPhotoSourcer("instagram", photoSource)
+		qrDecoder = m.NewDecoder(qrDecoder)
+		imageClient.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+			return m.NewTransport("instagram", next)
+		})
+		middlewares = append(middlewares, m.HTTPMiddleware)
+	}
+	linker, err := token.NewLinker(cfg.LinkBaseURL, cfg.LinkAliasDomains...)
+	if err != nil {
+		log.Fatalf("unable to initialize linker: %v", err)
+	}
+	qrSource := instagram.NewCustomQRSource(photoSource, qrDecoder, imageClient, instagram.Options{
+		Workers:        cfg.QRWorkers,
+		RequestWorkers: cfg.QRRequestWorkers,
+		ImageTimeout:   cfg.QRImageTimeout.Duration(),
+		CheckLink:      app.CheckGrizLink(linker, userRepo),
+	}, &logger)
+
+	passEncryptor := password.NewEncryptorByString(cfg.PasswordKey)
+	authTokenEncryptor := authtoken.NewJWTFromString(cfg.AuthTokenKey, cfg.AuthTokenTTL.Duration())
+	hashEncryptor, err := newKeyring(cfg.HashKey, cfg.HashKeys, cfg.HashCurrentVersion)
+	if err != nil {
+		log.Fatalf("unable to initialize hash encryptor: %v", err)
+	}
+	loginGuard := lockout.NewGuard(serviceCache,
+		lockout.NewPolicy(cfg.LoginUserThreshold, cfg.LoginBackoff.Duration(), cfg.LoginLockout.Duration()),
+		lockout.NewPolicy(cfg.LoginIPThreshold, cfg.LoginBackoff.Duration(), cfg.LoginLockout.Duration()),
+	)
 
 	service := app.NewCodeService(
-		authTokenTTL,
-		hashTTL,
-		socialLinkTTL,
+		cfg.AuthTokenTTL.Duration(),
+		cfg.HashTTL.Duration(),
+		cfg.SocialLinkTTL.Duration(),
 		&logger,
-		cache,
+		serviceCache,
+		serviceImageCache,
 		codeRepo,
 		userRepo,
+		campaignRepo,
+		styleRepo,
+		qrSource,
 		passEncryptor,
 		authTokenEncryptor,
 		hashEncryptor,
+		linker,
+		loginGuard,
+		cfg.PausedCodeURL,
+		maintenancePage,
 	)
 
-	rest := api.NewRest(bindAddr, reqTimeout, parseTimeout, &logger, service)
-	err = rest.Start()
+	// background workers are stopped after server, as requests could wait for them
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	workers := &sync.WaitGroup{}
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		service.RunTrashPurge(workersCtx, cfg.TrashPurgeInterval.Duration(), cfg.TrashRetention.Duration())
+	}()
+	go func() {
+		defer workers.Done()
+		service.RunScanFlush(workersCtx, cfg.ScanFlushInterval.Duration())
+	}()
+
+	serverTimeouts := api.ServerTimeouts{
+		Read:  cfg.ReadTimeout.Duration(),
+		Write: cfg.WriteTimeout.Duration(),
+		Idle:  cfg.IdleTimeout.Duration(),
+	}
+	checker, err := newChecker(cfg, db, cache)
 	if err != nil {
-		log.Fatalf("error with server: %v", err)
+		log.Fatalf("unable to initialize readiness checks: %v", err)
+	}
+	limits := newRateLimits(cfg, proxies, serviceCache)
+	rest := api.NewRest(cfg.BindAddress, cfg.RequestTimeout.Duration(), cfg.ParseRequestTimeout.Duration(), serverTimeouts, &logger, service, checker, build, limits, middlewares...)
+	if m != nil {
+		rest.Handle("/metrics", m.Handler())
+	}
+	if cfg.AdminToken != "" {
+		rest.EnableAdmin(cfg.AdminToken)
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- rest.Start()
+	}()
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	failed := false
+	select {
+	case <-signalCtx.Done():
+		logger.Info().Msg("shutting down")
+	case err = <-serverErr:
+		logger.Error().Err(err).Msg("error with server")
+		failed = true
+	}
+	// second signal stops program at once
+	stopSignals()
+
+	// Teardown in reverse order: API, workers, spans, caches, DB
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration())
+	err = rest.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Error().Err(err).Msg("in-flight requests are not finished")
+		failed = true
+	}
+	stopWorkers()
+	if !waitGroup(shutdownCtx, workers) {
+		logger.Error().Msg("background workers are not finished")
+		failed = true
+	}
+	err = shutdownTracing(shutdownCtx)
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to flush spans")
+		failed = true
+	}
+	cancel()
+	cache.Close()
+	imageCache.Close()
+	err = db.Close()
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to close DB")
+		failed = true
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+	logger.Info().Msg("server stopped")
+}
+
+// waitGroup waits for wg until ctx is done. It returns false if ctx is done first
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newChecker creates readiness checks of DB, its schema, cache and upstreams
+func newChecker(cfg config.Config, db *sql.DB, cache domain.Cacher) (*health.Checker, error) {
+	latest, err := migrations.Latest()
+	if err != nil {
+		return nil, err
+	}
+	checker := health.NewChecker(cfg.ReadinessTimeout.Duration(), cfg.ReadinessCacheTTL.Duration())
+	checker.Add("db", db.PingContext)
+	checker.Add("schema", health.SchemaCheck(func(ctx context.Context) (int64, error) {
+		return sqlite.SchemaVersion(ctx, db)
+	}, latest))
+	checker.Add("cache", health.CacheCheck(cache))
+	client := &http.Client{
+		// redirect is response of reachable upstream
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	// names are public, URLs are only in logged errors
+	for i, u := range cfg.ReadinessURLs {
+		checker.Add("upstream "+strconv.Itoa(i+1), health.HTTPCheck(client, u))
+	}
+	return checker, nil
+}
+
+// newRateLimits creates limiters of API routes with store in memory of instance or in shared cache
+func newRateLimits(cfg config.Config, proxies ratelimit.TrustedProxies, cache domain.Cacher) api.RateLimits {
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == "cache" {
+		store = ratelimit.NewCacheStore(cache)
+	}
+	return api.RateLimits{
+		Token:   ratelimit.NewLimiter("token", cfg.RateLimitToken, store),
+		Public:  ratelimit.NewLimiter("public", cfg.RateLimitPublic, store),
+		Scan:    ratelimit.NewLimiter("scan", cfg.RateLimitScan, store),
+		User:    ratelimit.NewLimiter("user", cfg.RateLimitUser, store),
+		Proxies: proxies,
+	}
+}
+
+// newKeyring creates keyring with legacy v01 key and additional versions
+func newKeyring(legacyKey string, specs []string, current string) (token.Keyring, error) {
+	legacy, err := token.NewAES(legacyKey)
+	if err != nil {
+		return token.Keyring{}, err
+	}
+	versions := []token.Version{legacy}
+	for _, spec := range specs {
+		v, err := token.ParseVersion(strings.TrimSpace(spec))
+		if err != nil {
+			return token.Keyring{}, err
+		}
+		versions = append(versions, v)
+	}
+	for i, v := range versions {
+		if v.Prefix() == current {
+			old := append(versions[:i:i], versions[i+1:]...)
+			return token.NewKeyring(v, old...)
+		}
 	}
+	return token.Keyring{}, fmt.Errorf("current version %s is not registered", current)
 }
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/pkg/errors"
+)
+
+// CreateCampaign creates campaign
+func (s CodeService) CreateCampaign(ctx context.Context, campaign entities.Campaign) (uint64, error) {
+	id, err := s.campaignRepo.Create(ctx, campaign)
+	if err != nil {
+		return 0, errors.Wrap(err, "CreateCampaign: Create: ")
+	}
+	return id, nil
+}
+
+// GetCampaigns returns campaigns of user with scan stats
+func (s CodeService) GetCampaigns(ctx context.Context, userID uint64) ([]entities.Campaign, error) {
+	campaigns, err := s.campaignRepo.List(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetCampaigns: List: ")
+	}
+	return campaigns, nil
+}
+
+// GetCampaign returns campaign with scan stats
+func (s CodeService) GetCampaign(ctx context.Context, campaignID uint64) (entities.Campaign, error) {
+	campaign, err := s.campaignRepo.Get(ctx, campaignID)
+	if err != nil {
+		return campaign, errors.Wrap(err, "GetCampaign: Get: ")
+	}
+	return campaign, nil
+}
+
+// UpdateCampaign updates name and description of campaign
+func (s CodeService) UpdateCampaign(ctx context.Context, campaign entities.Campaign) error {
+	err := s.campaignRepo.Update(ctx, campaign)
+	if err != nil {
+		return errors.Wrap(err, "UpdateCampaign: Update: ")
+	}
+	return nil
+}
+
+// DeleteCampaign removes campaign. Its codes are kept without campaign
+func (s CodeService) DeleteCampaign(ctx context.Context, campaignID uint64) error {
+	err := s.campaignRepo.Delete(ctx, campaignID)
+	if err != nil {
+		return errors.Wrap(err, "DeleteCampaign: Delete: ")
+	}
+	return nil
+}
+
+// MoveCodes moves codes of user to campaign. CampaignID 0 removes codes from their campaigns.
+// Nothing is moved if any code or campaign doesn't belong to user.
+func (s CodeService) MoveCodes(ctx context.Context, userID uint64, campaignID uint64, codeIDs []uint64) error {
+	if campaignID != 0 {
+		campaign, err := s.campaignRepo.Get(ctx, campaignID)
+		if err != nil {
+			return errors.Wrap(err, "MoveCodes: Get campaign: ")
+		}
+		if campaign.UserID != userID {
+			return errors.Wrap(domain.ErrCampaignNotFound, "MoveCodes: campaign of another user")
+		}
+	}
+	for _, id := range codeIDs {
+		code, err := s.GetCode(ctx, id)
+		if err != nil {
+			return errors.Wrap(err, "MoveCodes: ")
+		}
+		if code.UserID != userID {
+			return errors.Wrap(domain.ErrCodeNotFound, "MoveCodes: code of another user")
+		}
+	}
+	err := s.codeRepo.SetCampaign(ctx, campaignID, codeIDs)
+	if err != nil {
+		return errors.Wrap(err, "MoveCodes: SetCampaign: ")
+	}
+	return nil
+}
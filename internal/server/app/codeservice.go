@@ -2,21 +2,36 @@ package app
 
 import (
 	"context"
-	"encoding/base64"
+	"encoding/json"
 	"github.com/hotafrika/griz-backend/internal/server/app/authtoken"
+	"github.com/hotafrika/griz-backend/internal/server/app/lockout"
 	"github.com/hotafrika/griz-backend/internal/server/app/password"
+	"github.com/hotafrika/griz-backend/internal/server/app/payload"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
 	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrsheet"
+	"github.com/hotafrika/griz-backend/internal/server/app/scancount"
 	"github.com/hotafrika/griz-backend/internal/server/app/token"
 	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
 	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache"
 	"github.com/hotafrika/griz-backend/internal/server/infrastructure/instagram"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/tracing"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"net"
 	"strconv"
 	"time"
 )
 
+// DefaultMaintenancePage is shown for paused codes without fallback URL
+const DefaultMaintenancePage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Temporarily unavailable</title></head>
+<body><h1>Temporarily unavailable</h1><p>Content of this code is paused by its owner. Please try again later.</p></body>
+</html>
+`
+
 // CodeService contains app logic
 type CodeService struct {
 	authTokenTTL       time.Duration
@@ -24,13 +39,24 @@ type CodeService struct {
 	socialLinkTTL      time.Duration
 	logger             *zerolog.Logger
 	cache              domain.Cacher
+	imageCache         domain.ImageCacher
 	codeRepo           domain.CodeRepository
 	userRepo           domain.UserRepository
+	campaignRepo       domain.CampaignRepository
+	styleRepo          domain.StyleRepository
 	qrSource           *instagram.QRSource
 	qrEncoder          qrencoder.Yeqown
+	qrDecoder          qrdecoder.Makiuchi
 	passEncryptor      password.Encryptor
 	authTokenEncryptor authtoken.JWT
-	hashEncryptor      token.AES
+	hashEncryptor      token.Keyring
+	linker             token.Linker
+	loginGuard         *lockout.Guard
+	scans              *scancount.Counter
+	// lookupTXT resolves TXT records of domains which users verify
+	lookupTXT       func(ctx context.Context, name string) ([]string, error)
+	pausedURL       string
+	maintenancePage string
 }
 
 // NewCodeService creates new service
@@ -40,11 +66,19 @@ func NewCodeService(
 	socialLinkTTL time.Duration,
 	logger *zerolog.Logger,
 	cache domain.Cacher,
+	imageCache domain.ImageCacher,
 	codeRepo domain.CodeRepository,
 	userRepo domain.UserRepository,
+	campaignRepo domain.CampaignRepository,
+	styleRepo domain.StyleRepository,
+	qrSource *instagram.QRSource,
 	passEncryptor password.Encryptor,
 	authTokenEncryptor authtoken.JWT,
-	hashEncryptor token.AES,
+	hashEncryptor token.Keyring,
+	linker token.Linker,
+	loginGuard *lockout.Guard,
+	pausedURL string,
+	maintenancePage string,
 ) CodeService {
 	return CodeService{
 		authTokenTTL:       authTokenTTL,
@@ -52,28 +86,59 @@ func NewCodeService(
 		socialLinkTTL:      socialLinkTTL,
 		logger:             logger,
 		cache:              cache,
+		imageCache:         imageCache,
 		codeRepo:           codeRepo,
 		userRepo:           userRepo,
+		campaignRepo:       campaignRepo,
+		styleRepo:          styleRepo,
+		qrSource:           qrSource,
 		passEncryptor:      passEncryptor,
 		authTokenEncryptor: authTokenEncryptor,
 		hashEncryptor:      hashEncryptor,
-		qrSource:           instagram.NewQRSourceWithLogger(logger),
+		linker:             linker,
+		loginGuard:         loginGuard,
+		scans:              scancount.NewCounter(codeRepo),
+		lookupTXT:          net.DefaultResolver.LookupTXT,
+		pausedURL:          pausedURL,
+		maintenancePage:    maintenancePage,
 		qrEncoder:          qrencoder.DefaultYeqown(),
+		qrDecoder:          qrdecoder.Makiuchi{},
 	}
 }
 
-// CreateAuthToken returns userID by authToken if last exists
-func (s CodeService) CreateAuthToken(ctx context.Context, user entities.User) (string, error) {
+// CreateAuthToken returns userID by authToken if last exists.
+// Failed logins are counted by username and client IP, lockout.LockedError is returned while login has to wait.
+func (s CodeService) CreateAuthToken(ctx context.Context, user entities.User, clientIP string) (string, error) {
+	err := s.loginGuard.Check(ctx, user.Username, clientIP)
+	if err != nil {
+		var locked *lockout.LockedError
+		if errors.As(err, &locked) {
+			s.log(ctx).Warn().Str("event", "login_blocked").Str("username", user.Username).Str("ip", clientIP).
+				Str("by", locked.Kind).Bool("locked", locked.Locked).Dur("retry_after", locked.RetryAfter).Send()
+		}
+		return "", errors.Wrap(err, "CreateAuthToken: Check: ")
+	}
+
 	encodedPass, err := s.passEncryptor.EncodeString(user.Password)
 	if err != nil {
+		s.loginReleased(ctx, user.Username, clientIP)
 		return "", errors.Wrap(err, "CreateAuthToken: EncodeString: ")
 	}
 	user.Password = string(encodedPass)
 
 	id, err := s.userRepo.GetByUsernameAndPass(ctx, user)
 	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			s.loginFailed(ctx, user.Username, clientIP)
+		} else {
+			s.loginReleased(ctx, user.Username, clientIP)
+		}
 		return "", errors.Wrap(err, "CreateAuthToken: GetByUsernameAndPass: ")
 	}
+	err = s.loginGuard.Succeed(ctx, user.Username, clientIP)
+	if err != nil {
+		return "", errors.Wrap(err, "CreateAuthToken: Succeed: ")
+	}
 
 	authToken, err := s.authTokenEncryptor.MakeByID(id)
 	if err != nil {
@@ -88,6 +153,43 @@ func (s CodeService) CreateAuthToken(ctx context.Context, user entities.User) (s
 	return authToken, nil
 }
 
+// loginFailed counts failed login and logs security events. Errors of counters don't change response
+func (s CodeService) loginFailed(ctx context.Context, username, clientIP string) {
+	user, client, err := s.loginGuard.Fail(ctx, username, clientIP)
+	if err != nil {
+		s.log(ctx).Error().Err(err).Msg("unable to count failed login")
+		return
+	}
+	s.log(ctx).Warn().Str("event", "login_failed").Str("username", username).Str("ip", clientIP).
+		Int("username_failures", user.Failures).Int("ip_failures", client.Failures).Send()
+	if user.Locked {
+		s.log(ctx).Warn().Str("event", "login_locked").Str("username", username).Str("ip", clientIP).
+			Str("by", lockout.KindUsername).Time("until", user.Until).Send()
+	}
+	if client.Locked {
+		s.log(ctx).Warn().Str("event", "login_locked").Str("username", username).Str("ip", clientIP).
+			Str("by", lockout.KindIP).Time("until", client.Until).Send()
+	}
+}
+
+// loginReleased releases attempt which has no result, so it doesn't delay next attempts
+func (s CodeService) loginReleased(ctx context.Context, username, clientIP string) {
+	err := s.loginGuard.Release(ctx, username, clientIP)
+	if err != nil {
+		s.log(ctx).Error().Err(err).Msg("unable to release login attempt")
+	}
+}
+
+// UnlockLogin resets failed logins of username and client IP. Empty values are skipped
+func (s CodeService) UnlockLogin(ctx context.Context, username, clientIP string) error {
+	err := s.loginGuard.Unlock(ctx, username, clientIP)
+	if err != nil {
+		return errors.Wrap(err, "UnlockLogin: ")
+	}
+	s.log(ctx).Warn().Str("event", "login_unlocked").Str("username", username).Str("ip", clientIP).Send()
+	return nil
+}
+
 // GetUserIDByAuthToken returns userID by authToken if last exists
 func (s CodeService) GetUserIDByAuthToken(ctx context.Context, authToken string) (uint64, error) {
 	res, err := s.cache.Get(ctx, cache.AuthToken{Key: authToken})
@@ -111,44 +213,123 @@ func (s CodeService) GetUser(ctx context.Context, id uint64) (entities.User, err
 	return user, nil
 }
 
-// FindCodeBySocial returns sourceUrl by social link
-func (s CodeService) FindCodeBySocial(ctx context.Context, link string) (string, error) {
-	value, err := s.cache.Get(ctx, cache.SocialUrl{Key: link})
-	if err == nil { // link found
-		return value, nil
-	}
-	if !errors.Is(err, domain.ErrCacheNotExist) { // some error
-		return "", errors.Wrap(err, "FindCodeBySocial: get cache: ")
-	}
+// FindCodeBySocial returns sourceUrl by social link.
+// Griz link found in social post is cached, so updates of code are visible and scans are counted.
+func (s CodeService) FindCodeBySocial(ctx context.Context, link string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "CodeService.FindCodeBySocial")
+	defer tracing.End(span, &err)
 
-	// link not found
-	b, err := s.qrSource.GetFirstQR(ctx, link)
+	grizLink, err := s.cache.Get(ctx, cache.SocialUrl{Key: link})
 	if err != nil {
-		return "", errors.Wrap(err, "FindCodeBySocial: GetFirstQR: ")
+		if !errors.Is(err, domain.ErrCacheNotExist) { // some error
+			return "", errors.Wrap(err, "FindCodeBySocial: get cache: ")
+		}
+
+		// link not found
+		b, err := s.qrSource.GetFirstQR(ctx, link)
+		if err != nil {
+			return "", errors.Wrap(err, "FindCodeBySocial: GetFirstQR: ")
+		}
+		grizLink = string(b)
+
+		err = s.cache.Set(ctx, cache.SocialUrl{Key: link}, grizLink, s.socialLinkTTL)
+		if err != nil {
+			return "", errors.Wrap(err, "FindCodeBySocial: set cache: ")
+		}
 	}
 
-	hashToken, err := token.ExtractHashFromLink(string(b))
-	if err != nil { // token not found in decoded data
-		return "", errors.Wrap(err, "FindCodeBySocial: ExtractHashFromLink: ")
+	srcLink, err := s.FindCodeByLink(ctx, grizLink)
+	if err != nil {
+		return "", errors.Wrap(err, "FindCodeBySocial: FindCodeByLink: ")
 	}
+	return srcLink, nil
+}
 
-	srcLink, err := s.FindCodeByHash(ctx, hashToken)
+// FindCodeByLink returns sourceUrl by griz link.
+// Links on custom domains are resolved only for codes of domain owner.
+func (s CodeService) FindCodeByLink(ctx context.Context, link string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "CodeService.FindCodeByLink")
+	defer tracing.End(span, &err)
+
+	host, hashToken, err := token.ParseLink(link)
 	if err != nil {
-		return "", errors.Wrap(err, "FindCodeBySocial: FindCodeByHash: ")
+		return "", errors.Wrap(err, "FindCodeByLink: ParseLink: ")
+	}
+	if s.linker.IsOwnDomain(host) {
+		srcLink, err := s.FindCodeByHash(ctx, hashToken)
+		if err != nil {
+			return "", errors.Wrap(err, "FindCodeByLink: FindCodeByHash: ")
+		}
+		return srcLink, nil
 	}
 
-	err = s.cache.Set(ctx, cache.SocialUrl{Key: link}, srcLink, s.socialLinkTTL)
+	owner, err := s.userRepo.GetByDomain(ctx, host)
 	if err != nil {
-		return "", errors.Wrap(err, "FindCodeBySocial: set cache: ")
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return "", errors.Wrap(token.ErrNotGrizLink, "FindCodeByLink: unknown domain")
+		}
+		return "", errors.Wrap(err, "FindCodeByLink: GetByDomain: ")
 	}
+	code, err := s.getByToken(ctx, hashToken)
+	if err != nil {
+		return "", errors.Wrap(err, "FindCodeByLink: getByToken: ")
+	}
+	if code.UserID != owner.ID {
+		return "", errors.Wrap(domain.ErrCodeNotFound, "FindCodeByLink: code of another domain")
+	}
+	if !hasTarget(code) {
+		return "", errors.Wrap(domain.ErrCodeNotFound, "FindCodeByLink: static code has no target")
+	}
+	s.recordScan(code.ID)
+	return s.codeTarget(code), nil
+}
 
-	return srcLink, nil
+// CheckGrizLink returns checker of links found in social posts. Link has to be on own domain
+// or custom domain of user, else token.ErrNotGrizLink is returned
+func CheckGrizLink(linker token.Linker, userRepo domain.UserRepository) func(ctx context.Context, link string) error {
+	return func(ctx context.Context, link string) error {
+		host, _, err := token.ParseLink(link)
+		if err != nil {
+			return errors.Wrap(err, "CheckGrizLink: ParseLink: ")
+		}
+		if linker.IsOwnDomain(host) {
+			return nil
+		}
+		_, err = userRepo.GetByDomain(ctx, host)
+		if err != nil {
+			if errors.Is(err, domain.ErrUserNotFound) {
+				return errors.Wrap(token.ErrNotGrizLink, "CheckGrizLink: unknown domain")
+			}
+			return errors.Wrap(err, "CheckGrizLink: GetByDomain: ")
+		}
+		return nil
+	}
 }
 
-// FindCodeByHash returns sourceUrl by its hash
-func (s CodeService) FindCodeByHash(ctx context.Context, hashToken string) (string, error) {
+// FindCodeByHash returns sourceUrl by its hash or slug and records scan
+func (s CodeService) FindCodeByHash(ctx context.Context, hashToken string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "CodeService.FindCodeByHash")
+	defer tracing.End(span, &err)
+
+	// forged and malformed tokens are rejected before cache and repo
+	hashToken, err = s.canonicalToken(hashToken)
+	if err != nil {
+		return "", errors.Wrap(err, "FindCodeByHash: canonicalToken: ")
+	}
+	if !token.IsHash(hashToken) {
+		hashToken, err = s.slugHash(ctx, hashToken)
+		if err != nil {
+			return "", errors.Wrap(err, "FindCodeByHash: slugHash: ")
+		}
+	}
+	id, err := s.hashEncryptor.Decode(hashToken)
+	if err != nil {
+		return "", errors.Wrap(domain.ErrCodeNotFound, "FindCodeByHash: Decode: "+err.Error())
+	}
+
 	value, err := s.cache.Get(ctx, cache.HashUrl{Key: hashToken})
 	if err == nil { // hashToken found
+		s.recordScan(id)
 		return value, nil
 	}
 	if !errors.Is(err, domain.ErrCacheNotExist) { // some error
@@ -156,16 +337,128 @@ func (s CodeService) FindCodeByHash(ctx context.Context, hashToken string) (stri
 	}
 
 	// hashToken not found
-	code, err := s.codeRepo.GetByHash(ctx, hashToken)
+	code, err := s.getByToken(ctx, hashToken)
 	if err != nil {
-		return "", errors.Wrap(err, "FindCodeByHash: GetByHash: ")
+		return "", errors.Wrap(err, "FindCodeByHash: getByToken: ")
+	}
+	if !hasTarget(code) {
+		return "", errors.Wrap(domain.ErrCodeNotFound, "FindCodeByHash: static code has no target")
 	}
 
-	err = s.cache.Set(ctx, cache.HashUrl{Key: code.Hash}, code.SrcURL, s.hashTTL)
+	target := s.codeTarget(code)
+	err = s.cache.Set(ctx, cache.HashUrl{Key: hashToken}, target, s.hashTTL)
 	if err != nil {
 		return "", errors.Wrap(err, "FindCodeByHash: set cache: ")
 	}
-	return code.SrcURL, nil
+	s.recordScan(code.ID)
+	return target, nil
+}
+
+// GetCodeByHash returns code by its hash or slug.
+// It is used for hosted pages, so scan is recorded.
+func (s CodeService) GetCodeByHash(ctx context.Context, hashToken string) (entities.Code, error) {
+	code, err := s.getByToken(ctx, hashToken)
+	if err != nil {
+		return code, errors.Wrap(err, "GetCodeByHash: getByToken: ")
+	}
+	s.recordScan(code.ID)
+	return code, nil
+}
+
+// slugHash returns hash of code by its slug
+func (s CodeService) slugHash(ctx context.Context, slug string) (string, error) {
+	hash, err := s.cache.Get(ctx, cache.SlugHash{Key: slug})
+	if err == nil {
+		return hash, nil
+	}
+	if !errors.Is(err, domain.ErrCacheNotExist) {
+		return "", errors.Wrap(err, "get cache: ")
+	}
+	code, err := s.codeRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return "", errors.Wrap(err, "GetBySlug: ")
+	}
+	err = s.cache.Set(ctx, cache.SlugHash{Key: slug}, code.Hash, s.hashTTL)
+	if err != nil {
+		return "", errors.Wrap(err, "set cache: ")
+	}
+	return code.Hash, nil
+}
+
+// log returns logger of request from ctx. Background work without it logs through logger of service
+func (s CodeService) log(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return s.logger
+}
+
+// recordScan counts scan of code in memory. Scans are written to repo in batches by RunScanFlush
+func (s CodeService) recordScan(codeID uint64) {
+	s.scans.Add(codeID)
+}
+
+// getByToken returns code from repo by hash or slug.
+// Hash is decoded to code ID, so code is found by primary key.
+// Trashed codes are not resolved: ErrCodeDisabled is returned.
+// Paused codes are returned only if they have fallback URL, else ErrCodePaused is returned.
+func (s CodeService) getByToken(ctx context.Context, hashToken string) (entities.Code, error) {
+	code, err := s.findByToken(ctx, hashToken)
+	if err != nil {
+		return code, err
+	}
+	if code.IsTrashed() {
+		return entities.Code{}, errors.Wrap(domain.ErrCodeDisabled, "code is trashed")
+	}
+	if code.Paused && s.PausedURL(code) == "" {
+		return entities.Code{}, errors.Wrap(domain.ErrCodePaused, "no fallback URL")
+	}
+	return code, nil
+}
+
+// findByToken returns code by hash or slug regardless of its state
+func (s CodeService) findByToken(ctx context.Context, hashToken string) (entities.Code, error) {
+	var code entities.Code
+	if token.IsHash(hashToken) {
+		id, err := s.hashEncryptor.Decode(hashToken)
+		if err != nil {
+			return code, errors.Wrap(domain.ErrCodeNotFound, "Decode: "+err.Error())
+		}
+		code, err = s.codeRepo.Get(ctx, id)
+		if err != nil {
+			return code, err
+		}
+		// code could be created but its hash is not saved yet
+		if code.Hash != hashToken {
+			return entities.Code{}, errors.Wrap(domain.ErrCodeNotFound, "hash mismatch")
+		}
+	} else {
+		slug, err := s.canonicalToken(hashToken)
+		if err != nil {
+			return code, err
+		}
+		code, err = s.codeRepo.GetBySlug(ctx, slug)
+		if err != nil {
+			return code, err
+		}
+	}
+	return code, nil
+}
+
+// canonicalToken validates hash or slug without repo and returns it in canonical form.
+// Hashes are accepted only as they were created, slugs are case-insensitive.
+func (s CodeService) canonicalToken(hashToken string) (string, error) {
+	if token.IsHash(hashToken) {
+		if _, err := s.hashEncryptor.Decode(hashToken); err != nil {
+			return "", errors.Wrap(domain.ErrCodeNotFound, "Decode: "+err.Error())
+		}
+		return hashToken, nil
+	}
+	slug := token.NormalizeSlug(hashToken)
+	if err := token.ValidateSlug(slug); err != nil {
+		return "", errors.Wrap(domain.ErrCodeNotFound, "ValidateSlug: "+err.Error())
+	}
+	return slug, nil
 }
 
 // CreateCode creates code and adds it to cache
@@ -188,7 +481,10 @@ func (s CodeService) CreateCode(ctx context.Context, code entities.Code) (uint64
 		return 0, errors.Wrap(err, "CreateCode: Update: ")
 	}
 
-	err = s.cache.Set(ctx, cache.HashUrl{Key: hashValue}, strconv.FormatUint(id, 10), s.hashTTL)
+	if !hasTarget(code) {
+		return id, nil
+	}
+	err = s.cache.Set(ctx, cache.HashUrl{Key: hashValue}, s.codeTarget(code), s.hashTTL)
 	if err != nil {
 		// TODO maybe delete from repo
 		return 0, errors.Wrap(err, "CreateCode: set cache: ")
@@ -197,57 +493,615 @@ func (s CodeService) CreateCode(ctx context.Context, code entities.Code) (uint64
 	return id, nil
 }
 
-// GetCodes returns codes by userID
-func (s CodeService) GetCodes(ctx context.Context, userID uint64) ([]entities.Code, error) {
-	codes, err := s.codeRepo.ListAll(ctx, userID)
+// CreateCodes creates all codes in single batch.
+// Codes are not cached, they get to cache on first resolution.
+func (s CodeService) CreateCodes(ctx context.Context, codes []entities.Code) ([]uint64, error) {
+	ids, err := s.codeRepo.CreateBatch(ctx, codes, s.hashEncryptor.Create)
+	if err != nil {
+		return nil, errors.Wrap(err, "CreateCodes: CreateBatch: ")
+	}
+	return ids, nil
+}
+
+// ExportedCode is code with its griz link. Link is empty for static codes
+type ExportedCode struct {
+	entities.Code
+	Link string
+}
+
+// ExportCodes returns all codes of user with their links
+func (s CodeService) ExportCodes(ctx context.Context, userID uint64) ([]ExportedCode, error) {
+	owner, err := s.userRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExportCodes: Get owner: ")
+	}
+	codes, err := s.codeRepo.ListByFilter(ctx, userID, domain.CodeFilter{})
+	if err != nil {
+		return nil, errors.Wrap(err, "ExportCodes: ListByFilter: ")
+	}
+	res := make([]ExportedCode, 0, len(codes))
+	for _, code := range codes {
+		link := ""
+		if !code.Type.IsStatic() {
+			link = s.ownerCodeLink(owner, code)
+		}
+		res = append(res, ExportedCode{Code: code, Link: link})
+	}
+	return res, nil
+}
+
+// GetCodes returns codes by userID selected by filter
+func (s CodeService) GetCodes(ctx context.Context, userID uint64, filter domain.CodeFilter) ([]entities.Code, error) {
+	codes, err := s.codeRepo.ListByFilter(ctx, userID, filter)
 	if err != nil {
-		return codes, errors.Wrap(err, "GetCodes: ListAll: ")
+		return codes, errors.Wrap(err, "GetCodes: ListByFilter: ")
 	}
 	return codes, nil
 }
 
-// GetCode returns code by userID
+// GetCode returns code by its ID. Trashed codes are not found
 func (s CodeService) GetCode(ctx context.Context, codeID uint64) (entities.Code, error) {
 	code, err := s.codeRepo.Get(ctx, codeID)
 	if err != nil {
 		return code, errors.Wrap(err, "GetCode: Get: ")
 	}
+	if code.IsTrashed() {
+		return entities.Code{}, errors.Wrap(domain.ErrCodeNotFound, "GetCode: code is trashed")
+	}
 	return code, nil
 }
 
-// DownloadCodeByHash returns code by userID
-func (s CodeService) DownloadCodeByHash(ctx context.Context, hashToken string) (string, error) {
-	b, err := s.qrEncoder.Encode([]byte(token.BuildLink(hashToken)))
+// GetTrashedCode returns code from trash by its ID
+func (s CodeService) GetTrashedCode(ctx context.Context, codeID uint64) (entities.Code, error) {
+	code, err := s.codeRepo.Get(ctx, codeID)
 	if err != nil {
-		return "", errors.Wrap(err, "DownloadCodeByHash: encode token: ")
+		return code, errors.Wrap(err, "GetTrashedCode: Get: ")
 	}
-	return base64.StdEncoding.EncodeToString(b), nil
+	if !code.IsTrashed() {
+		return entities.Code{}, errors.Wrap(domain.ErrCodeNotFound, "GetTrashedCode: code is not trashed")
+	}
+	return code, nil
+}
+
+// CodeImage is rendered QR image of code
+type CodeImage struct {
+	Data []byte
+	// ETag is changed when content or design of image is changed
+	ETag   string
+	Report qrdecoder.Report
+}
+
+// DownloadCodeByHash returns QR image by code hash
+func (s CodeService) DownloadCodeByHash(ctx context.Context, hashToken string) (CodeImage, error) {
+	code, err := s.getByToken(ctx, hashToken)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "DownloadCodeByHash: getByToken: ")
+	}
+	return s.DownloadCode(ctx, code)
+}
+
+// DownloadCode returns QR image of code with its scannability report.
+// Static codes contain payload itself, dynamic ones contain griz link.
+// Image is drawn with style of code or default style of owner.
+// Images are cached by hash and fingerprint of content and style, so changed style is rendered again.
+// qrdecoder.ErrUnscannable is returned if rendered image couldn't be decoded back.
+func (s CodeService) DownloadCode(ctx context.Context, code entities.Code) (_ CodeImage, err error) {
+	ctx, span := tracing.Start(ctx, "CodeService.DownloadCode")
+	defer tracing.End(span, &err)
+
+	owner, err := s.userRepo.Get(ctx, code.UserID)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "DownloadCode: Get owner: ")
+	}
+	content, err := s.ownerCodeContent(owner, code)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "DownloadCode: ownerCodeContent: ")
+	}
+	style, styled, err := s.codeStyle(ctx, owner, code)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "DownloadCode: codeStyle: ")
+	}
+	fingerprint := renderFingerprint(content, style, styled)
+	key := code.Hash + "_" + fingerprint
+	if image, ok := s.cachedCodeImage(ctx, key); ok {
+		image.ETag = fingerprint
+		return image, nil
+	}
+
+	encoder := s.qrEncoder
+	if styled {
+		encoder, err = s.styleEncoder(ctx, style)
+		if err != nil {
+			return CodeImage{}, errors.Wrap(err, "DownloadCode: styleEncoder: ")
+		}
+	}
+	b, err := encoder.Encode([]byte(content))
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "DownloadCode: encode content: ")
+	}
+	report, err := s.qrDecoder.Check(b, content)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "DownloadCode: Check: ")
+	}
+
+	image := CodeImage{Data: b, ETag: fingerprint, Report: report}
+	s.cacheCodeImage(ctx, key, image)
+	return image, nil
+}
+
+// cachedCodeImage returns rendered image with its report from cache
+func (s CodeService) cachedCodeImage(ctx context.Context, key string) (CodeImage, bool) {
+	data, err := s.imageCache.GetImage(ctx, cache.CodeImage{Key: key})
+	if err != nil {
+		return CodeImage{}, false
+	}
+	b, err := s.imageCache.GetImage(ctx, cache.CodeImageReport{Key: key})
+	if err != nil {
+		return CodeImage{}, false
+	}
+	var report qrdecoder.Report
+	if err := json.Unmarshal(b, &report); err != nil {
+		return CodeImage{}, false
+	}
+	return CodeImage{Data: data, Report: report}, true
+}
+
+// cacheCodeImage puts rendered image with its report to cache.
+// Errors are only logged: image is rendered anyway and is rendered again on next request.
+func (s CodeService) cacheCodeImage(ctx context.Context, key string, image CodeImage) {
+	b, err := json.Marshal(image.Report)
+	if err != nil {
+		s.log(ctx).Error().Err(err).Msg("unable to marshal report of image")
+		return
+	}
+	err = s.imageCache.SetImage(ctx, cache.CodeImageReport{Key: key}, b)
+	if err != nil {
+		s.log(ctx).Error().Err(err).Str("key", key).Msg("unable to cache report of image")
+		return
+	}
+	err = s.imageCache.SetImage(ctx, cache.CodeImage{Key: key}, image.Data)
+	if err != nil {
+		s.log(ctx).Error().Err(err).Str("key", key).Msg("unable to cache image")
+	}
+}
+
+// sheetCode is code prepared for sheet
+type sheetCode struct {
+	code    entities.Code
+	content string
+	style   entities.Style
+	styled  bool
+	design  string // fingerprint of style for codes drawn as images
+}
+
+// RenderCodes adds codes of user to sheet one by one.
+// All codes are checked before rendering, so nothing is added if any code is not found.
+// Codes are drawn in their styles to image sheets. Each design is checked for scannability once
+// by its densest code. Default style of account is skipped for sheets without images (PDF),
+// domain.ErrStyleNotSupported is returned for codes with own style there.
+// qrdecoder.ErrUnscannable is returned if image of code couldn't be decoded back.
+func (s CodeService) RenderCodes(ctx context.Context, userID uint64, ids []uint64, sheet qrsheet.Sheet) error {
+	owner, err := s.userRepo.Get(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "RenderCodes: Get owner: ")
+	}
+	images, hasImages := sheet.(qrsheet.ImageSheet)
+	codes := make([]sheetCode, 0, len(ids))
+	densest := map[string]int{}
+	for _, id := range ids {
+		code, err := s.GetCode(ctx, id)
+		if err != nil {
+			return errors.Wrap(err, "RenderCodes: ")
+		}
+		if code.UserID != userID {
+			return errors.Wrap(domain.ErrCodeNotFound, "RenderCodes: code of another user")
+		}
+		content, err := s.ownerCodeContent(owner, code)
+		if err != nil {
+			return errors.Wrap(err, "RenderCodes: ownerCodeContent: ")
+		}
+		style, styled, err := s.codeStyle(ctx, owner, code)
+		if err != nil {
+			return errors.Wrap(err, "RenderCodes: codeStyle: ")
+		}
+		if styled && !hasImages {
+			if code.StyleID != 0 {
+				return errors.Wrapf(domain.ErrStyleNotSupported, "RenderCodes: code %d", code.ID)
+			}
+			style, styled = entities.Style{}, false
+		}
+		c := sheetCode{code: code, content: content, style: style, styled: styled}
+		if hasImages && (styled || images.Ext() == "png") {
+			c.design = renderFingerprint("", style, styled)
+			if i, ok := densest[c.design]; !ok || len(content) > len(codes[i].content) {
+				densest[c.design] = len(codes)
+			}
+		}
+		codes = append(codes, c)
+	}
+	for i, c := range codes {
+		if c.design == "" || densest[c.design] != i {
+			continue
+		}
+		if err := s.checkSheetImage(ctx, c); err != nil {
+			return errors.Wrapf(err, "RenderCodes: code %d: ", c.code.ID)
+		}
+	}
+
+	for _, c := range codes {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "RenderCodes: ")
+		}
+		// plain vector codes are drawn by sheet itself
+		if c.design == "" {
+			err = sheet.Add(codeLabel(c.code), c.content)
+			if err != nil {
+				return errors.Wrap(err, "RenderCodes: Add: ")
+			}
+			continue
+		}
+		b, err := s.renderSheetImage(ctx, c, images.Ext())
+		if err != nil {
+			return errors.Wrapf(err, "RenderCodes: code %d: ", c.code.ID)
+		}
+		err = images.AddImage(codeLabel(c.code), b)
+		if err != nil {
+			return errors.Wrap(err, "RenderCodes: AddImage: ")
+		}
+	}
+	return nil
+}
+
+// checkSheetImage checks if raster image of code in its style is scannable
+func (s CodeService) checkSheetImage(ctx context.Context, c sheetCode) error {
+	b, err := s.encodeSheetPNG(ctx, c)
+	if err != nil {
+		return err
+	}
+	_, err = s.qrDecoder.Check(b, c.content)
+	if err != nil {
+		return errors.Wrap(err, "Check: ")
+	}
+	return nil
+}
+
+// encodeSheetPNG draws code in its style as PNG
+func (s CodeService) encodeSheetPNG(ctx context.Context, c sheetCode) ([]byte, error) {
+	encoder := qrencoder.DefaultYeqown(qrencoder.WithPNG())
+	if c.styled {
+		var err error
+		encoder, err = s.styleEncoder(ctx, c.style, qrencoder.WithPNG())
+		if err != nil {
+			return nil, errors.Wrap(err, "styleEncoder: ")
+		}
+	}
+	b, err := encoder.Encode([]byte(c.content))
+	if err != nil {
+		return nil, errors.Wrap(err, "encode content: ")
+	}
+	return b, nil
+}
+
+// renderSheetImage draws code in its style as PNG or SVG
+func (s CodeService) renderSheetImage(ctx context.Context, c sheetCode, ext string) ([]byte, error) {
+	if ext != "svg" {
+		return s.encodeSheetPNG(ctx, c)
+	}
+
+	logo, _, err := s.styleLogo(ctx, c.style)
+	if err != nil {
+		return nil, errors.Wrap(err, "styleLogo: ")
+	}
+	options, err := svgStyleOptions(c.style, logo)
+	if err != nil {
+		return nil, err
+	}
+	b, err := qrencoder.NewSVG(10, options...).Encode([]byte(c.content))
+	if err != nil {
+		return nil, errors.Wrap(err, "encode SVG: ")
+	}
+	return b, nil
 }
 
 // UpdateCode ...
 func (s CodeService) UpdateCode(ctx context.Context, code entities.Code) error {
-	err := s.cache.Set(ctx, cache.HashUrl{Key: code.Hash}, code.SrcURL, s.hashTTL)
-	if err != nil {
-		return errors.Wrap(err, "UpdateCode: set cache: ")
+	if code.Paused || !hasTarget(code) {
+		// target of paused code depends on fallback, so it is cached on next resolution.
+		// Static code has no target, stale one is removed
+		err := s.cache.Delete(ctx, cache.HashUrl{Key: code.Hash})
+		if err != nil {
+			return errors.Wrap(err, "UpdateCode: delete cache: ")
+		}
+	} else {
+		err := s.cache.Set(ctx, cache.HashUrl{Key: code.Hash}, s.codeTarget(code), s.hashTTL)
+		if err != nil {
+			return errors.Wrap(err, "UpdateCode: set cache: ")
+		}
 	}
 
-	err = s.codeRepo.Update(ctx, code)
+	err := s.codeRepo.Update(ctx, code)
 	if err != nil {
 		return errors.Wrap(err, "UpdateCode: Update: ")
 	}
 	return nil
 }
 
-// DeleteCode ...
+// SetCodePaused pauses or resumes code. Paused code is resolved to fallbackURL,
+// default fallback URL or maintenance page. Resumed code loses its fallback URL.
+// Resolution cache of code is dropped. SocialUrl cache keeps only griz links which are resolved
+// through HashUrl cache, so it doesn't contain state of code.
+func (s CodeService) SetCodePaused(ctx context.Context, code entities.Code, paused bool, fallbackURL string) (entities.Code, error) {
+	code.Paused = paused
+	code.PausedURL = ""
+	if paused {
+		code.PausedURL = fallbackURL
+	}
+	err := s.codeRepo.Update(ctx, code)
+	if err != nil {
+		return code, errors.Wrap(err, "SetCodePaused: Update: ")
+	}
+	err = s.cache.Delete(ctx, cache.HashUrl{Key: code.Hash})
+	if err != nil {
+		return code, errors.Wrap(err, "SetCodePaused: delete cache: ")
+	}
+	return code, nil
+}
+
+// PausedURL returns fallback URL of paused code. Empty URL means that maintenance page has to be shown
+func (s CodeService) PausedURL(code entities.Code) string {
+	if code.PausedURL != "" {
+		return code.PausedURL
+	}
+	return s.pausedURL
+}
+
+// MaintenancePage returns HTML page shown for paused codes without fallback URL
+func (s CodeService) MaintenancePage() string {
+	return s.maintenancePage
+}
+
+// SetCodeSlug claims new slug for code. Empty slug releases current one
+func (s CodeService) SetCodeSlug(ctx context.Context, code entities.Code, slug string) (entities.Code, error) {
+	oldSlug := code.Slug
+	code.Slug = token.NormalizeSlug(slug)
+	err := s.codeRepo.Update(ctx, code)
+	if err != nil {
+		return code, errors.Wrap(err, "SetCodeSlug: Update: ")
+	}
+	if oldSlug != "" && oldSlug != code.Slug {
+		err = s.cache.Delete(ctx, cache.SlugHash{Key: oldSlug})
+		if err != nil {
+			return code, errors.Wrap(err, "SetCodeSlug: delete cache: ")
+		}
+	}
+	return code, nil
+}
+
+// DeleteCode moves code to trash. Its links are disabled until restore or purge
 func (s CodeService) DeleteCode(ctx context.Context, code entities.Code) error {
 	err := s.cache.Delete(ctx, cache.HashUrl{Key: code.Hash})
 	if err != nil {
-		return errors.Wrap(err, "UpdateCode: delete cache: ")
+		return errors.Wrap(err, "DeleteCode: delete cache: ")
 	}
+	if code.Slug != "" {
+		err = s.cache.Delete(ctx, cache.SlugHash{Key: code.Slug})
+		if err != nil {
+			return errors.Wrap(err, "DeleteCode: delete slug cache: ")
+		}
+	}
+
+	err = s.codeRepo.Trash(ctx, code.ID, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "DeleteCode: Trash: ")
+	}
+	return nil
+}
+
+// RestoreCode returns code from trash, so its links are resolved again
+func (s CodeService) RestoreCode(ctx context.Context, code entities.Code) error {
+	err := s.codeRepo.Restore(ctx, code.ID)
+	if err != nil {
+		return errors.Wrap(err, "RestoreCode: Restore: ")
+	}
+	return nil
+}
+
+// PurgeTrash permanently removes codes which are in trash longer than retention.
+// It returns number of removed codes.
+func (s CodeService) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+	codes, err := s.codeRepo.Purge(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return 0, errors.Wrap(err, "PurgeTrash: Purge: ")
+	}
+	for _, code := range codes {
+		err = s.cache.Delete(ctx, cache.HashUrl{Key: code.Hash})
+		if err != nil {
+			return len(codes), errors.Wrap(err, "PurgeTrash: delete cache: ")
+		}
+		if code.Slug != "" {
+			err = s.cache.Delete(ctx, cache.SlugHash{Key: code.Slug})
+			if err != nil {
+				return len(codes), errors.Wrap(err, "PurgeTrash: delete slug cache: ")
+			}
+		}
+	}
+	return len(codes), nil
+}
 
-	err = s.codeRepo.Delete(ctx, code.ID)
+// RunTrashPurge purges trash every interval until ctx is done.
+// Purge in progress is interrupted by ctx too.
+func (s CodeService) RunTrashPurge(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.PurgeTrash(ctx, retention)
+		if err != nil && ctx.Err() == nil {
+			s.log(ctx).Error().Err(err).Msg("unable to purge trash")
+		} else if n > 0 {
+			s.log(ctx).Info().Int("codes", n).Msg("trash purged")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunScanFlush writes counted scans to repo every interval until ctx is done.
+// Remaining scans are written after ctx is done, so they aren't lost on shutdown.
+func (s CodeService) RunScanFlush(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			_, err := s.scans.Flush(context.Background())
+			if err != nil {
+				s.logger.Error().Err(err).Uint64("scans", s.scans.Pending()).Msg("scans are lost")
+			}
+			return
+		case <-ticker.C:
+		}
+
+		_, err := s.scans.Flush(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.log(ctx).Error().Err(err).Msg("unable to write scans")
+		}
+	}
+}
+
+// SetUserDomain requests custom domain for links of user. Domain is pending until VerifyUserDomain finds
+// TXT record of token.DomainVerificationRecord, current domain is used till then. Empty domain removes both
+func (s CodeService) SetUserDomain(ctx context.Context, userID uint64, domainName string) error {
+	domainName = token.NormalizeDomain(domainName)
+	if domainName == "" {
+		err := s.userRepo.SetDomain(ctx, userID, "")
+		if err != nil {
+			return errors.Wrap(err, "SetUserDomain: SetDomain: ")
+		}
+		err = s.userRepo.SetPendingDomain(ctx, userID, "", "")
+		if err != nil {
+			return errors.Wrap(err, "SetUserDomain: SetPendingDomain: ")
+		}
+		return nil
+	}
+	if s.linker.IsOwnDomain(domainName) {
+		return errors.Wrap(domain.ErrDomainAlreadyExists, "SetUserDomain: ")
+	}
+
+	owner, err := s.userRepo.GetByDomain(ctx, domainName)
+	switch {
+	case err == nil && owner.ID == userID:
+		// domain is verified already, other pending domain is dropped
+		err = s.userRepo.SetPendingDomain(ctx, userID, "", "")
+		if err != nil {
+			return errors.Wrap(err, "SetUserDomain: SetPendingDomain: ")
+		}
+		return nil
+	case err == nil:
+		return errors.Wrap(domain.ErrDomainAlreadyExists, "SetUserDomain: ")
+	case !errors.Is(err, domain.ErrUserNotFound):
+		return errors.Wrap(err, "SetUserDomain: GetByDomain: ")
+	}
+
+	domainToken, err := token.NewDomainToken()
+	if err != nil {
+		return errors.Wrap(err, "SetUserDomain: ")
+	}
+	err = s.userRepo.SetPendingDomain(ctx, userID, domainName, domainToken)
 	if err != nil {
-		return errors.Wrap(err, "DeleteCode: Delete: ")
+		return errors.Wrap(err, "SetUserDomain: SetPendingDomain: ")
 	}
 	return nil
 }
+
+// VerifyUserDomain activates pending domain of user if its DNS has TXT record of token.DomainVerificationRecord
+func (s CodeService) VerifyUserDomain(ctx context.Context, userID uint64) error {
+	user, err := s.userRepo.Get(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "VerifyUserDomain: Get: ")
+	}
+	if user.PendingDomain == "" {
+		return errors.Wrap(domain.ErrDomainNotPending, "VerifyUserDomain: ")
+	}
+
+	name, value := token.DomainVerificationRecord(user.PendingDomain, user.DomainToken)
+	records, err := s.lookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return errors.Wrap(domain.ErrDomainNotVerified, "VerifyUserDomain: no record")
+		}
+		return errors.Wrap(err, "VerifyUserDomain: lookupTXT: ")
+	}
+	verified := false
+	for _, r := range records {
+		if r == value {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return errors.Wrap(domain.ErrDomainNotVerified, "VerifyUserDomain: wrong record")
+	}
+
+	err = s.userRepo.SetDomain(ctx, userID, user.PendingDomain)
+	if err != nil {
+		return errors.Wrap(err, "VerifyUserDomain: SetDomain: ")
+	}
+	err = s.userRepo.SetPendingDomain(ctx, userID, "", "")
+	if err != nil {
+		return errors.Wrap(err, "VerifyUserDomain: SetPendingDomain: ")
+	}
+	s.log(ctx).Info().Str("event", "domain_verified").Uint64("user_id", userID).Str("domain", user.PendingDomain).Send()
+	return nil
+}
+
+// ownerCodeLink returns griz link of dynamic code on owner's domain
+func (s CodeService) ownerCodeLink(owner entities.User, code entities.Code) string {
+	hashToken := code.Hash
+	if code.Slug != "" {
+		hashToken = code.Slug
+	}
+	if owner.Domain != "" {
+		return s.linker.BuildLinkForDomain(owner.Domain, hashToken)
+	}
+	return s.linker.BuildLink(hashToken)
+}
+
+// ownerCodeContent returns content of QR: payload for static codes, griz link for dynamic ones
+func (s CodeService) ownerCodeContent(owner entities.User, code entities.Code) (string, error) {
+	if code.Type.IsStatic() {
+		return payload.Encode(code)
+	}
+	return s.ownerCodeLink(owner, code), nil
+}
+
+// codeLabel returns human readable name of code
+func codeLabel(code entities.Code) string {
+	switch {
+	case code.Name != "":
+		return code.Name
+	case code.Slug != "":
+		return code.Slug
+	}
+	return "code-" + strconv.FormatUint(code.ID, 10)
+}
+
+// hasTarget reports if code is resolved to link. Content of static codes is in code itself
+func hasTarget(code entities.Code) bool {
+	return !code.Type.IsStatic()
+}
+
+// codeTarget returns where dynamic code leads to
+func (s CodeService) codeTarget(code entities.Code) string {
+	if code.Paused {
+		return s.PausedURL(code)
+	}
+	if code.Type == entities.CodeTypeVCard {
+		return s.linker.BuildPageLink(code.Hash)
+	}
+	return code.SrcURL
+}
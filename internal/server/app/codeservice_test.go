@@ -0,0 +1,400 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/app/authtoken"
+	"github.com/hotafrika/griz-backend/internal/server/app/password"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrsheet"
+	"github.com/hotafrika/griz-backend/internal/server/app/token"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache/inmemory"
+	repos "github.com/hotafrika/griz-backend/internal/server/infrastructure/database/inmemory"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"image/color"
+	"image/png"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failCacher fails test on every call
+type failCacher struct {
+	t *testing.T
+}
+
+func (c failCacher) Get(_ context.Context, key fmt.Stringer) (string, error) {
+	c.t.Errorf("cache is read: %s", key)
+	return "", domain.ErrCacheNotExist
+}
+
+func (c failCacher) Set(_ context.Context, key fmt.Stringer, _ string, _ time.Duration) error {
+	c.t.Errorf("cache is written: %s", key)
+	return nil
+}
+
+func (c failCacher) Delete(_ context.Context, key fmt.Stringer) error {
+	c.t.Errorf("cache is deleted: %s", key)
+	return nil
+}
+
+// failCodeRepository fails test on lookups of codes. Other methods aren't expected
+type failCodeRepository struct {
+	domain.CodeRepository
+	t *testing.T
+}
+
+func (r failCodeRepository) Get(_ context.Context, id uint64) (entities.Code, error) {
+	r.t.Errorf("code %d is read", id)
+	return entities.Code{}, domain.ErrCodeNotFound
+}
+
+func (r failCodeRepository) GetByHash(_ context.Context, hash string) (entities.Code, error) {
+	r.t.Errorf("code %s is read", hash)
+	return entities.Code{}, domain.ErrCodeNotFound
+}
+
+func (r failCodeRepository) GetBySlug(_ context.Context, slug string) (entities.Code, error) {
+	r.t.Errorf("code %s is read", slug)
+	return entities.Code{}, domain.ErrCodeNotFound
+}
+
+func (r failCodeRepository) AddScans(_ context.Context, scans map[uint64]uint64) error {
+	r.t.Errorf("scans %v are recorded", scans)
+	return nil
+}
+
+func newTestKeyring(t *testing.T) token.Keyring {
+	legacy, err := token.NewAES("1234567812345678")
+	assert.NoError(t, err)
+	current, err := token.NewAuthAES("v02", []byte("12345678123456781234567812345678"))
+	assert.NoError(t, err)
+	keyring, err := token.NewKeyring(current, legacy)
+	assert.NoError(t, err)
+	return keyring
+}
+
+func newTestService(t *testing.T, cache domain.Cacher, codeRepo domain.CodeRepository) CodeService {
+	linker, err := token.NewLinker(token.DefaultBaseURL)
+	assert.NoError(t, err)
+	logger := zerolog.Nop()
+	return NewCodeService(time.Hour, time.Hour, time.Hour, &logger, cache, nil, codeRepo, nil, nil, nil, nil,
+		password.Encryptor{}, authtoken.JWT{}, newTestKeyring(t), linker, nil, "", DefaultMaintenancePage)
+}
+
+// flipBit changes last hex digit of token
+func flipBit(hash string) string {
+	last := hash[len(hash)-1]
+	flipped := "0"
+	if last == '0' {
+		flipped = "1"
+	}
+	return hash[:len(hash)-1] + flipped
+}
+
+func TestCodeService_FindCodeByHash_Forged(t *testing.T) {
+	s := newTestService(t, failCacher{t: t}, failCodeRepository{t: t})
+	keyring := newTestKeyring(t)
+	hash, err := keyring.Create(42)
+	assert.NoError(t, err)
+	legacyHash, err := token.NewAES("1234567812345678")
+	assert.NoError(t, err)
+	legacy, err := legacyHash.Create(42)
+	assert.NoError(t, err)
+
+	for _, valid := range []string{hash, legacy, "my-slug"} {
+		_, err = s.canonicalToken(valid)
+		assert.NoError(t, err, valid)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"bit flipped", flipBit(hash)},
+		{"bit flipped legacy", flipBit(legacy)},
+		{"uppercase hex", hash[:3] + strings.ToUpper(hash[3:])},
+		{"uppercase token", strings.ToUpper(hash)},
+		{"uppercase hex legacy", legacy[:3] + strings.ToUpper(legacy[3:])},
+		{"extra hex suffix", hash + "00"},
+		{"extra suffix", hash + "x"},
+		{"extra suffix legacy", legacy + "ab"},
+		{"unknown version", "v09" + hash[3:]},
+		{"invalid slug", "a--b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.FindCodeByHash(context.Background(), tt.token)
+			assert.True(t, errors.Is(err, domain.ErrCodeNotFound), "%v", err)
+		})
+	}
+}
+
+// testEnv is service with in-memory repositories and caches
+type testEnv struct {
+	s      CodeService
+	users  *repos.UserRepository
+	codes  *repos.CodeRepository
+	styles *repos.StyleRepository
+	images *inmemory.ImageCache
+	owner  uint64
+}
+
+func newTestEnv(t *testing.T) testEnv {
+	users := repos.NewUserRepository()
+	codes := repos.NewCodeRepository()
+	styles := repos.NewStyleRepository(codes, users)
+	images := inmemory.NewImageCache(1 << 20)
+	c := inmemory.NewCache()
+	t.Cleanup(func() {
+		images.Close()
+		c.Close()
+	})
+	linker, err := token.NewLinker(token.DefaultBaseURL)
+	assert.NoError(t, err)
+	logger := zerolog.Nop()
+	s := NewCodeService(time.Hour, time.Hour, time.Hour, &logger, c, images, codes, users, nil, styles, nil,
+		password.Encryptor{}, authtoken.JWT{}, newTestKeyring(t), linker, nil, "", DefaultMaintenancePage)
+	owner, err := users.Create(context.Background(), entities.User{Username: "owner"})
+	assert.NoError(t, err)
+	return testEnv{s: s, users: users, codes: codes, styles: styles, images: images, owner: owner}
+}
+
+// createCode creates dynamic code of owner with style
+func (e testEnv) createCode(t *testing.T, name string, styleID uint64) entities.Code {
+	ctx := context.Background()
+	id, err := e.s.CreateCode(ctx, entities.Code{UserID: e.owner, Type: entities.CodeTypeURL, SrcURL: "https://example.com/" + name, Name: name, StyleID: styleID})
+	assert.NoError(t, err)
+	code, err := e.codes.Get(ctx, id)
+	assert.NoError(t, err)
+	return code
+}
+
+func (e testEnv) createStyle(t *testing.T, fg, bg string) uint64 {
+	id, err := e.styles.CreateStyle(context.Background(), entities.Style{
+		UserID:          e.owner,
+		Foreground:      fg,
+		Background:      bg,
+		Shape:           string(qrencoder.ShapeSquare),
+		QuietZone:       qrencoder.QuietZone,
+		ErrorCorrection: string(qrencoder.ECLevelQuart),
+	})
+	assert.NoError(t, err)
+	return id
+}
+
+// zipFiles returns files of archive by name
+func zipFiles(t *testing.T, b []byte) map[string][]byte {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		data, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		files[f.Name] = data
+	}
+	return files
+}
+
+func TestCodeService_RenderCodes(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+	cream := color.RGBA{R: 0xff, G: 0xf8, B: 0xe1, A: 0xff}
+	plain := e.createCode(t, "plain", 0)
+	styled := e.createCode(t, "styled", e.createStyle(t, "#1a237e", "#fff8e1"))
+	ids := []uint64{plain.ID, styled.ID}
+
+	t.Run("png zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		sheet := qrsheet.NewZIP(&buf, qrencoder.DefaultYeqown(qrencoder.WithPNG()), "png")
+		assert.NoError(t, e.s.RenderCodes(ctx, e.owner, ids, sheet))
+		assert.NoError(t, sheet.Close())
+
+		files := zipFiles(t, buf.Bytes())
+		assert.Len(t, files, 2)
+		for name, code := range map[string]entities.Code{"plain.png": plain, "styled.png": styled} {
+			content, _ := e.s.ownerCodeContent(entities.User{ID: e.owner}, code)
+			got, err := qrdecoder.Makiuchi{}.Decode(ctx, files[name])
+			assert.NoError(t, err, name)
+			assert.Equal(t, content, string(got), name)
+		}
+		img, err := png.Decode(bytes.NewReader(files["styled.png"]))
+		if assert.NoError(t, err) {
+			assert.Equal(t, color.RGBAModel.Convert(cream), color.RGBAModel.Convert(img.At(1, 1)), "style of code")
+		}
+	})
+
+	t.Run("svg zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		sheet := qrsheet.NewZIP(&buf, qrencoder.NewSVG(10), "svg")
+		assert.NoError(t, e.s.RenderCodes(ctx, e.owner, ids, sheet))
+		assert.NoError(t, sheet.Close())
+
+		files := zipFiles(t, buf.Bytes())
+		assert.Len(t, files, 2)
+		assert.Contains(t, string(files["styled.svg"]), "#1a237e")
+		assert.NotContains(t, string(files["plain.svg"]), "#1a237e")
+	})
+
+	t.Run("pdf with styled code", func(t *testing.T) {
+		var buf bytes.Buffer
+		sheet, err := qrsheet.NewPDF(&buf, qrsheet.Layout{Page: qrsheet.PageA4, Columns: 2, Rows: 2})
+		assert.NoError(t, err)
+		err = e.s.RenderCodes(ctx, e.owner, ids, sheet)
+		assert.True(t, errors.Is(err, domain.ErrStyleNotSupported), "%v", err)
+		assert.Zero(t, buf.Len(), "nothing is written")
+
+		assert.NoError(t, e.s.RenderCodes(ctx, e.owner, []uint64{plain.ID}, sheet))
+	})
+
+	t.Run("pdf with default style", func(t *testing.T) {
+		assert.NoError(t, e.users.SetDefaultStyle(ctx, e.owner, e.createStyle(t, "#1b5e20", "#ffffff")))
+		defer e.users.SetDefaultStyle(ctx, e.owner, 0)
+		var buf bytes.Buffer
+		sheet, err := qrsheet.NewPDF(&buf, qrsheet.Layout{Page: qrsheet.PageA4, Columns: 2, Rows: 2})
+		assert.NoError(t, err)
+		assert.NoError(t, e.s.RenderCodes(ctx, e.owner, []uint64{plain.ID}, sheet), "default style is skipped")
+		assert.NoError(t, sheet.Close())
+		assert.NotZero(t, buf.Len())
+
+		err = e.s.RenderCodes(ctx, e.owner, ids, sheet)
+		assert.True(t, errors.Is(err, domain.ErrStyleNotSupported), "own style of code: %v", err)
+	})
+
+	t.Run("unscannable style", func(t *testing.T) {
+		invisible := e.createCode(t, "invisible", e.createStyle(t, "#ffffff", "#ffffff"))
+		var buf bytes.Buffer
+		sheet := qrsheet.NewZIP(&buf, qrencoder.DefaultYeqown(qrencoder.WithPNG()), "png")
+		err := e.s.RenderCodes(ctx, e.owner, []uint64{plain.ID, invisible.ID}, sheet)
+		assert.True(t, errors.Is(err, qrdecoder.ErrUnscannable), "%v", err)
+		assert.Zero(t, buf.Len(), "design is checked before rendering")
+	})
+}
+
+func TestCodeService_FindCodeByHash_Static(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+	id, err := e.s.CreateCode(ctx, entities.Code{UserID: e.owner, Type: entities.CodeTypeText, Payload: `{"text":"hello"}`})
+	assert.NoError(t, err)
+	code, err := e.codes.Get(ctx, id)
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		target, err := e.s.FindCodeByHash(ctx, code.Hash)
+		assert.True(t, errors.Is(err, domain.ErrCodeNotFound), "%v", err)
+		assert.Empty(t, target)
+	}
+	_, err = e.s.cache.Get(ctx, cache.HashUrl{Key: code.Hash})
+	assert.True(t, errors.Is(err, domain.ErrCacheNotExist), "empty target isn't cached: %v", err)
+	_, err = e.s.FindCodeByLink(ctx, e.s.linker.BuildLink(code.Hash))
+	assert.True(t, errors.Is(err, domain.ErrCodeNotFound), "%v", err)
+}
+
+// txtRecords resolves fixed TXT records
+func txtRecords(records map[string][]string) func(ctx context.Context, name string) ([]string, error) {
+	return func(ctx context.Context, name string) ([]string, error) {
+		r, ok := records[name]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return r, nil
+	}
+}
+
+func TestCodeService_VerifyUserDomain(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+	squatter, err := e.users.Create(ctx, entities.User{Username: "squatter"})
+	assert.NoError(t, err)
+	records := map[string][]string{}
+	e.s.lookupTXT = txtRecords(records)
+
+	// domain claimed first by other user doesn't lock out owner
+	assert.NoError(t, e.s.SetUserDomain(ctx, squatter, "QR.Shop.example"))
+	assert.NoError(t, e.s.SetUserDomain(ctx, e.owner, "qr.shop.example"))
+	_, err = e.users.GetByDomain(ctx, "qr.shop.example")
+	assert.True(t, errors.Is(err, domain.ErrUserNotFound), "pending domain isn't used: %v", err)
+	assert.True(t, errors.Is(CheckGrizLink(e.s.linker, e.users)(ctx, "https://qr.shop.example/my-slug"), token.ErrNotGrizLink))
+
+	err = e.s.VerifyUserDomain(ctx, squatter)
+	assert.True(t, errors.Is(err, domain.ErrDomainNotVerified), "%v", err)
+
+	owner, err := e.users.Get(ctx, e.owner)
+	assert.NoError(t, err)
+	name, value := token.DomainVerificationRecord(owner.PendingDomain, owner.DomainToken)
+	assert.Equal(t, "_griz-verification.qr.shop.example", name)
+	records[name] = []string{"v=spf1 -all", value}
+	assert.NoError(t, e.s.VerifyUserDomain(ctx, e.owner))
+
+	owner, err = e.users.Get(ctx, e.owner)
+	assert.NoError(t, err)
+	assert.Equal(t, "qr.shop.example", owner.Domain)
+	assert.Empty(t, owner.PendingDomain)
+	err = e.s.VerifyUserDomain(ctx, e.owner)
+	assert.True(t, errors.Is(err, domain.ErrDomainNotPending), "%v", err)
+
+	// record of other user's token doesn't verify squatter
+	err = e.s.VerifyUserDomain(ctx, squatter)
+	assert.True(t, errors.Is(err, domain.ErrDomainNotVerified), "%v", err)
+	err = e.s.SetUserDomain(ctx, squatter, "qr.shop.example")
+	assert.True(t, errors.Is(err, domain.ErrDomainAlreadyExists), "%v", err)
+
+	assert.NoError(t, e.s.SetUserDomain(ctx, e.owner, ""))
+	owner, _ = e.users.Get(ctx, e.owner)
+	assert.Empty(t, owner.Domain)
+}
+
+func TestCheckGrizLink(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+	_, err := e.users.Create(ctx, entities.User{Username: "shop", Domain: "qr.shop.example"})
+	assert.NoError(t, err)
+	check := CheckGrizLink(e.s.linker, e.users)
+
+	assert.NoError(t, check(ctx, token.DefaultBaseURL+"/my-slug"))
+	assert.NoError(t, check(ctx, "https://QR.shop.example/my-slug"), "custom domain of user")
+	for _, link := range []string{"https://paypal.me/griz", "https://example.com/my-slug", "not link"} {
+		err = check(ctx, link)
+		assert.True(t, errors.Is(err, token.ErrNotGrizLink), "%s: %v", link, err)
+	}
+}
+
+// brokenImageCache misses all images and fails writes
+type brokenImageCache struct{}
+
+func (brokenImageCache) GetImage(context.Context, fmt.Stringer) ([]byte, error) {
+	return nil, domain.ErrCacheNotExist
+}
+
+func (brokenImageCache) SetImage(context.Context, fmt.Stringer, []byte) error {
+	return errors.New("cache is full")
+}
+
+func (brokenImageCache) DeleteImage(context.Context, fmt.Stringer) error {
+	return nil
+}
+
+func TestCodeService_DownloadCode_CacheError(t *testing.T) {
+	e := newTestEnv(t)
+	e.s.imageCache = brokenImageCache{}
+	code := e.createCode(t, "plain", 0)
+
+	image, err := e.s.DownloadCode(context.Background(), code)
+	assert.NoError(t, err, "rendered image is returned without cache")
+	assert.NotEmpty(t, image.Data)
+	assert.NotEmpty(t, image.ETag)
+}
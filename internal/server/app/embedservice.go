@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/pkg/errors"
+)
+
+// Formats of public code images
+const (
+	EmbedPNG = "png"
+	EmbedSVG = "svg"
+)
+
+// EmbedSizes are widths of public images. Requested size is rounded down to one of them,
+// so every code has few cached images
+var EmbedSizes = []int{64, 128, 256, 512, 1024}
+
+// EmbedOptions are query parameters of public code image. They override style of code
+type EmbedOptions struct {
+	Format     string
+	Size       int    // max width of image in pixels, rounded down to EmbedSizes
+	Foreground string // #rrggbb, empty keeps color of style
+	Background string // #rrggbb, empty keeps color of style
+	QuietZone  *int   // nil keeps quiet zone of style
+}
+
+// defaultEmbedStyle is design of public images of codes without style
+var defaultEmbedStyle = entities.Style{
+	Foreground:      "#000000",
+	Background:      "#ffffff",
+	Shape:           string(qrencoder.ShapeSquare),
+	QuietZone:       qrencoder.QuietZone,
+	ErrorCorrection: string(qrencoder.ECLevelQuart),
+}
+
+// EmbedCode returns public image of code by hash or slug.
+// Paused codes are embedded too, their links lead to fallback.
+// Images with colors or quiet zone of caller aren't cached: their combinations are unbounded
+// and would evict images of owners from shared cache.
+// qrencoder.ErrTooSmall is returned if code doesn't fit in size,
+// qrdecoder.ErrUnscannable is returned if design with options couldn't be decoded.
+func (s CodeService) EmbedCode(ctx context.Context, hashToken string, options EmbedOptions) (CodeImage, error) {
+	options.Size = embedSize(options.Size)
+	overridden := options.Foreground != "" || options.Background != "" || options.QuietZone != nil
+
+	code, err := s.findByToken(ctx, hashToken)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "EmbedCode: findByToken: ")
+	}
+	if code.IsTrashed() {
+		return CodeImage{}, errors.Wrap(domain.ErrCodeDisabled, "EmbedCode: code is trashed")
+	}
+	if code.EmbedDisabled {
+		return CodeImage{}, errors.Wrap(domain.ErrEmbedDisabled, "EmbedCode: ")
+	}
+	owner, err := s.userRepo.Get(ctx, code.UserID)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "EmbedCode: Get owner: ")
+	}
+	content, err := s.ownerCodeContent(owner, code)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "EmbedCode: ownerCodeContent: ")
+	}
+	style, styled, err := s.codeStyle(ctx, owner, code)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "EmbedCode: codeStyle: ")
+	}
+	if !styled {
+		style = defaultEmbedStyle
+	}
+	if options.Foreground != "" {
+		style.Foreground = options.Foreground
+	}
+	if options.Background != "" {
+		style.Background = options.Background
+	}
+	if options.QuietZone != nil {
+		style.QuietZone = *options.QuietZone
+	}
+
+	fingerprint := embedFingerprint(renderFingerprint(content, style, true), options)
+	key := code.Hash + "_" + fingerprint
+	if !overridden {
+		if image, ok := s.cachedCodeImage(ctx, key); ok {
+			image.ETag = fingerprint
+			return image, nil
+		}
+	}
+
+	logo, logoImage, err := s.styleLogo(ctx, style)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "EmbedCode: styleLogo: ")
+	}
+	yeqownOptions, err := yeqownStyleOptions(style, logoImage)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "EmbedCode: ")
+	}
+	// SVG is checked by raster image of the same design
+	b, err := qrencoder.NewYeqown(append(yeqownOptions, qrencoder.WithPNG())...).EncodeFit([]byte(content), options.Size)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "EmbedCode: encode content: ")
+	}
+	report, err := s.qrDecoder.Check(b, content)
+	if err != nil {
+		return CodeImage{}, errors.Wrap(err, "EmbedCode: Check: ")
+	}
+	if options.Format == EmbedSVG {
+		svgOptions, err := svgStyleOptions(style, logo)
+		if err != nil {
+			return CodeImage{}, errors.Wrap(err, "EmbedCode: ")
+		}
+		b, err = qrencoder.NewSVG(1, append(svgOptions, qrencoder.WithSVGWidth(options.Size))...).Encode([]byte(content))
+		if err != nil {
+			return CodeImage{}, errors.Wrap(err, "EmbedCode: encode SVG: ")
+		}
+	}
+
+	image := CodeImage{Data: b, ETag: fingerprint, Report: report}
+	if !overridden {
+		s.cacheCodeImage(ctx, key, image)
+	}
+	return image, nil
+}
+
+// SetCodeEmbedding allows or forbids public image of code
+func (s CodeService) SetCodeEmbedding(ctx context.Context, code entities.Code, enabled bool) (entities.Code, error) {
+	code.EmbedDisabled = !enabled
+	err := s.codeRepo.Update(ctx, code)
+	if err != nil {
+		return code, errors.Wrap(err, "SetCodeEmbedding: Update: ")
+	}
+	return code, nil
+}
+
+// embedSize rounds size down to one of EmbedSizes. Smaller sizes get the smallest one
+func embedSize(size int) int {
+	res := EmbedSizes[0]
+	for _, s := range EmbedSizes {
+		if s <= size {
+			res = s
+		}
+	}
+	return res
+}
+
+// embedFingerprint identifies public image by fingerprint of its design and options
+func embedFingerprint(fingerprint string, options EmbedOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d", fingerprint, options.Format, options.Size)
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// countingImageCache counts written images
+type countingImageCache struct {
+	domain.ImageCacher
+	sets int
+}
+
+func (c *countingImageCache) SetImage(ctx context.Context, key fmt.Stringer, data []byte) error {
+	c.sets++
+	return c.ImageCacher.SetImage(ctx, key, data)
+}
+
+func TestEmbedSize(t *testing.T) {
+	tests := []struct {
+		size int
+		want int
+	}{
+		{10, 64},
+		{64, 64},
+		{200, 128},
+		{256, 256},
+		{1000, 512},
+		{5000, 1024},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, embedSize(tt.size), tt.size)
+	}
+}
+
+func TestCodeService_EmbedCode(t *testing.T) {
+	e := newTestEnv(t)
+	images := &countingImageCache{ImageCacher: e.images}
+	e.s.imageCache = images
+	ctx := context.Background()
+	code := e.createCode(t, "plain", 0)
+
+	first, err := e.s.EmbedCode(ctx, code.Hash, EmbedOptions{Format: EmbedPNG, Size: 300})
+	assert.NoError(t, err)
+	second, err := e.s.EmbedCode(ctx, code.Hash, EmbedOptions{Format: EmbedPNG, Size: 400})
+	assert.NoError(t, err)
+	assert.Equal(t, first.ETag, second.ETag, "sizes are rounded to the same width")
+	assert.Equal(t, 2, images.sets, "image and report are cached once")
+
+	svg, err := e.s.EmbedCode(ctx, code.Hash, EmbedOptions{Format: EmbedSVG, Size: 256})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.ETag, svg.ETag)
+	assert.Contains(t, string(svg.Data), "<svg")
+
+	margin := 1
+	for i := 0; i < 2; i++ {
+		image, err := e.s.EmbedCode(ctx, code.Hash, EmbedOptions{Format: EmbedPNG, Size: 256, Background: "#fff8e1", QuietZone: &margin})
+		assert.NoError(t, err)
+		assert.NotEqual(t, first.ETag, image.ETag)
+		img, err := png.Decode(bytes.NewReader(image.Data))
+		if assert.NoError(t, err) {
+			assert.Equal(t, color.RGBA{R: 0xff, G: 0xf8, B: 0xe1, A: 0xff}, color.RGBAModel.Convert(img.At(0, 0)), "background of caller")
+		}
+	}
+	assert.Equal(t, 4, images.sets, "images with overrides aren't cached")
+}
+
+func TestCodeService_EmbedCode_Errors(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+
+	disabled := e.createCode(t, "disabled", 0)
+	_, err := e.s.SetCodeEmbedding(ctx, disabled, false)
+	assert.NoError(t, err)
+	_, err = e.s.EmbedCode(ctx, disabled.Hash, EmbedOptions{Format: EmbedPNG, Size: 256})
+	assert.True(t, errors.Is(err, domain.ErrEmbedDisabled), "%v", err)
+
+	trashed := e.createCode(t, "trashed", 0)
+	assert.NoError(t, e.codes.Trash(ctx, trashed.ID, time.Now()))
+	_, err = e.s.EmbedCode(ctx, trashed.Hash, EmbedOptions{Format: EmbedPNG, Size: 256})
+	assert.True(t, errors.Is(err, domain.ErrCodeDisabled), "%v", err)
+
+	_, err = e.s.EmbedCode(ctx, "v01"+disabled.Hash[3:]+"00", EmbedOptions{Format: EmbedPNG, Size: 256})
+	assert.True(t, errors.Is(err, domain.ErrCodeNotFound), "%v", err)
+}
@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// probeCounter makes keys of concurrent cache probes unique
+var probeCounter uint64
+
+// CacheCheck writes, reads and deletes temporary value
+func CacheCheck(c domain.Cacher) CheckFunc {
+	return func(ctx context.Context) error {
+		n := atomic.AddUint64(&probeCounter, 1)
+		key := cache.HealthProbe{Key: strconv.FormatUint(n, 10)}
+		value := strconv.FormatInt(time.Now().UnixNano(), 10)
+		err := c.Set(ctx, key, value, time.Minute)
+		if err != nil {
+			return errors.Wrap(err, "unable to set value: ")
+		}
+		got, err := c.Get(ctx, key)
+		if err != nil {
+			return errors.Wrap(err, "unable to get value: ")
+		}
+		if got != value {
+			return errors.New("cache returned wrong value")
+		}
+		err = c.Delete(ctx, key)
+		if err != nil {
+			return errors.Wrap(err, "unable to delete value: ")
+		}
+		return nil
+	}
+}
+
+// SchemaCheck compares version of DB schema with version expected by service
+func SchemaCheck(current func(ctx context.Context) (int64, error), want int64) CheckFunc {
+	return func(ctx context.Context) error {
+		v, err := current(ctx)
+		if err != nil {
+			return errors.Wrap(err, "unable to get schema version: ")
+		}
+		if v != want {
+			return errors.Errorf("schema version is %d, expected %d", v, want)
+		}
+		return nil
+	}
+}
+
+// HTTPCheck requests url. Any response except 5xx means upstream is reachable
+func HTTPCheck(client *http.Client, url string) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return errors.Wrap(err, "unable to create request: ")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "unable to reach upstream: ")
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return errors.Errorf("upstream %s responded with %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
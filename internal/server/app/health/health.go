@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned for checks which are not finished in time
+var ErrTimeout = errors.New("check timed out")
+
+// CheckFunc checks one dependency of service. It has to respect ctx
+type CheckFunc func(ctx context.Context) error
+
+// Result is result of one check
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// Report is result of all checks
+type Report struct {
+	Ready   bool
+	Results []Result
+}
+
+type check struct {
+	name string
+	f    CheckFunc
+}
+
+// Checker runs readiness checks
+type Checker struct {
+	timeout time.Duration
+	maxAge  time.Duration
+	checks  []check
+
+	mu      sync.Mutex
+	last    Report
+	checked time.Time
+}
+
+// NewChecker creates Checker which gives each check up to timeout.
+// Report is reused for maxAge, so frequent probes don't load dependencies
+func NewChecker(timeout, maxAge time.Duration) *Checker {
+	return &Checker{timeout: timeout, maxAge: maxAge}
+}
+
+// Add registers check. Checks have to be added before first Check call
+func (c *Checker) Add(name string, f CheckFunc) {
+	c.checks = append(c.checks, check{name: name, f: f})
+}
+
+// Check returns report which isn't older than maxAge. Concurrent calls wait for one run of checks
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checked.IsZero() && time.Since(c.checked) < c.maxAge {
+		return c.last
+	}
+	report := c.checkAll(ctx)
+	// report of canceled request isn't result of dependencies
+	if ctx.Err() == nil {
+		c.last = report
+		c.checked = time.Now()
+	}
+	return report
+}
+
+// checkAll runs all checks concurrently. Results are in order of registration
+func (c *Checker) checkAll(ctx context.Context) Report {
+	report := Report{Ready: true, Results: make([]Result, len(c.checks))}
+	wg := sync.WaitGroup{}
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func(i int, ch check) {
+			defer wg.Done()
+			report.Results[i] = c.run(ctx, ch)
+		}(i, ch)
+	}
+	wg.Wait()
+	for _, r := range report.Results {
+		if r.Err != nil {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+// run runs check with timeout. Check which ignores ctx is abandoned after timeout
+func (c *Checker) run(ctx context.Context, ch check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- ch.f(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+		if err != nil && ctx.Err() != nil {
+			err = ErrTimeout
+		}
+	case <-ctx.Done():
+		err = ErrTimeout
+	}
+	return Result{Name: ch.name, Err: err, Duration: time.Since(start)}
+}
+
+// BuildInfo describes running binary
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	BuildDate string
+	GoVersion string
+}
+
+// NewBuildInfo returns info set by -ldflags. Version of main module is used when version isn't set
+func NewBuildInfo(version, commit, buildDate string) BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+	if info.Version == "" {
+		info.Version = "unknown"
+		if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+			info.Version = bi.Main.Version
+		}
+	}
+	return info
+}
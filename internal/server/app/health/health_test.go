@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache/inmemory"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChecker_Check(t *testing.T) {
+	errDown := errors.New("down")
+	tests := []struct {
+		name      string
+		check     CheckFunc
+		wantReady bool
+		wantErr   error
+	}{
+		{
+			name:      "passed",
+			check:     func(ctx context.Context) error { return nil },
+			wantReady: true,
+		},
+		{
+			name:    "failed",
+			check:   func(ctx context.Context) error { return errDown },
+			wantErr: errDown,
+		},
+		{
+			name: "respects timeout",
+			check: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			wantErr: ErrTimeout,
+		},
+		{
+			name: "ignores timeout",
+			check: func(ctx context.Context) error {
+				time.Sleep(time.Second)
+				return nil
+			},
+			wantErr: ErrTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(50*time.Millisecond, 0)
+			c.Add("other", func(ctx context.Context) error { return nil })
+			c.Add(tt.name, tt.check)
+
+			start := time.Now()
+			report := c.Check(context.Background())
+			assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+			assert.Equal(t, tt.wantReady, report.Ready)
+			if assert.Len(t, report.Results, 2) {
+				assert.Equal(t, "other", report.Results[0].Name)
+				assert.NoError(t, report.Results[0].Err)
+				assert.Equal(t, tt.name, report.Results[1].Name)
+				assert.Equal(t, tt.wantErr, report.Results[1].Err)
+			}
+		})
+	}
+}
+
+func TestChecker_Check_MaxAge(t *testing.T) {
+	var runs int32
+	c := NewChecker(time.Second, time.Hour)
+	c.Add("counted", func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, c.Check(context.Background()).Ready)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs), "report is reused")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	c = NewChecker(time.Second, time.Hour)
+	c.Add("canceled", func(ctx context.Context) error { return ctx.Err() })
+	assert.False(t, c.Check(canceled).Ready)
+	assert.True(t, c.Check(context.Background()).Ready, "report of canceled request isn't reused")
+}
+
+func TestCacheCheck(t *testing.T) {
+	c := inmemory.NewCache()
+	assert.NoError(t, CacheCheck(c)(context.Background()))
+}
+
+func TestSchemaCheck(t *testing.T) {
+	version := func(v int64) func(ctx context.Context) (int64, error) {
+		return func(ctx context.Context) (int64, error) { return v, nil }
+	}
+	assert.NoError(t, SchemaCheck(version(20211215142207), 20211215142207)(context.Background()))
+	assert.Error(t, SchemaCheck(version(20211118121627), 20211215142207)(context.Background()))
+}
+
+func TestHTTPCheck(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	check := HTTPCheck(srv.Client(), srv.URL)
+	assert.NoError(t, check(context.Background()))
+	status = http.StatusNotFound
+	assert.NoError(t, check(context.Background()), "upstream is reachable")
+	status = http.StatusBadGateway
+	assert.Error(t, check(context.Background()))
+}
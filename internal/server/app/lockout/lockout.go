@@ -0,0 +1,328 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLocked is cause of LockedError
+var ErrLocked = errors.New("login is locked")
+
+// Kinds of counters
+const (
+	KindUsername = "username"
+	KindIP       = "ip"
+)
+
+// LockedError is returned for login which has to wait for backoff or lockout
+type LockedError struct {
+	// Kind is counter which blocks login: KindUsername or KindIP
+	Kind string
+	// Locked is true for lockout after threshold, false for backoff
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("login is locked by %s for %s", e.Kind, e.RetryAfter)
+}
+
+// Unwrap allows errors.Is(err, ErrLocked)
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// Policy of failed attempts of one counter
+type Policy struct {
+	// FreeAttempts are failures without delay
+	FreeAttempts int
+	// Threshold is number of failures which locks login
+	Threshold int
+	// Backoff is delay after first failure over free attempts. It is doubled by every next failure
+	Backoff time.Duration
+	// Lockout is time of lock after threshold. Backoff doesn't exceed it.
+	// Failures are forgotten after Lockout without failures.
+	Lockout time.Duration
+}
+
+// NewPolicy creates Policy where backoff starts after third of threshold
+func NewPolicy(threshold int, backoff, lockout time.Duration) Policy {
+	return Policy{
+		FreeAttempts: threshold / 3,
+		Threshold:    threshold,
+		Backoff:      backoff,
+		Lockout:      lockout,
+	}
+}
+
+// delay returns time to wait after failures
+func (p Policy) delay(failures int) time.Duration {
+	if failures >= p.Threshold {
+		return p.Lockout
+	}
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	d := p.Backoff
+	for i := 1; i < over && d < p.Lockout; i++ {
+		d *= 2
+	}
+	if d > p.Lockout {
+		d = p.Lockout
+	}
+	return d
+}
+
+// State is counter of failed logins
+type State struct {
+	Failures int
+	// Last is time of last failure
+	Last time.Time
+	// Until is time before which login is blocked
+	Until time.Time
+	// Locked is true after threshold
+	Locked bool
+}
+
+// Guard counts failed logins by username and client IP.
+// Counters are kept in cache, so all instances with shared cache see them.
+// Successful login resets counter of username only, else attacker could reset counter of IP by own account.
+type Guard struct {
+	// mu serializes read-modify-write of counters of this instance
+	mu       sync.Mutex
+	cache    domain.Cacher
+	username Policy
+	ip       Policy
+	now      func() time.Time
+}
+
+// NewGuard creates Guard with policies of username and IP counters
+func NewGuard(cache domain.Cacher, username, ip Policy) *Guard {
+	return &Guard{
+		cache:    cache,
+		username: username,
+		ip:       ip,
+		now:      time.Now,
+	}
+}
+
+// Check returns LockedError if login of username from ip has to wait.
+// Allowed attempt whose failure would be delayed reserves the delay at once, so parallel attempts
+// wait as if it failed. Reservation is replaced by Fail and released by Succeed or Release.
+func (g *Guard) Check(ctx context.Context, username, ip string) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	counters := g.counters(username, ip)
+	states := make([]State, len(counters))
+	for i, c := range counters {
+		s, err := g.get(ctx, c.key, c.policy, now)
+		if err != nil {
+			return errors.Wrap(err, "Check: ")
+		}
+		if now.Before(s.Until) {
+			return &LockedError{Kind: c.kind, Locked: s.Locked, RetryAfter: s.Until.Sub(now)}
+		}
+		states[i] = s
+	}
+	for i, c := range counters {
+		d := c.policy.delay(states[i].Failures + 1)
+		if d <= 0 {
+			continue
+		}
+		states[i].Until = now.Add(d)
+		err := g.set(ctx, c.key, c.policy, states[i], now)
+		if err != nil {
+			return errors.Wrap(err, "Check: ")
+		}
+	}
+	return nil
+}
+
+// Fail records failed login and returns counters of username and IP after it
+func (g *Guard) Fail(ctx context.Context, username, ip string) (user State, client State, err error) {
+	if g == nil {
+		return State{}, State{}, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	states := make([]State, 0, 2)
+	for _, c := range g.counters(username, ip) {
+		s, err := g.get(ctx, c.key, c.policy, now)
+		if err != nil {
+			return State{}, State{}, errors.Wrap(err, "Fail: ")
+		}
+		s.Failures++
+		s.Last = now
+		s.Until = now.Add(c.policy.delay(s.Failures))
+		s.Locked = s.Failures >= c.policy.Threshold
+		err = g.set(ctx, c.key, c.policy, s, now)
+		if err != nil {
+			return State{}, State{}, errors.Wrap(err, "Fail: ")
+		}
+		states = append(states, s)
+	}
+	return states[0], states[1], nil
+}
+
+// Succeed resets counter of username and releases reservation of IP after successful login
+func (g *Guard) Succeed(ctx context.Context, username, ip string) error {
+	if g == nil {
+		return nil
+	}
+	err := g.Unlock(ctx, username, "")
+	if err != nil {
+		return errors.Wrap(err, "Succeed: ")
+	}
+	return g.Release(ctx, "", ip)
+}
+
+// Release releases reservations of attempt which finished without result, e.g. by error of DB.
+// Failures are kept. Empty values are skipped
+func (g *Guard) Release(ctx context.Context, username, ip string) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	for _, c := range g.counters(username, ip) {
+		if c.value == "" {
+			continue
+		}
+		s, err := g.get(ctx, c.key, c.policy, now)
+		if err != nil {
+			return errors.Wrap(err, "Release: ")
+		}
+		switch {
+		case s.Locked:
+			continue
+		case s.Failures == 0:
+			err = g.delete(ctx, c.key)
+		default:
+			s.Until = s.Last.Add(c.policy.delay(s.Failures))
+			err = g.set(ctx, c.key, c.policy, s, now)
+		}
+		if err != nil {
+			return errors.Wrap(err, "Release: ")
+		}
+	}
+	return nil
+}
+
+// Unlock resets counters of username and IP. Empty values are skipped
+func (g *Guard) Unlock(ctx context.Context, username, ip string) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, c := range g.counters(username, ip) {
+		if c.value == "" {
+			continue
+		}
+		err := g.delete(ctx, c.key)
+		if err != nil {
+			return errors.Wrap(err, "Unlock: ")
+		}
+	}
+	return nil
+}
+
+type counter struct {
+	kind   string
+	value  string
+	key    cache.LoginFailures
+	policy Policy
+}
+
+func (g *Guard) counters(username, ip string) []counter {
+	return []counter{
+		{kind: KindUsername, value: username, key: cache.LoginFailures{Key: KindUsername + "_" + username}, policy: g.username},
+		{kind: KindIP, value: ip, key: cache.LoginFailures{Key: KindIP + "_" + ip}, policy: g.ip},
+	}
+}
+
+// get returns counter. Expired lock and failures older than lockout are forgotten
+func (g *Guard) get(ctx context.Context, key cache.LoginFailures, p Policy, now time.Time) (State, error) {
+	value, err := g.cache.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, domain.ErrCacheNotExist) {
+			return State{}, nil
+		}
+		return State{}, errors.Wrap(err, "get cache: ")
+	}
+	s, err := parseState(value)
+	if err != nil {
+		return State{}, err
+	}
+	if s.Locked && !now.Before(s.Until) {
+		return State{}, nil
+	}
+	// unlocked failures are forgotten after lockout, reservation is kept until it expires
+	if !s.Locked && now.Sub(s.Last) >= p.Lockout && !now.Before(s.Until) {
+		return State{}, nil
+	}
+	return s, nil
+}
+
+func (g *Guard) set(ctx context.Context, key cache.LoginFailures, p Policy, s State, now time.Time) error {
+	ttl := s.Until.Sub(now) + p.Lockout
+	if ttl <= 0 {
+		return g.delete(ctx, key)
+	}
+	err := g.cache.Set(ctx, key, formatState(s), ttl)
+	if err != nil {
+		return errors.Wrap(err, "set cache: ")
+	}
+	return nil
+}
+
+func (g *Guard) delete(ctx context.Context, key cache.LoginFailures) error {
+	err := g.cache.Delete(ctx, key)
+	if err != nil {
+		return errors.Wrap(err, "delete cache: ")
+	}
+	return nil
+}
+
+// formatState returns state as "failures last until locked" with times in unix nanoseconds
+func formatState(s State) string {
+	return fmt.Sprintf("%d %d %d %t", s.Failures, s.Last.UnixNano(), s.Until.UnixNano(), s.Locked)
+}
+
+func parseState(value string) (State, error) {
+	parts := strings.Fields(value)
+	if len(parts) != 4 {
+		return State{}, errors.Errorf("invalid login failures %q", value)
+	}
+	failures, err1 := strconv.Atoi(parts[0])
+	last, err2 := strconv.ParseInt(parts[1], 10, 64)
+	until, err3 := strconv.ParseInt(parts[2], 10, 64)
+	locked, err4 := strconv.ParseBool(parts[3])
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return State{}, errors.Errorf("invalid login failures %q", value)
+	}
+	return State{
+		Failures: failures,
+		Last:     time.Unix(0, last),
+		Until:    time.Unix(0, until),
+		Locked:   locked,
+	}, nil
+}
@@ -0,0 +1,193 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache/inmemory"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// clock is fake time of Guard
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newGuard(username, ip Policy) (*Guard, *clock) {
+	c := &clock{now: time.Unix(1640000000, 0)}
+	g := NewGuard(inmemory.NewCache(), username, ip)
+	g.now = c.Now
+	return g, c
+}
+
+// lockedFor returns RetryAfter of LockedError or zero for allowed login
+func lockedFor(t *testing.T, err error) time.Duration {
+	if err == nil {
+		return 0
+	}
+	var locked *LockedError
+	if assert.True(t, errors.As(err, &locked), err.Error()) {
+		assert.True(t, errors.Is(err, ErrLocked))
+		return locked.RetryAfter
+	}
+	return 0
+}
+
+func TestPolicy_delay(t *testing.T) {
+	p := NewPolicy(10, time.Second, 15*time.Minute)
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, 15 * time.Minute}
+	for i, d := range want {
+		assert.Equal(t, d, p.delay(i+1), "failures %d", i+1)
+	}
+
+	capped := Policy{Threshold: 100, Backoff: time.Second, Lockout: time.Minute}
+	assert.Equal(t, time.Minute, capped.delay(50))
+}
+
+func TestGuard_Backoff(t *testing.T) {
+	g, c := newGuard(NewPolicy(10, time.Second, 15*time.Minute), NewPolicy(100, time.Second, 15*time.Minute))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, g.Check(ctx, "user1", "203.0.113.7"))
+		_, _, err := g.Fail(ctx, "user1", "203.0.113.7")
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, g.Check(ctx, "user1", "203.0.113.7"), "free attempts")
+
+	user, _, err := g.Fail(ctx, "user1", "203.0.113.7")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, user.Failures)
+	assert.False(t, user.Locked)
+	assert.Equal(t, time.Second, lockedFor(t, g.Check(ctx, "user1", "198.51.100.1")), "username is delayed from other IP")
+	assert.NoError(t, g.Check(ctx, "user2", "203.0.113.7"), "other usernames from IP are not delayed")
+
+	c.Advance(time.Second)
+	assert.NoError(t, g.Check(ctx, "user1", "203.0.113.7"))
+	_, _, _ = g.Fail(ctx, "user1", "203.0.113.7")
+	assert.Equal(t, 2*time.Second, lockedFor(t, g.Check(ctx, "user1", "203.0.113.7")), "delay is doubled")
+
+	c.Advance(2 * time.Second)
+	assert.NoError(t, g.Succeed(ctx, "user1", "203.0.113.7"))
+	assert.NoError(t, g.Check(ctx, "user1", "203.0.113.7"))
+	user, client, _ := g.Fail(ctx, "user1", "203.0.113.7")
+	assert.Equal(t, 1, user.Failures, "success resets username")
+	assert.Equal(t, 6, client.Failures, "success doesn't reset IP")
+}
+
+func TestGuard_ParallelAttempts(t *testing.T) {
+	g, c := newGuard(NewPolicy(10, time.Second, 15*time.Minute), NewPolicy(100, time.Second, 15*time.Minute))
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		_, _, _ = g.Fail(ctx, "user1", "203.0.113.7")
+	}
+	c.Advance(time.Second)
+
+	// parallel attempts after backoff: only one is allowed until its result
+	var allowed int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if g.Check(ctx, "user1", fmt.Sprintf("198.51.100.%d", i)) == nil {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), allowed)
+	assert.Equal(t, 2*time.Second, lockedFor(t, g.Check(ctx, "user1", "203.0.113.7")), "backoff of next failure is reserved")
+
+	user, _, err := g.Fail(ctx, "user1", "198.51.100.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, user.Failures)
+	assert.Equal(t, 2*time.Second, lockedFor(t, g.Check(ctx, "user1", "203.0.113.7")))
+
+	c.Advance(2 * time.Second)
+	assert.NoError(t, g.Check(ctx, "user1", "203.0.113.7"))
+	assert.NoError(t, g.Release(ctx, "user1", "203.0.113.7"))
+	assert.NoError(t, g.Check(ctx, "user1", "203.0.113.7"), "released attempt doesn't delay")
+	assert.NoError(t, g.Succeed(ctx, "user1", "203.0.113.7"))
+	user, _, _ = g.Fail(ctx, "user1", "203.0.113.7")
+	assert.Equal(t, 1, user.Failures)
+}
+
+func TestGuard_Lockout(t *testing.T) {
+	g, c := newGuard(NewPolicy(3, time.Second, 15*time.Minute), NewPolicy(100, time.Second, 15*time.Minute))
+	ctx := context.Background()
+
+	var user State
+	for i := 0; i < 3; i++ {
+		c.Advance(time.Minute)
+		user, _, _ = g.Fail(ctx, "user1", "203.0.113.7")
+	}
+	assert.True(t, user.Locked)
+	err := g.Check(ctx, "user1", "203.0.113.7")
+	assert.Equal(t, 15*time.Minute, lockedFor(t, err))
+	var locked *LockedError
+	if errors.As(err, &locked) {
+		assert.Equal(t, KindUsername, locked.Kind)
+		assert.True(t, locked.Locked)
+	}
+
+	c.Advance(14 * time.Minute)
+	assert.Equal(t, time.Minute, lockedFor(t, g.Check(ctx, "user1", "203.0.113.7")))
+
+	c.Advance(time.Minute)
+	assert.NoError(t, g.Check(ctx, "user1", "203.0.113.7"), "lock expires")
+	user, _, _ = g.Fail(ctx, "user1", "203.0.113.7")
+	assert.Equal(t, 1, user.Failures, "expired lock starts new count")
+}
+
+func TestGuard_IPLockout(t *testing.T) {
+	g, _ := newGuard(NewPolicy(100, time.Second, time.Hour), Policy{Threshold: 3, Backoff: time.Second, Lockout: time.Hour})
+	ctx := context.Background()
+
+	// spraying of many usernames from one IP
+	var client State
+	for _, username := range []string{"alice", "bob", "carol"} {
+		_, client, _ = g.Fail(ctx, username, "203.0.113.7")
+	}
+	assert.True(t, client.Locked)
+	err := g.Check(ctx, "dave", "203.0.113.7")
+	var locked *LockedError
+	if assert.True(t, errors.As(err, &locked)) {
+		assert.Equal(t, KindIP, locked.Kind)
+	}
+	assert.NoError(t, g.Check(ctx, "dave", "198.51.100.1"))
+
+	assert.NoError(t, g.Unlock(ctx, "", "203.0.113.7"))
+	assert.NoError(t, g.Check(ctx, "dave", "203.0.113.7"), "admin unlock")
+}
+
+func TestGuard_ForgetsOldFailures(t *testing.T) {
+	g, c := newGuard(NewPolicy(10, time.Second, 15*time.Minute), NewPolicy(100, time.Second, 15*time.Minute))
+	ctx := context.Background()
+
+	_, _, _ = g.Fail(ctx, "user1", "203.0.113.7")
+	_, _, _ = g.Fail(ctx, "user1", "203.0.113.7")
+	c.Advance(15 * time.Minute)
+	user, client, _ := g.Fail(ctx, "user1", "203.0.113.7")
+	assert.Equal(t, 1, user.Failures)
+	assert.Equal(t, 1, client.Failures)
+}
+
+func TestGuard_Nil(t *testing.T) {
+	var g *Guard
+	ctx := context.Background()
+	assert.NoError(t, g.Check(ctx, "user1", "203.0.113.7"))
+	_, _, err := g.Fail(ctx, "user1", "203.0.113.7")
+	assert.NoError(t, err)
+	assert.NoError(t, g.Succeed(ctx, "user1", "203.0.113.7"))
+	assert.NoError(t, g.Release(ctx, "user1", "203.0.113.7"))
+	assert.NoError(t, g.Unlock(ctx, "user1", ""))
+}
@@ -0,0 +1,63 @@
+package payload
+
+import (
+	"encoding/json"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownType is returned for types without payload
+var ErrUnknownType = errors.New("code type has no payload")
+
+// Payload is a typed content of QR code
+type Payload interface {
+	// Validate checks fields of payload
+	Validate() error
+	// Encode returns string which is put into QR (or hosted page)
+	Encode() string
+}
+
+// New returns empty payload for code type
+func New(t entities.CodeType) (Payload, error) {
+	switch t {
+	case entities.CodeTypeVCard:
+		return &VCard{}, nil
+	case entities.CodeTypeWiFi:
+		return &WiFi{}, nil
+	case entities.CodeTypeEmail:
+		return &Email{}, nil
+	case entities.CodeTypeSMS:
+		return &SMS{}, nil
+	case entities.CodeTypeGeo:
+		return &Geo{}, nil
+	case entities.CodeTypeText:
+		return &Text{}, nil
+	}
+	return nil, ErrUnknownType
+}
+
+// Parse deserializes JSON payload of code type and validates it
+func Parse(t entities.CodeType, raw []byte) (Payload, error) {
+	p, err := New(t)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(raw, p)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshal payload: ")
+	}
+	err = p.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "validate payload: ")
+	}
+	return p, nil
+}
+
+// Encode builds QR content from code payload
+func Encode(code entities.Code) (string, error) {
+	p, err := Parse(code.Type, []byte(code.Payload))
+	if err != nil {
+		return "", err
+	}
+	return p.Encode(), nil
+}
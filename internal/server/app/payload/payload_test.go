@@ -0,0 +1,127 @@
+package payload
+
+import (
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		codeType   entities.CodeType
+		raw        string
+		wantErr    bool
+		wantString string
+	}{
+		{
+			name:       "vcard",
+			codeType:   entities.CodeTypeVCard,
+			raw:        `{"first_name":"John","last_name":"Doe","organization":"Griz, Inc.","phone":"+1 (555) 123-45","email":"john@example.com"}`,
+			wantErr:    false,
+			wantString: "BEGIN:VCARD\r\nVERSION:3.0\r\nN:Doe;John;;;\r\nFN:John Doe\r\nORG:Griz\\, Inc.\r\nTEL;TYPE=CELL:+155512345\r\nEMAIL:john@example.com\r\nEND:VCARD",
+		},
+		{
+			name:     "vcard without name",
+			codeType: entities.CodeTypeVCard,
+			raw:      `{"phone":"+155512345"}`,
+			wantErr:  true,
+		},
+		{
+			name:       "wifi wpa",
+			codeType:   entities.CodeTypeWiFi,
+			raw:        `{"ssid":"home;net","password":"pass:word","encryption":"WPA","hidden":true}`,
+			wantErr:    false,
+			wantString: `WIFI:T:WPA;S:home\;net;P:pass\:word;H:true;;`,
+		},
+		{
+			name:       "wifi open",
+			codeType:   entities.CodeTypeWiFi,
+			raw:        `{"ssid":"cafe"}`,
+			wantErr:    false,
+			wantString: `WIFI:T:nopass;S:cafe;;`,
+		},
+		{
+			name:     "wifi short password",
+			codeType: entities.CodeTypeWiFi,
+			raw:      `{"ssid":"home","password":"123","encryption":"WPA"}`,
+			wantErr:  true,
+		},
+		{
+			name:       "email",
+			codeType:   entities.CodeTypeEmail,
+			raw:        `{"to":"john@example.com","subject":"Hello there","body":"a&b"}`,
+			wantErr:    false,
+			wantString: "mailto:john@example.com?subject=Hello%20there&body=a%26b",
+		},
+		{
+			name:     "email wrong address",
+			codeType: entities.CodeTypeEmail,
+			raw:      `{"to":"John <john@example.com>"}`,
+			wantErr:  true,
+		},
+		{
+			name:       "sms",
+			codeType:   entities.CodeTypeSMS,
+			raw:        `{"phone":"+1 555-123-45","message":"hi"}`,
+			wantErr:    false,
+			wantString: "SMSTO:+155512345:hi",
+		},
+		{
+			name:     "sms wrong phone",
+			codeType: entities.CodeTypeSMS,
+			raw:      `{"phone":"call me"}`,
+			wantErr:  true,
+		},
+		{
+			name:       "geo",
+			codeType:   entities.CodeTypeGeo,
+			raw:        `{"latitude":40.7128,"longitude":-74.006}`,
+			wantErr:    false,
+			wantString: "geo:40.7128,-74.006",
+		},
+		{
+			name:     "geo out of range",
+			codeType: entities.CodeTypeGeo,
+			raw:      `{"latitude":91,"longitude":0}`,
+			wantErr:  true,
+		},
+		{
+			name:       "text",
+			codeType:   entities.CodeTypeText,
+			raw:        `{"text":"hello"}`,
+			wantErr:    false,
+			wantString: "hello",
+		},
+		{
+			name:     "empty text",
+			codeType: entities.CodeTypeText,
+			raw:      `{"text":""}`,
+			wantErr:  true,
+		},
+		{
+			name:     "url has no payload",
+			codeType: entities.CodeTypeURL,
+			raw:      `{}`,
+			wantErr:  true,
+		},
+		{
+			name:     "broken json",
+			codeType: entities.CodeTypeText,
+			raw:      `{"text":`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.codeType, []byte(tt.raw))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantString, p.Encode())
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package payload
+
+import (
+	"github.com/pkg/errors"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxTextLen = 1000
+
+var phoneValidator = regexp.MustCompile(`^\+?[0-9]{3,15}$`)
+
+// VCard is a contact card (vCard 3.0)
+type VCard struct {
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Organization string `json:"organization,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Website      string `json:"website,omitempty"`
+	Address      string `json:"address,omitempty"`
+	Note         string `json:"note,omitempty"`
+}
+
+// Validate ...
+func (v VCard) Validate() error {
+	if v.FirstName == "" && v.LastName == "" && v.Organization == "" {
+		return errors.New("name or organization is required")
+	}
+	if v.Phone != "" && !phoneValidator.MatchString(normalizePhone(v.Phone)) {
+		return errors.New("phone has wrong format")
+	}
+	if v.Email != "" {
+		if _, err := mail.ParseAddress(v.Email); err != nil {
+			return errors.Wrap(err, "email: ")
+		}
+	}
+	if v.Website != "" {
+		if _, err := url.ParseRequestURI(v.Website); err != nil {
+			return errors.Wrap(err, "website: ")
+		}
+	}
+	if utf8.RuneCountInString(v.Note) > maxTextLen {
+		return errors.New("note is too long")
+	}
+	return nil
+}
+
+// Encode returns vCard text
+func (v VCard) Encode() string {
+	var b strings.Builder
+	line := func(name, value string) {
+		if value == "" {
+			return
+		}
+		b.WriteString(name + ":" + value + "\r\n")
+	}
+	b.WriteString("BEGIN:VCARD\r\nVERSION:3.0\r\n")
+	b.WriteString("N:" + escapeVCard(v.LastName) + ";" + escapeVCard(v.FirstName) + ";;;\r\n")
+	fn := strings.TrimSpace(v.FirstName + " " + v.LastName)
+	if fn == "" {
+		fn = v.Organization
+	}
+	line("FN", escapeVCard(fn))
+	line("ORG", escapeVCard(v.Organization))
+	line("TITLE", escapeVCard(v.Title))
+	line("TEL;TYPE=CELL", normalizePhone(v.Phone))
+	line("EMAIL", escapeVCard(v.Email))
+	line("URL", escapeVCard(v.Website))
+	if v.Address != "" {
+		line("ADR", ";;"+escapeVCard(v.Address)+";;;;")
+	}
+	line("NOTE", escapeVCard(v.Note))
+	b.WriteString("END:VCARD")
+	return b.String()
+}
+
+// WiFi encryption types
+const (
+	WiFiWPA    = "WPA"
+	WiFiWEP    = "WEP"
+	WiFiNoPass = "nopass"
+)
+
+// WiFi is a network configuration
+type WiFi struct {
+	SSID       string `json:"ssid"`
+	Password   string `json:"password,omitempty"`
+	Encryption string `json:"encryption"`
+	Hidden     bool   `json:"hidden,omitempty"`
+}
+
+// Validate ...
+func (w WiFi) Validate() error {
+	if w.SSID == "" || len(w.SSID) > 32 {
+		return errors.New("ssid has to be 1-32 bytes long")
+	}
+	switch w.Encryption {
+	case WiFiWPA:
+		if len(w.Password) < 8 || len(w.Password) > 63 {
+			return errors.New("WPA password has to be 8-63 symbols long")
+		}
+	case WiFiWEP:
+		if l := len(w.Password); l != 5 && l != 10 && l != 13 && l != 26 {
+			return errors.New("WEP password has to be 5, 10, 13 or 26 symbols long")
+		}
+	case WiFiNoPass, "":
+		if w.Password != "" {
+			return errors.New("password is set for open network")
+		}
+	default:
+		return errors.New("unknown encryption")
+	}
+	return nil
+}
+
+// Encode returns WIFI: string
+func (w WiFi) Encode() string {
+	enc := w.Encryption
+	if enc == "" {
+		enc = WiFiNoPass
+	}
+	s := "WIFI:T:" + enc + ";S:" + escapeWiFi(w.SSID) + ";"
+	if w.Password != "" {
+		s += "P:" + escapeWiFi(w.Password) + ";"
+	}
+	if w.Hidden {
+		s += "H:true;"
+	}
+	return s + ";"
+}
+
+// Email is a prepared letter
+type Email struct {
+	To      string `json:"to"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body,omitempty"`
+}
+
+// Validate ...
+func (e Email) Validate() error {
+	addr, err := mail.ParseAddress(e.To)
+	if err != nil {
+		return errors.Wrap(err, "to: ")
+	}
+	if addr.Address != e.To {
+		return errors.New("to has to be plain address")
+	}
+	if utf8.RuneCountInString(e.Subject)+utf8.RuneCountInString(e.Body) > maxTextLen {
+		return errors.New("subject and body are too long")
+	}
+	return nil
+}
+
+// Encode returns mailto: link
+func (e Email) Encode() string {
+	q := make([]string, 0, 2)
+	if e.Subject != "" {
+		q = append(q, "subject="+queryEscape(e.Subject))
+	}
+	if e.Body != "" {
+		q = append(q, "body="+queryEscape(e.Body))
+	}
+	s := "mailto:" + e.To
+	if len(q) > 0 {
+		s += "?" + strings.Join(q, "&")
+	}
+	return s
+}
+
+// queryEscape escapes value of mailto query. Spaces are %20, mail clients don't decode +
+func queryEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// SMS is a prepared message
+type SMS struct {
+	Phone   string `json:"phone"`
+	Message string `json:"message,omitempty"`
+}
+
+// Validate ...
+func (s SMS) Validate() error {
+	if !phoneValidator.MatchString(normalizePhone(s.Phone)) {
+		return errors.New("phone has wrong format")
+	}
+	if utf8.RuneCountInString(s.Message) > maxTextLen {
+		return errors.New("message is too long")
+	}
+	return nil
+}
+
+// Encode returns SMSTO: string
+func (s SMS) Encode() string {
+	return "SMSTO:" + normalizePhone(s.Phone) + ":" + s.Message
+}
+
+// Geo is a location
+type Geo struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Validate ...
+func (g Geo) Validate() error {
+	if g.Latitude < -90 || g.Latitude > 90 {
+		return errors.New("latitude has to be between -90 and 90")
+	}
+	if g.Longitude < -180 || g.Longitude > 180 {
+		return errors.New("longitude has to be between -180 and 180")
+	}
+	return nil
+}
+
+// Encode returns geo: URI
+func (g Geo) Encode() string {
+	return "geo:" + strconv.FormatFloat(g.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(g.Longitude, 'f', -1, 64)
+}
+
+// Text is a plain text
+type Text struct {
+	Text string `json:"text"`
+}
+
+// Validate ...
+func (t Text) Validate() error {
+	if t.Text == "" {
+		return errors.New("text is empty")
+	}
+	if utf8.RuneCountInString(t.Text) > maxTextLen {
+		return errors.New("text is too long")
+	}
+	return nil
+}
+
+// Encode returns text as is
+func (t Text) Encode() string {
+	return t.Text
+}
+
+func normalizePhone(s string) string {
+	return strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(s)
+}
+
+func escapeVCard(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func escapeWiFi(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, ":", `\:`, `"`, `\"`).Replace(s)
+}
@@ -2,32 +2,48 @@ package qrdecoder
 
 import (
 	"bytes"
-	"github.com/makiuchi-d/gozxing"
-	"github.com/makiuchi-d/gozxing/qrcode"
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/tracing"
 	"github.com/pkg/errors"
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
 )
 
+// MaxPixels limits dimensions of decoded images. Small compressed file could declare huge image
+// which takes gigabytes of memory after decoding. Image of 16 MP takes 64 MB as RGBA and about as much
+// in binarizer, so default 16 QR_WORKERS stay within 2 GB. Photos of posts are below 2 MP
+const MaxPixels = 16 << 20
+
+// ErrImageTooLarge is returned for images with more than MaxPixels
+var ErrImageTooLarge = errors.New("image is too large")
+
 // Makiuchi is a qr decoder of Makiuchi user
 type Makiuchi struct {
 }
 
 // Decode decodes slice of bytes (potential image) to result string as slice of bytes
 // It decodes only good printed QRs. Look at tests file to img1.png file. It doesn't decode this img.
-func (m Makiuchi) Decode(b []byte) ([]byte, error) {
-	img, _, err := image.Decode(bytes.NewReader(b))
+func (m Makiuchi) Decode(ctx context.Context, b []byte) (_ []byte, err error) {
+	_, span := tracing.Start(ctx, "Makiuchi.Decode")
+	defer tracing.End(span, &err)
+
+	// header is checked before pixels are allocated
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to decode bytes to image: ")
+		return nil, errors.Wrap(err, "unable to decode image header: ")
 	}
-	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > MaxPixels/cfg.Height {
+		return nil, errors.Wrapf(ErrImageTooLarge, "%dx%d", cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to create binary bitmap: ")
+		return nil, errors.Wrap(err, "unable to decode bytes to image: ")
 	}
-	result, err := qrcode.NewQRCodeReader().DecodeWithoutHints(bitmap)
+	res, err := m.decodeImage(img)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to decode binary bitmap: ")
+		return nil, err
 	}
-	return []byte(result.GetText()), nil
+	return []byte(res), nil
 }
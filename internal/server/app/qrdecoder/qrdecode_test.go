@@ -2,7 +2,11 @@ package qrdecoder
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"hash/crc32"
 	"os"
 	"path"
 	"testing"
@@ -65,7 +69,7 @@ func TestMakiuchi_Decode(t *testing.T) {
 			var b bytes.Buffer
 			_, err = b.ReadFrom(file)
 			assert.NoError(t, err)
-			res, err := m.Decode(b.Bytes())
+			res, err := m.Decode(context.Background(), b.Bytes())
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -75,3 +79,29 @@ func TestMakiuchi_Decode(t *testing.T) {
 		})
 	}
 }
+
+// pngHeader returns PNG header of width x height RGBA image without pixels
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 6
+	var b bytes.Buffer
+	b.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&b, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	b.Write(chunk)
+	binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return b.Bytes()
+}
+
+func TestMakiuchi_DecodeBomb(t *testing.T) {
+	_, err := Makiuchi{}.Decode(context.Background(), pngHeader(100000, 100000))
+	assert.True(t, errors.Is(err, ErrImageTooLarge), "%v", err)
+
+	_, err = Makiuchi{}.Decode(context.Background(), pngHeader(5000, 4000))
+	assert.True(t, errors.Is(err, ErrImageTooLarge), "20 MP: %v", err)
+
+	_, err = Makiuchi{}.Decode(context.Background(), pngHeader(4096, 4096))
+	assert.False(t, errors.Is(err, ErrImageTooLarge), "16 MP: %v", err)
+}
@@ -0,0 +1,129 @@
+package qrdecoder
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/pkg/errors"
+	xdraw "golang.org/x/image/draw"
+	"image"
+	"image/draw"
+)
+
+// ErrUnscannable is returned for QR images which couldn't be decoded at full size
+var ErrUnscannable = errors.New("qr code is not scannable")
+
+// checkScales are sizes of image relative to rendered one. Small sizes simulate small prints and far cameras
+var checkScales = []float64{1, 0.75, 0.5}
+
+// checkBlurRadius is radius of box blur in pixels which simulates unfocused camera
+const checkBlurRadius = 1
+
+// Report is result of scannability check
+type Report struct {
+	// Score is percent of simulated conditions where QR is decoded to expected content
+	Score int
+	// Warnings describe conditions where QR is not decoded
+	Warnings []string
+}
+
+// Check decodes rendered QR at several downscaled sizes with and without blur.
+// ErrUnscannable is returned if image itself isn't decoded to want.
+func (m Makiuchi) Check(b []byte, want string) (Report, error) {
+	report := Report{Warnings: make([]string, 0)}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return report, errors.Wrap(err, "unable to decode bytes to image: ")
+	}
+	if res, err := m.decodeImage(img); err != nil || res != want {
+		return report, ErrUnscannable
+	}
+
+	passed, total := 0, 0
+	for _, scale := range checkScales {
+		scaled := img
+		if scale != 1 {
+			scaled = resize(img, scale)
+		}
+		for _, blurred := range []bool{false, true} {
+			if scale == 1 && !blurred {
+				// already checked
+				passed++
+				total++
+				continue
+			}
+			variant := scaled
+			if blurred {
+				variant = blur(scaled, checkBlurRadius)
+			}
+			total++
+			if res, err := m.decodeImage(variant); err == nil && res == want {
+				passed++
+				continue
+			}
+			warning := fmt.Sprintf("not readable at %d%% size", int(scale*100))
+			if blurred {
+				warning += " when out of focus"
+			}
+			report.Warnings = append(report.Warnings, warning)
+		}
+	}
+	report.Score = passed * 100 / total
+	return report, nil
+}
+
+// decodeImage decodes QR image to its content
+func (m Makiuchi) decodeImage(img image.Image) (string, error) {
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create binary bitmap: ")
+	}
+	result, err := qrcode.NewQRCodeReader().DecodeWithoutHints(bitmap)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to decode binary bitmap: ")
+	}
+	return result.GetText(), nil
+}
+
+// resize scales image with bilinear filter like camera sensor does
+func resize(img image.Image, scale float64) image.Image {
+	b := img.Bounds()
+	w, h := int(float64(b.Dx())*scale), int(float64(b.Dy())*scale)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.BiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// blur applies box blur of radius r
+func blur(img image.Image, r int) image.Image {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	dst := image.NewRGBA(src.Bounds())
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [4]int
+			n := 0
+			for dy := -r; dy <= r; dy++ {
+				for dx := -r; dx <= r; dx++ {
+					sx, sy := x+dx, y+dy
+					if sx < 0 || sy < 0 || sx >= w || sy >= h {
+						continue
+					}
+					i := src.PixOffset(sx, sy)
+					for c := 0; c < 4; c++ {
+						sum[c] += int(src.Pix[i+c])
+					}
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,66 @@
+package qrdecoder
+
+import (
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/stretchr/testify/assert"
+	"image/color"
+	"testing"
+)
+
+func TestMakiuchi_Check(t *testing.T) {
+	data := "https://griz.grizzlytics.com/app?d=v015cf58619ad623291c8c3b26c108720f7"
+	gray := color.RGBA{R: 150, G: 150, B: 150, A: 255}
+	lightGray := color.RGBA{R: 200, G: 200, B: 200, A: 255}
+
+	tests := []struct {
+		name         string
+		options      []qrencoder.YeqownOption
+		want         string
+		wantScore    int
+		wantWarnings bool
+		wantErr      error
+	}{
+		{
+			name:      "default design",
+			want:      data,
+			wantScore: 100,
+		},
+		{
+			name:         "circles are lost on small prints",
+			options:      []qrencoder.YeqownOption{qrencoder.WithShape(qrencoder.ShapeCircle), qrencoder.WithErrorCorrection(qrencoder.ECLevelHighest)},
+			want:         data,
+			wantScore:    66,
+			wantWarnings: true,
+		},
+		{
+			name:    "low contrast",
+			options: []qrencoder.YeqownOption{qrencoder.WithColors(gray, lightGray)},
+			want:    data,
+			wantErr: ErrUnscannable,
+		},
+		{
+			name:    "wrong content",
+			want:    "https://griz.grizzlytics.com/other",
+			wantErr: ErrUnscannable,
+		},
+	}
+	m := Makiuchi{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]qrencoder.YeqownOption{qrencoder.WithQRWidth(6)}, tt.options...)
+			b, err := qrencoder.NewYeqown(options...).Encode([]byte(data))
+			if !assert.NoError(t, err) {
+				return
+			}
+			report, err := m.Check(b, tt.want)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantScore, report.Score)
+				assert.Equal(t, tt.wantWarnings, len(report.Warnings) > 0)
+			}
+		})
+	}
+}
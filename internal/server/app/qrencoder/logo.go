@@ -0,0 +1,65 @@
+package qrencoder
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"image"
+	"image/png"
+
+	_ "image/jpeg" // JPEG logos are accepted
+)
+
+// ErrInvalidLogo is returned for images which couldn't be used as logo
+var ErrInvalidLogo = errors.New("invalid logo")
+
+const (
+	// MaxLogoBytes is max size of uploaded logo file
+	MaxLogoBytes = 1 << 20
+	// MaxLogoSide is max width and height of logo in pixels
+	MaxLogoSide = 1024
+	// MinLogoSide is min width and height of logo in pixels
+	MinLogoSide = 16
+	// MaxLogoAspect is max ratio of long side of logo to short one
+	MaxLogoAspect = 2
+)
+
+// DecodeLogo validates PNG or JPEG logo and decodes it.
+// Dimensions are checked before decoding, so huge images are rejected cheaply.
+func DecodeLogo(b []byte) (image.Image, error) {
+	if len(b) > MaxLogoBytes {
+		return nil, errors.Wrapf(ErrInvalidLogo, "file is bigger than %d bytes", MaxLogoBytes)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidLogo, "unknown image format")
+	}
+	if format != "png" && format != "jpeg" {
+		return nil, errors.Wrap(ErrInvalidLogo, "only PNG and JPEG are supported")
+	}
+	w, h := config.Width, config.Height
+	if w < MinLogoSide || h < MinLogoSide || w > MaxLogoSide || h > MaxLogoSide {
+		return nil, errors.Wrapf(ErrInvalidLogo, "width and height have to be %d-%d pixels", MinLogoSide, MaxLogoSide)
+	}
+	if w > h*MaxLogoAspect || h > w*MaxLogoAspect {
+		return nil, errors.Wrapf(ErrInvalidLogo, "sides ratio couldn't be more than %d:1", MaxLogoAspect)
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidLogo, err.Error())
+	}
+	return img, nil
+}
+
+// NormalizeLogo validates logo and re-encodes it to PNG, so only clean images are stored
+func NormalizeLogo(b []byte) ([]byte, image.Image, error) {
+	img, err := DecodeLogo(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "encode PNG: ")
+	}
+	return buf.Bytes(), img, nil
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"github.com/pkg/errors"
 	"github.com/yeqown/go-qrcode"
+	"image"
+	"image/color"
 	"path"
 )
 
@@ -13,6 +15,7 @@ type YeqownOption func(*Yeqown)
 // WithQRWidth adds size for blocks
 func WithQRWidth(n uint8) YeqownOption {
 	return func(yeqown *Yeqown) {
+		yeqown.width = n
 		yeqown.options = append(yeqown.options, qrcode.WithQRWidth(n))
 	}
 }
@@ -28,7 +31,16 @@ func WithFileImagePNG(filename string) YeqownOption {
 
 // Yeqown type of QR code encoder
 type Yeqown struct {
-	options []qrcode.ImageOption
+	options      []qrcode.ImageOption
+	width        uint8
+	png          bool
+	fg           color.Color
+	bg           color.Color
+	shape        Shape
+	quietZone    int
+	hasQuietZone bool
+	ecLevel      ECLevel
+	logo         image.Image
 }
 
 // NewYeqown creates new QR encoder
@@ -40,10 +52,20 @@ func NewYeqown(options ...YeqownOption) Yeqown {
 	return y
 }
 
-// DefaultYeqown creates new default encoder
-func DefaultYeqown() Yeqown {
+// WithPNG makes encoder to return PNG instead of default JPEG
+func WithPNG() YeqownOption {
+	return func(yeqown *Yeqown) {
+		yeqown.png = true
+		yeqown.options = append(yeqown.options, qrcode.WithBuiltinImageEncoder(qrcode.PNG_FORMAT))
+	}
+}
+
+// DefaultYeqown creates new default encoder.
+// Additional options are applied after default ones.
+func DefaultYeqown(additional ...YeqownOption) Yeqown {
 	y := Yeqown{}
 	options := []YeqownOption{WithQRWidth(6), WithFileImagePNG("GrizLogo.png")}
+	options = append(options, additional...)
 	for _, option := range options {
 		option(&y)
 	}
@@ -52,7 +74,7 @@ func DefaultYeqown() Yeqown {
 
 // Encode returns slice of bytes with QR code
 func (y Yeqown) Encode(b []byte) ([]byte, error) {
-	qr, err := qrcode.New(string(b), y.options...)
+	qr, err := qrcode.NewWithConfig(string(b), y.config(), y.styledOptions()...)
 	if err != nil {
 		return nil, errors.Wrap(err, "qr encoder generation: ")
 	}
@@ -64,3 +86,65 @@ func (y Yeqown) Encode(b []byte) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// ErrTooSmall is returned if QR couldn't be drawn in requested size
+var ErrTooSmall = errors.New("qr code doesn't fit in size")
+
+// MinModuleWidth is min width of module in pixels which could be scanned
+const MinModuleWidth = 2
+
+// EncodeFit returns QR image with side up to maxSide pixels.
+// Width of module is the biggest whole number of pixels which fits.
+func (y Yeqown) EncodeFit(b []byte, maxSide int) ([]byte, error) {
+	level := y.ecLevel
+	if level == "" {
+		level = ECLevelQuart // default of library
+	}
+	n, err := ModuleCount(b, level)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		width := y.fitWidth(n, maxSide)
+		if width < MinModuleWidth {
+			return nil, ErrTooSmall
+		}
+		sized := y
+		sized.options = append([]qrcode.ImageOption{}, y.options...)
+		WithQRWidth(uint8(width))(&sized)
+		res, err := sized.Encode(b)
+		if err != nil {
+			return nil, err
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(res))
+		if err != nil {
+			return nil, errors.Wrap(err, "qr encoder size: ")
+		}
+		if config.Width <= maxSide {
+			return res, nil
+		}
+		// library has chosen bigger version than estimated one
+		n = (config.Width - 2*sized.borderWidth()) / width
+	}
+	return nil, ErrTooSmall
+}
+
+// fitWidth returns the biggest width of module for QR of n modules in maxSide pixels
+func (y Yeqown) fitWidth(n int, maxSide int) int {
+	width := (maxSide - 2*defaultBorder) / n
+	if y.hasQuietZone {
+		width = maxSide / (n + 2*y.quietZone)
+	}
+	if width > 255 {
+		width = 255
+	}
+	return width
+}
+
+// borderWidth returns width of border around QR in pixels
+func (y Yeqown) borderWidth() int {
+	if y.hasQuietZone {
+		return y.quietZone * y.moduleWidth()
+	}
+	return defaultBorder
+}
@@ -1,8 +1,14 @@
 package qrencoder
 
 import (
+	"bytes"
+	"context"
 	qrdecoder2 "github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
 	"github.com/stretchr/testify/assert"
+	"image"
+	"image/draw"
+	"image/png"
+	"strings"
 	"testing"
 )
 
@@ -50,9 +56,74 @@ func TestYeqown_Encode(t *testing.T) {
 			}
 			assert.NotNil(t, res)
 
-			res2, err := m.Decode(res)
+			res2, err := m.Decode(context.Background(), res)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.data, string(res2))
 		})
 	}
 }
+
+func TestModules(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name:    "short link",
+			data:    "https://griz.grizzlytics.com/summer-sale",
+			wantErr: false,
+		},
+		{
+			name:    "long link",
+			data:    "https://someapp.somedomain.com/apps?q=123456789012345678901234567890123456789012345678901234567890123456789012345678901234567890123456789012345678901234567890",
+			wantErr: false,
+		},
+		{
+			name:    "empty",
+			data:    "",
+			wantErr: true,
+		},
+	}
+	m := qrdecoder2.Makiuchi{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modules, err := Modules([]byte(tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			// render modules 4px each with quiet zone and decode them back
+			const scale = 4
+			size := (len(modules) + 2*QuietZone) * scale
+			img := image.NewGray(image.Rect(0, 0, size, size))
+			draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+			for y, row := range modules {
+				Runs(row, func(x, length int) {
+					r := image.Rect((x+QuietZone)*scale, (y+QuietZone)*scale, (x+QuietZone+length)*scale, (y+QuietZone+1)*scale)
+					draw.Draw(img, r, image.Black, image.Point{}, draw.Src)
+				})
+			}
+			var buf bytes.Buffer
+			if !assert.NoError(t, png.Encode(&buf, img)) {
+				return
+			}
+			res, err := m.Decode(context.Background(), buf.Bytes())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.data, string(res))
+		})
+	}
+}
+
+func TestSVG_Encode(t *testing.T) {
+	res, err := NewSVG(10).Encode([]byte("https://griz.grizzlytics.com/summer-sale"))
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(string(res), "<?xml"))
+		assert.Contains(t, string(res), `<svg xmlns="http://www.w3.org/2000/svg"`)
+		assert.Contains(t, string(res), `d="M4 4h7v1h-7z`)
+	}
+}
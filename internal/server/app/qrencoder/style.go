@@ -0,0 +1,283 @@
+package qrencoder
+
+import (
+	"github.com/pkg/errors"
+	"github.com/yeqown/go-qrcode"
+	xdraw "golang.org/x/image/draw"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Shape is form of dark data modules. Finder patterns are always square, so codes stay scannable
+type Shape string
+
+const (
+	ShapeSquare  Shape = "square"
+	ShapeCircle  Shape = "circle"
+	ShapeRounded Shape = "rounded"
+)
+
+// IsValid returns true if shape is known
+func (s Shape) IsValid() bool {
+	switch s {
+	case ShapeSquare, ShapeCircle, ShapeRounded:
+		return true
+	}
+	return false
+}
+
+// ECLevel is error correction level of QR code
+type ECLevel string
+
+const (
+	ECLevelLow     ECLevel = "L"
+	ECLevelMedium  ECLevel = "M"
+	ECLevelQuart   ECLevel = "Q"
+	ECLevelHighest ECLevel = "H"
+)
+
+// IsValid returns true if level is known
+func (l ECLevel) IsValid() bool {
+	switch l {
+	case ECLevelLow, ECLevelMedium, ECLevelQuart, ECLevelHighest:
+		return true
+	}
+	return false
+}
+
+// MaxQuietZone is max number of modules around QR code
+const MaxQuietZone = 16
+
+// logoRatio is max part of QR image width taken by logo
+const logoRatio = 5
+
+// defaultBorder is border of library in pixels
+const defaultBorder = 40
+
+// WithColors sets colors of dark and light modules.
+// Color options of library change its global state, so colors are applied by encoder itself.
+func WithColors(fg, bg color.Color) YeqownOption {
+	return func(yeqown *Yeqown) {
+		yeqown.fg = fg
+		yeqown.bg = bg
+	}
+}
+
+// WithShape sets form of dark modules
+func WithShape(shape Shape) YeqownOption {
+	return func(yeqown *Yeqown) {
+		yeqown.shape = shape
+	}
+}
+
+// WithQuietZone sets border around QR code in modules
+func WithQuietZone(modules int) YeqownOption {
+	return func(yeqown *Yeqown) {
+		yeqown.quietZone = modules
+		yeqown.hasQuietZone = true
+	}
+}
+
+// WithErrorCorrection sets error correction level. Higher levels allow bigger logos
+func WithErrorCorrection(level ECLevel) YeqownOption {
+	return func(yeqown *Yeqown) {
+		yeqown.ecLevel = level
+	}
+}
+
+// WithLogo puts image in the center of QR.
+// Logo is scaled down to 1/5 of QR width, so it is never skipped because of size.
+func WithLogo(logo image.Image) YeqownOption {
+	return func(yeqown *Yeqown) {
+		yeqown.logo = logo
+	}
+}
+
+// ParseHexColor parses color in #rrggbb form
+func ParseHexColor(s string) (color.Color, error) {
+	if len(s) != 7 || s[0] != '#' {
+		return nil, errors.New("color has to be in #rrggbb form")
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return nil, errors.New("color has to be in #rrggbb form")
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// NormalizeHexColor returns color in lower case
+func NormalizeHexColor(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// styledOptions returns options of library for style of encoder
+func (y Yeqown) styledOptions() []qrcode.ImageOption {
+	options := make([]qrcode.ImageOption, 0, len(y.options)+3)
+	options = append(options, y.options...)
+	if y.fg != nil || y.shape != "" {
+		options = append(options, qrcode.WithCustomShape(moduleShape{
+			fg:    y.fg,
+			bg:    y.bg,
+			shape: y.shape,
+		}))
+	}
+	border := defaultBorder
+	if y.hasQuietZone {
+		border = y.quietZone * y.moduleWidth()
+		options = append(options, qrcode.WithBorderWidth(border))
+	}
+	if y.bg != nil || y.logo != nil {
+		options = append(options, qrcode.WithCustomImageEncoder(styleEncoder{
+			bg:     y.bg,
+			border: border,
+			logo:   y.logo,
+			png:    y.png,
+		}))
+	}
+	return options
+}
+
+// config returns encoding config of library
+func (y Yeqown) config() *qrcode.Config {
+	config := qrcode.DefaultConfig()
+	switch y.ecLevel {
+	case ECLevelLow:
+		config.EcLevel = qrcode.ErrorCorrectionLow
+	case ECLevelMedium:
+		config.EcLevel = qrcode.ErrorCorrectionMedium
+	case ECLevelQuart:
+		config.EcLevel = qrcode.ErrorCorrectionQuart
+	case ECLevelHighest:
+		config.EcLevel = qrcode.ErrorCorrectionHighest
+	}
+	return config
+}
+
+// moduleWidth returns width of module in pixels
+func (y Yeqown) moduleWidth() int {
+	if y.width == 0 {
+		return 20 // default of library
+	}
+	return int(y.width)
+}
+
+// libraryLight is color of light modules given by library
+var libraryLight = color.RGBAModel.Convert(color.White)
+
+// moduleShape draws modules with own colors.
+// Colors of library are global, so they couldn't be used for different styles at once.
+type moduleShape struct {
+	fg    color.Color
+	bg    color.Color
+	shape Shape
+}
+
+// Draw draws data module
+func (s moduleShape) Draw(ctx *qrcode.DrawContext) {
+	if s.isLight(ctx) {
+		s.drawSquare(ctx, s.color(ctx))
+		return
+	}
+	x, y := float64(ctx.UpperLeft().X), float64(ctx.UpperLeft().Y)
+	w, h := ctx.Edge()
+	switch s.shape {
+	case ShapeCircle:
+		r := float64(w) / 2
+		ctx.DrawCircle(x+r, y+float64(h)/2, r)
+	case ShapeRounded:
+		ctx.DrawRoundedRectangle(x, y, float64(w), float64(h), float64(w)/3)
+	default:
+		ctx.DrawRectangle(x, y, float64(w), float64(h))
+	}
+	ctx.SetColor(s.color(ctx))
+	ctx.Fill()
+}
+
+// DrawFinder draws modules of finder patterns
+func (s moduleShape) DrawFinder(ctx *qrcode.DrawContext) {
+	s.drawSquare(ctx, s.color(ctx))
+}
+
+func (s moduleShape) drawSquare(ctx *qrcode.DrawContext, c color.Color) {
+	w, h := ctx.Edge()
+	ctx.DrawRectangle(float64(ctx.UpperLeft().X), float64(ctx.UpperLeft().Y), float64(w), float64(h))
+	ctx.SetColor(c)
+	ctx.Fill()
+}
+
+func (s moduleShape) isLight(ctx *qrcode.DrawContext) bool {
+	return color.RGBAModel.Convert(ctx.Color()) == libraryLight
+}
+
+// color returns color of module in style
+func (s moduleShape) color(ctx *qrcode.DrawContext) color.Color {
+	if s.isLight(ctx) {
+		if s.bg != nil {
+			return s.bg
+		}
+		return color.White
+	}
+	if s.fg != nil {
+		return s.fg
+	}
+	return color.Black
+}
+
+// styleEncoder paints border with background color and puts logo
+// in the center of QR image before encoding
+type styleEncoder struct {
+	bg     color.Color
+	border int
+	logo   image.Image
+	png    bool
+}
+
+// Encode implements qrcode.ImageEncoder
+func (e styleEncoder) Encode(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+
+	if e.bg != nil {
+		bg := image.NewUniform(e.bg)
+		inner := image.Rect(b.Min.X+e.border, b.Min.Y+e.border, b.Max.X-e.border, b.Max.Y-e.border)
+		for _, r := range []image.Rectangle{
+			image.Rect(b.Min.X, b.Min.Y, b.Max.X, inner.Min.Y),
+			image.Rect(b.Min.X, inner.Max.Y, b.Max.X, b.Max.Y),
+			image.Rect(b.Min.X, inner.Min.Y, inner.Min.X, inner.Max.Y),
+			image.Rect(inner.Max.X, inner.Min.Y, b.Max.X, inner.Max.Y),
+		} {
+			draw.Draw(rgba, r, bg, image.Point{}, draw.Src)
+		}
+	}
+
+	if e.logo != nil {
+		lb := e.logo.Bounds()
+		maxSide := b.Dx() / logoRatio
+		if b.Dy()/logoRatio < maxSide {
+			maxSide = b.Dy() / logoRatio
+		}
+		lw, lh := lb.Dx(), lb.Dy()
+		if lw > maxSide || lh > maxSide {
+			if lw >= lh {
+				lw, lh = maxSide, lh*maxSide/lw
+			} else {
+				lw, lh = lw*maxSide/lh, maxSide
+			}
+		}
+		x := b.Min.X + (b.Dx()-lw)/2
+		y := b.Min.Y + (b.Dy()-lh)/2
+		xdraw.CatmullRom.Scale(rgba, image.Rect(x, y, x+lw, y+lh), e.logo, lb, draw.Over, nil)
+	}
+
+	if e.png {
+		return png.Encode(w, rgba)
+	}
+	return jpeg.Encode(w, rgba, nil)
+}
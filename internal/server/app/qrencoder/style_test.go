@@ -0,0 +1,198 @@
+package qrencoder
+
+import (
+	"bytes"
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestYeqown_EncodeStyled(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{R: 200, A: 255}), image.Point{}, draw.Src)
+	navy := color.RGBA{R: 0x1a, G: 0x23, B: 0x7e, A: 0xff}
+	cream := color.RGBA{R: 0xff, G: 0xf8, B: 0xe1, A: 0xff}
+
+	tests := []struct {
+		name    string
+		options []YeqownOption
+		wantBg  color.Color
+	}{
+		{
+			name:    "colors",
+			options: []YeqownOption{WithColors(navy, cream)},
+			wantBg:  cream,
+		},
+		{
+			name:    "circle shape with quiet zone",
+			options: []YeqownOption{WithShape(ShapeCircle), WithQuietZone(4)},
+			wantBg:  color.White,
+		},
+		{
+			name:    "rounded shape with big logo",
+			options: []YeqownOption{WithShape(ShapeRounded), WithErrorCorrection(ECLevelHighest), WithLogo(logo)},
+			wantBg:  color.White,
+		},
+	}
+	data := "https://griz.grizzlytics.com/summer-sale"
+	m := qrdecoder.Makiuchi{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]YeqownOption{WithQRWidth(8), WithPNG()}, tt.options...)
+			res, err := NewYeqown(options...).Encode([]byte(data))
+			if !assert.NoError(t, err) {
+				return
+			}
+			img, err := png.Decode(bytes.NewReader(res))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, color.RGBAModel.Convert(tt.wantBg), color.RGBAModel.Convert(img.At(1, 1)))
+
+			decoded, err := m.Decode(context.Background(), res)
+			assert.NoError(t, err)
+			assert.Equal(t, data, string(decoded))
+		})
+	}
+}
+
+func TestDecodeLogo(t *testing.T) {
+	encode := func(w, h int, asPNG bool) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		var buf bytes.Buffer
+		if asPNG {
+			png.Encode(&buf, img)
+		} else {
+			jpeg.Encode(&buf, img, nil)
+		}
+		return buf.Bytes()
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{
+			name: "png",
+			data: encode(200, 100, true),
+		},
+		{
+			name: "jpeg",
+			data: encode(64, 64, false),
+		},
+		{
+			name:    "too wide",
+			data:    encode(300, 100, true),
+			wantErr: true,
+		},
+		{
+			name:    "too small",
+			data:    encode(8, 8, true),
+			wantErr: true,
+		},
+		{
+			name:    "too big",
+			data:    encode(2000, 2000, true),
+			wantErr: true,
+		},
+		{
+			name:    "not image",
+			data:    []byte("<svg></svg>"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeLogo(tt.data)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLogo)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestYeqown_EncodeFit(t *testing.T) {
+	data := "https://griz.grizzlytics.com/app?d=v015cf58619ad623291c8c3b26c108720f7"
+	tests := []struct {
+		name    string
+		options []YeqownOption
+		maxSide int
+		wantErr error
+	}{
+		{name: "default border", maxSide: 512},
+		{name: "quiet zone", options: []YeqownOption{WithQuietZone(2)}, maxSide: 256},
+		{name: "medium level", options: []YeqownOption{WithQuietZone(4), WithErrorCorrection(ECLevelMedium)}, maxSide: 300},
+		{name: "too small", options: []YeqownOption{WithQuietZone(4)}, maxSide: 64, wantErr: ErrTooSmall},
+	}
+	m := qrdecoder.Makiuchi{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]YeqownOption{WithPNG()}, tt.options...)
+			res, err := NewYeqown(options...).EncodeFit([]byte(data), tt.maxSide)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			config, _, err := image.DecodeConfig(bytes.NewReader(res))
+			if assert.NoError(t, err) {
+				assert.LessOrEqual(t, config.Width, tt.maxSide)
+				assert.Greater(t, config.Width, tt.maxSide*3/4)
+			}
+			decoded, err := m.Decode(context.Background(), res)
+			assert.NoError(t, err)
+			assert.Equal(t, data, string(decoded))
+		})
+	}
+}
+
+func TestSVG_EncodeStyled(t *testing.T) {
+	navy := color.RGBA{R: 0x1a, G: 0x23, B: 0x7e, A: 0xff}
+	tests := []struct {
+		name     string
+		options  []SVGOption
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "colors and width",
+			options:  []SVGOption{WithSVGColors(navy, color.White), WithSVGWidth(300), WithSVGQuietZone(2)},
+			contains: []string{`width="300"`, `fill="#ffffff"`, `<path fill="#1a237e" d="M2 2h7v1h-7z`},
+			excludes: []string{"<circle", "<image"},
+		},
+		{
+			name:     "circles keep square finders",
+			options:  []SVGOption{WithSVGShape(ShapeCircle)},
+			contains: []string{`d="M4 4h7v1h-7z`, `<circle cx=`},
+		},
+		{
+			name:     "rounded with logo",
+			options:  []SVGOption{WithSVGShape(ShapeRounded), WithSVGErrorCorrection(ECLevelHighest), WithSVGLogo([]byte{1, 2, 3}, 40, 20)},
+			contains: []string{`rx="0.33"`, `<image `, `href="data:image/png;base64,AQID"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := NewSVG(10, tt.options...).Encode([]byte("https://griz.grizzlytics.com/summer-sale"))
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, s := range tt.contains {
+				assert.Contains(t, string(res), s)
+			}
+			for _, s := range tt.excludes {
+				assert.NotContains(t, string(res), s)
+			}
+		})
+	}
+}
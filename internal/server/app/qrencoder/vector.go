@@ -0,0 +1,242 @@
+package qrencoder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+	"github.com/makiuchi-d/gozxing/qrcode/encoder"
+	"github.com/pkg/errors"
+	"image/color"
+)
+
+// QuietZone is number of light modules around QR code
+const QuietZone = 4
+
+// Modules returns modules of QR code without quiet zone.
+// modules[y][x] is true for dark module.
+func Modules(b []byte) ([][]bool, error) {
+	return modules(b, ECLevelMedium)
+}
+
+// ModuleCount returns number of modules in row of QR code with error correction level
+func ModuleCount(b []byte, level ECLevel) (int, error) {
+	m, err := modules(b, level)
+	if err != nil {
+		return 0, err
+	}
+	return len(m), nil
+}
+
+func modules(b []byte, level ECLevel) ([][]bool, error) {
+	if len(b) == 0 {
+		return nil, errors.New("qr encoder generation: empty content")
+	}
+	ecLevel := decoder.ErrorCorrectionLevel_M
+	switch level {
+	case ECLevelLow:
+		ecLevel = decoder.ErrorCorrectionLevel_L
+	case ECLevelQuart:
+		ecLevel = decoder.ErrorCorrectionLevel_Q
+	case ECLevelHighest:
+		ecLevel = decoder.ErrorCorrectionLevel_H
+	}
+	code, err := encoder.Encoder_encode(string(b), ecLevel, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "qr encoder generation: ")
+	}
+	matrix := code.GetMatrix()
+	size := matrix.GetWidth()
+	modules := make([][]bool, size)
+	for y := 0; y < size; y++ {
+		modules[y] = make([]bool, size)
+		for x := 0; x < size; x++ {
+			modules[y][x] = matrix.Get(x, y) == 1
+		}
+	}
+	return modules, nil
+}
+
+// Runs calls fn for every horizontal run of dark modules in row
+func Runs(row []bool, fn func(x, length int)) {
+	for x := 0; x < len(row); {
+		if !row[x] {
+			x++
+			continue
+		}
+		start := x
+		for x < len(row) && row[x] {
+			x++
+		}
+		fn(start, x-start)
+	}
+}
+
+// SVGOption is option for encoder of type SVG
+type SVGOption func(*SVG)
+
+// WithSVGColors sets colors of dark and light modules
+func WithSVGColors(fg, bg color.Color) SVGOption {
+	return func(svg *SVG) {
+		svg.fg = hexColor(fg)
+		svg.bg = hexColor(bg)
+	}
+}
+
+// WithSVGShape sets form of dark modules
+func WithSVGShape(shape Shape) SVGOption {
+	return func(svg *SVG) {
+		svg.shape = shape
+	}
+}
+
+// WithSVGQuietZone sets border around QR code in modules
+func WithSVGQuietZone(modules int) SVGOption {
+	return func(svg *SVG) {
+		svg.quietZone = modules
+	}
+}
+
+// WithSVGErrorCorrection sets error correction level
+func WithSVGErrorCorrection(level ECLevel) SVGOption {
+	return func(svg *SVG) {
+		svg.ecLevel = level
+	}
+}
+
+// WithSVGLogo puts PNG image in the center of QR. Logo takes up to 1/5 of QR width
+func WithSVGLogo(png []byte, width, height int) SVGOption {
+	return func(svg *SVG) {
+		svg.logo = png
+		svg.logoWidth = width
+		svg.logoHeight = height
+	}
+}
+
+// WithSVGWidth sets width and height of image in pixels instead of size of module
+func WithSVGWidth(width int) SVGOption {
+	return func(svg *SVG) {
+		svg.width = width
+	}
+}
+
+// SVG type of QR code encoder which returns vector image
+type SVG struct {
+	moduleSize int
+	width      int
+	fg         string
+	bg         string
+	shape      Shape
+	quietZone  int
+	ecLevel    ECLevel
+	logo       []byte
+	logoWidth  int
+	logoHeight int
+}
+
+// NewSVG creates new SVG encoder. moduleSize is size of module in pixels
+func NewSVG(moduleSize int, options ...SVGOption) SVG {
+	if moduleSize < 1 {
+		moduleSize = 1
+	}
+	s := SVG{
+		moduleSize: moduleSize,
+		fg:         "#000000",
+		bg:         "#ffffff",
+		quietZone:  QuietZone,
+		ecLevel:    ECLevelMedium,
+	}
+	for _, option := range options {
+		option(&s)
+	}
+	return s
+}
+
+// Encode returns SVG image with QR code
+func (s SVG) Encode(b []byte) ([]byte, error) {
+	modules, err := modules(b, s.ecLevel)
+	if err != nil {
+		return nil, err
+	}
+	n := len(modules)
+	q := s.quietZone
+	size := n + 2*q
+	width := size * s.moduleSize
+	if s.width > 0 {
+		width = s.width
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		width, width, size, size)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/><path fill="%s" d="`, size, size, s.bg, s.fg)
+	var dots bytes.Buffer
+	for y, row := range modules {
+		if s.shape == ShapeCircle || s.shape == ShapeRounded {
+			// finder patterns are always square, so only their modules are drawn by path
+			for x, dark := range row {
+				if dark && !isFinder(x, y, n) {
+					s.writeDot(&dots, x+q, y+q)
+				}
+			}
+			row = finderRow(row, y)
+		}
+		Runs(row, func(x, length int) {
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+q, y+q, length, length)
+		})
+	}
+	buf.WriteString(`"/>`)
+	if dots.Len() > 0 {
+		fmt.Fprintf(&buf, `<g fill="%s" shape-rendering="auto">`, s.fg)
+		buf.Write(dots.Bytes())
+		buf.WriteString(`</g>`)
+	}
+	if s.logo != nil {
+		s.writeLogo(&buf, size)
+	}
+	buf.WriteString(`</svg>` + "\n")
+	return buf.Bytes(), nil
+}
+
+// writeDot writes dark data module of round shape
+func (s SVG) writeDot(buf *bytes.Buffer, x, y int) {
+	if s.shape == ShapeCircle {
+		fmt.Fprintf(buf, `<circle cx="%d.5" cy="%d.5" r="0.5"/>`, x, y)
+		return
+	}
+	fmt.Fprintf(buf, `<rect x="%d" y="%d" width="1" height="1" rx="0.33"/>`, x, y)
+}
+
+// writeLogo writes logo in the center of QR. size is width of QR with quiet zone in modules
+func (s SVG) writeLogo(buf *bytes.Buffer, size int) {
+	maxSide := float64(size) / logoRatio
+	w, h := maxSide, maxSide
+	if s.logoWidth >= s.logoHeight {
+		h = maxSide * float64(s.logoHeight) / float64(s.logoWidth)
+	} else {
+		w = maxSide * float64(s.logoWidth) / float64(s.logoHeight)
+	}
+	fmt.Fprintf(buf, `<image x="%.3f" y="%.3f" width="%.3f" height="%.3f" href="data:image/png;base64,%s"/>`,
+		(float64(size)-w)/2, (float64(size)-h)/2, w, h, base64.StdEncoding.EncodeToString(s.logo))
+}
+
+// isFinder returns true if module belongs to finder pattern
+func isFinder(x, y, n int) bool {
+	return (x < 7 && y < 7) || (x >= n-7 && y < 7) || (x < 7 && y >= n-7)
+}
+
+// finderRow returns row with modules of finder patterns only
+func finderRow(row []bool, y int) []bool {
+	n := len(row)
+	res := make([]bool, n)
+	for x, dark := range row {
+		res[x] = dark && isFinder(x, y, n)
+	}
+	return res
+}
+
+// hexColor formats color in #rrggbb form
+func hexColor(c color.Color) string {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
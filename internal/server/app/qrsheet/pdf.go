@@ -0,0 +1,285 @@
+package qrsheet
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/pkg/errors"
+	"io"
+	"math"
+	"strings"
+)
+
+// PageSize is size of PDF page in points (1/72 inch)
+type PageSize struct {
+	Width  float64
+	Height float64
+}
+
+var (
+	PageA4     = PageSize{Width: 595.28, Height: 841.89}
+	PageLetter = PageSize{Width: 612, Height: 792}
+)
+
+// Layout describes grid of codes on PDF pages
+type Layout struct {
+	Page      PageSize
+	Columns   int
+	Rows      int
+	Margin    float64 // page margin in points
+	Captions  bool    // print label under every code
+	CropMarks bool    // print crop marks around every code
+}
+
+const (
+	cellPadding    = 12.0 // gap between cell border and trim box, crop marks are drawn there
+	minTrimSize    = 36.0
+	captionSize    = 9.0
+	captionHeight  = 14.0
+	captionWidth   = 0.55 // average width of Helvetica symbol relative to font size
+	cropMarkOffset = 2.0
+	cropMarkLength = 8.0
+)
+
+// fixed object numbers
+const (
+	catalogObject = 1
+	pagesObject   = 2
+	fontObject    = 3
+)
+
+// PDF writes codes to pages by grid.
+// Objects are written as soon as page is filled, only current page is kept in memory.
+type PDF struct {
+	w       *countingWriter
+	layout  Layout
+	offsets []int64 // offsets of objects, object number is index + 1
+	pages   []int   // object numbers of written pages
+	content bytes.Buffer
+	cell    int // next cell on current page
+	started bool
+}
+
+var _ Sheet = (*PDF)(nil)
+
+// NewPDF creates PDF. Nothing is written until first code is added
+func NewPDF(w io.Writer, layout Layout) (*PDF, error) {
+	if layout.Columns < 1 || layout.Rows < 1 {
+		return nil, errors.New("grid has to contain at least one column and row")
+	}
+	p := &PDF{
+		w:      &countingWriter{w: w},
+		layout: layout,
+	}
+	if p.trimWidth() < minTrimSize || p.trimHeight() < minTrimSize {
+		return nil, errors.New("grid cells are too small for page")
+	}
+
+	p.newObject() // catalog
+	p.newObject() // pages are written on Close
+	p.newObject() // font
+	return p, nil
+}
+
+// Add draws code in next cell of grid
+func (p *PDF) Add(label string, content string) error {
+	modules, err := qrencoder.Modules([]byte(content))
+	if err != nil {
+		return errors.Wrap(err, "encode: ")
+	}
+	p.start()
+	if p.cell == p.layout.Columns*p.layout.Rows {
+		p.flushPage()
+	}
+
+	col := p.cell % p.layout.Columns
+	row := p.cell / p.layout.Columns
+	cellWidth, cellHeight := p.cellSize()
+	// trim box, PDF origin is bottom left corner
+	left := p.layout.Margin + float64(col)*cellWidth + cellPadding
+	top := p.layout.Page.Height - p.layout.Margin - float64(row)*cellHeight - cellPadding
+	width, height := p.trimWidth(), p.trimHeight()
+	bottom := top - height
+
+	captionSpace := 0.0
+	if p.layout.Captions {
+		captionSpace = captionHeight
+	}
+	side := math.Min(width, height-captionSpace)
+	qrLeft := left + (width-side)/2
+	module := side / float64(len(modules)+2*qrencoder.QuietZone)
+
+	c := &p.content
+	c.WriteString("0 g\n")
+	for y, line := range modules {
+		qrencoder.Runs(line, func(x, length int) {
+			fmt.Fprintf(c, "%.3f %.3f %.3f %.3f re\n",
+				qrLeft+float64(x+qrencoder.QuietZone)*module,
+				top-float64(y+qrencoder.QuietZone+1)*module,
+				float64(length)*module,
+				module)
+		})
+	}
+	c.WriteString("f\n")
+
+	if p.layout.Captions {
+		text := truncateCaption(label, width)
+		textWidth := float64(len([]rune(text))) * captionSize * captionWidth
+		fmt.Fprintf(c, "BT /F1 %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+			captionSize, left+(width-textWidth)/2, bottom+(captionSpace-captionSize)/2, pdfString(text))
+	}
+
+	if p.layout.CropMarks {
+		c.WriteString("0.5 w 0 G\n")
+		for _, corner := range [][4]float64{
+			{left, top, -1, 1}, {left + width, top, 1, 1},
+			{left, bottom, -1, -1}, {left + width, bottom, 1, -1},
+		} {
+			x, y, dx, dy := corner[0], corner[1], corner[2], corner[3]
+			fmt.Fprintf(c, "%.2f %.2f m %.2f %.2f l S\n",
+				x+dx*cropMarkOffset, y, x+dx*(cropMarkOffset+cropMarkLength), y)
+			fmt.Fprintf(c, "%.2f %.2f m %.2f %.2f l S\n",
+				x, y+dy*cropMarkOffset, x, y+dy*(cropMarkOffset+cropMarkLength))
+		}
+	}
+
+	p.cell++
+	return p.w.err
+}
+
+// Close writes last page, page tree and cross-reference table
+func (p *PDF) Close() error {
+	p.start()
+	if p.cell > 0 || len(p.pages) == 0 {
+		p.flushPage()
+	}
+
+	p.beginObject(pagesObject)
+	p.w.WriteString("<< /Type /Pages /Kids [")
+	for i, page := range p.pages {
+		if i > 0 {
+			p.w.WriteString(" ")
+		}
+		fmt.Fprintf(p.w, "%d 0 R", page)
+	}
+	fmt.Fprintf(p.w, "] /Count %d >>\nendobj\n", len(p.pages))
+
+	xref := p.w.n
+	fmt.Fprintf(p.w, "xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, offset := range p.offsets {
+		fmt.Fprintf(p.w, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(p.w, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(p.offsets)+1, catalogObject, xref)
+	return p.w.err
+}
+
+// start writes header, catalog and font
+func (p *PDF) start() {
+	if p.started {
+		return
+	}
+	p.started = true
+	p.w.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	p.beginObject(catalogObject)
+	fmt.Fprintf(p.w, "<< /Type /Catalog /Pages %d 0 R >>\nendobj\n", pagesObject)
+	p.beginObject(fontObject)
+	p.w.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>\nendobj\n")
+}
+
+// flushPage writes content of current page
+func (p *PDF) flushPage() {
+	contentObject := p.newObject()
+	p.beginObject(contentObject)
+	fmt.Fprintf(p.w, "<< /Length %d >>\nstream\n", p.content.Len())
+	p.w.Write(p.content.Bytes())
+	p.w.WriteString("\nendstream\nendobj\n")
+
+	pageObject := p.newObject()
+	p.beginObject(pageObject)
+	fmt.Fprintf(p.w, "<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Contents %d 0 R /Resources << /Font << /F1 %d 0 R >> >> >>\nendobj\n",
+		pagesObject, p.layout.Page.Width, p.layout.Page.Height, contentObject, fontObject)
+
+	p.pages = append(p.pages, pageObject)
+	p.content.Reset()
+	p.cell = 0
+}
+
+func (p *PDF) newObject() int {
+	p.offsets = append(p.offsets, 0)
+	return len(p.offsets)
+}
+
+func (p *PDF) beginObject(n int) {
+	p.offsets[n-1] = p.w.n
+	fmt.Fprintf(p.w, "%d 0 obj\n", n)
+}
+
+func (p *PDF) cellSize() (float64, float64) {
+	return (p.layout.Page.Width - 2*p.layout.Margin) / float64(p.layout.Columns),
+		(p.layout.Page.Height - 2*p.layout.Margin) / float64(p.layout.Rows)
+}
+
+func (p *PDF) trimWidth() float64 {
+	w, _ := p.cellSize()
+	return w - 2*cellPadding
+}
+
+func (p *PDF) trimHeight() float64 {
+	_, h := p.cellSize()
+	return h - 2*cellPadding
+}
+
+// truncateCaption shortens label to fit width
+func truncateCaption(label string, width float64) string {
+	runes := []rune(strings.TrimSpace(label))
+	max := int(width / (captionSize * captionWidth))
+	if len(runes) <= max {
+		return string(runes)
+	}
+	if max <= 3 {
+		return string(runes[:max])
+	}
+	return string(runes[:max-3]) + "..."
+}
+
+// pdfString escapes text for PDF string literal in WinAnsiEncoding.
+// Symbols out of Latin-1 are replaced with "?"
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// countingWriter counts written bytes and keeps first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+func (c *countingWriter) WriteString(s string) (int, error) {
+	return c.Write([]byte(s))
+}
@@ -0,0 +1,24 @@
+package qrsheet
+
+// Sheet receives QR codes one by one and writes them to output.
+// Codes are not kept after Add, so sheets of any size could be streamed.
+type Sheet interface {
+	// Add renders content as QR code with label
+	Add(label string, content string) error
+	// Close finishes output. Underlying writer is not closed
+	Close() error
+}
+
+// ImageSheet receives images rendered by caller, e.g. in style of code
+type ImageSheet interface {
+	Sheet
+	// Ext returns extension of images of sheet (png, svg)
+	Ext() string
+	// AddImage writes rendered image with label
+	AddImage(label string, b []byte) error
+}
+
+// Encoder renders content to image
+type Encoder interface {
+	Encode(b []byte) ([]byte, error)
+}
@@ -0,0 +1,144 @@
+package qrsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestZIP_Add(t *testing.T) {
+	tests := []struct {
+		label    string
+		wantName string
+	}{
+		{label: "Shoe A", wantName: "Shoe-A.svg"},
+		{label: "shoe a", wantName: "shoe-a-2.svg"},
+		{label: "../../etc/passwd", wantName: "etc-passwd.svg"},
+		{label: "", wantName: "code.svg"},
+		{label: "Ботинки", wantName: "code-2.svg"},
+	}
+	var buf bytes.Buffer
+	z := NewZIP(&buf, qrencoder.NewSVG(4), "svg")
+	for _, tt := range tests {
+		assert.NoError(t, z.Add(tt.label, "https://griz.grizzlytics.com/summer-sale"))
+	}
+	if !assert.NoError(t, z.Close()) {
+		return
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, r.File, len(tests)) {
+		for i, tt := range tests {
+			assert.Equal(t, tt.wantName, r.File[i].Name)
+		}
+	}
+}
+
+func TestPDF_Close(t *testing.T) {
+	tests := []struct {
+		name      string
+		codes     int
+		layout    Layout
+		wantPages int
+		wantErr   bool
+	}{
+		{
+			name:      "single page",
+			codes:     5,
+			layout:    Layout{Page: PageA4, Columns: 3, Rows: 4, Margin: 36, Captions: true, CropMarks: true},
+			wantPages: 1,
+		},
+		{
+			name:      "several pages",
+			codes:     25,
+			layout:    Layout{Page: PageLetter, Columns: 3, Rows: 4, Margin: 36},
+			wantPages: 3,
+		},
+		{
+			name:      "full page",
+			codes:     12,
+			layout:    Layout{Page: PageA4, Columns: 3, Rows: 4, Captions: true},
+			wantPages: 1,
+		},
+		{
+			name:      "empty",
+			codes:     0,
+			layout:    Layout{Page: PageA4, Columns: 1, Rows: 1},
+			wantPages: 1,
+		},
+		{
+			name:    "cells are too small",
+			layout:  Layout{Page: PageA4, Columns: 30, Rows: 4},
+			wantErr: true,
+		},
+		{
+			name:    "no columns",
+			layout:  Layout{Page: PageA4, Rows: 4},
+			wantErr: true,
+		},
+	}
+	objectRe := regexp.MustCompile(`^(\d+) 0 obj`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			p, err := NewPDF(&buf, tt.layout)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			for i := 0; i < tt.codes; i++ {
+				assert.NoError(t, p.Add("Code (#"+strconv.Itoa(i)+")", "https://griz.grizzlytics.com/code-"+strconv.Itoa(i)))
+			}
+			if !assert.NoError(t, p.Close()) {
+				return
+			}
+
+			doc := buf.String()
+			assert.True(t, strings.HasPrefix(doc, "%PDF-1.4\n"))
+			assert.True(t, strings.HasSuffix(doc, "%%EOF\n"))
+			assert.Equal(t, tt.wantPages, strings.Count(doc, "/Type /Page /Parent"))
+			assert.Contains(t, doc, "/Count "+strconv.Itoa(tt.wantPages)+" >>")
+
+			// every xref entry points to its object
+			xref := doc[strings.LastIndex(doc, "\nxref\n")+1:]
+			lines := strings.Split(xref, "\n")[3:]
+			for i := 0; i < len(p.offsets); i++ {
+				offset, err := strconv.Atoi(lines[i][:10])
+				if assert.NoError(t, err) {
+					m := objectRe.FindStringSubmatch(doc[offset:])
+					if assert.NotNil(t, m) {
+						assert.Equal(t, strconv.Itoa(i+1), m[1])
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_pdfString(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "Shoe A", want: "Shoe A"},
+		{text: `a(b)\c`, want: `a\(b\)\\c`},
+		{text: "Café", want: `Caf\351`},
+		{text: "Ботинки", want: "???????"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.want, pdfString(tt.text))
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package qrsheet
+
+import (
+	"archive/zip"
+	"github.com/pkg/errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var unsafeFilenameSymbols = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// maxFilenameLength is max length of file name without extension
+const maxFilenameLength = 64
+
+// ZIP writes every QR code as separate image file named by label
+type ZIP struct {
+	zw      *zip.Writer
+	encoder Encoder
+	ext     string
+	names   map[string]struct{}
+}
+
+var _ ImageSheet = (*ZIP)(nil)
+
+// NewZIP creates ZIP. ext is extension of images encoded by encoder (png, svg)
+func NewZIP(w io.Writer, encoder Encoder, ext string) *ZIP {
+	return &ZIP{
+		zw:      zip.NewWriter(w),
+		encoder: encoder,
+		ext:     ext,
+		names:   make(map[string]struct{}),
+	}
+}
+
+// Add renders content and writes it to archive
+func (z *ZIP) Add(label string, content string) error {
+	b, err := z.encoder.Encode([]byte(content))
+	if err != nil {
+		return errors.Wrap(err, "encode: ")
+	}
+	return z.AddImage(label, b)
+}
+
+// Ext returns extension of images
+func (z *ZIP) Ext() string {
+	return z.ext
+}
+
+// AddImage writes image rendered by caller to archive
+func (z *ZIP) AddImage(label string, b []byte) error {
+	f, err := z.zw.CreateHeader(&zip.FileHeader{
+		Name:     z.filename(label),
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "create file: ")
+	}
+	_, err = f.Write(b)
+	return errors.Wrap(err, "write file: ")
+}
+
+// Close writes archive directory
+func (z *ZIP) Close() error {
+	return z.zw.Close()
+}
+
+// filename returns safe unique file name for label
+func (z *ZIP) filename(label string) string {
+	base := strings.Trim(unsafeFilenameSymbols.ReplaceAllString(label, "-"), "-.")
+	if len(base) > maxFilenameLength {
+		base = base[:maxFilenameLength]
+	}
+	if base == "" {
+		base = "code"
+	}
+	name := base
+	for i := 2; ; i++ {
+		if _, ok := z.names[strings.ToLower(name)]; !ok {
+			break
+		}
+		name = base + "-" + strconv.Itoa(i)
+	}
+	z.names[strings.ToLower(name)] = struct{}{}
+	return name + "." + z.ext
+}
@@ -0,0 +1,65 @@
+package scancount
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+// Counter counts scans of codes in memory, so resolution of code doesn't write to DB.
+// Counted scans are added to repository in batches by Flush.
+type Counter struct {
+	mu      sync.Mutex
+	pending map[uint64]uint64
+	repo    domain.CodeRepository
+}
+
+// NewCounter creates Counter which flushes scans to repo
+func NewCounter(repo domain.CodeRepository) *Counter {
+	return &Counter{
+		pending: make(map[uint64]uint64),
+		repo:    repo,
+	}
+}
+
+// Add counts scan of code
+func (c *Counter) Add(codeID uint64) {
+	c.mu.Lock()
+	c.pending[codeID]++
+	c.mu.Unlock()
+}
+
+// Pending returns number of scans which are not flushed
+func (c *Counter) Pending() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n uint64
+	for _, scans := range c.pending {
+		n += scans
+	}
+	return n
+}
+
+// Flush adds counted scans to repository and returns number of codes.
+// Scans are kept for next flush if repository fails.
+func (c *Counter) Flush(ctx context.Context) (int, error) {
+	c.mu.Lock()
+	scans := c.pending
+	c.pending = make(map[uint64]uint64)
+	c.mu.Unlock()
+	if len(scans) == 0 {
+		return 0, nil
+	}
+
+	err := c.repo.AddScans(ctx, scans)
+	if err != nil {
+		c.mu.Lock()
+		for id, n := range scans {
+			c.pending[id] += n
+		}
+		c.mu.Unlock()
+		return 0, errors.Wrap(err, "Flush: AddScans: ")
+	}
+	return len(scans), nil
+}
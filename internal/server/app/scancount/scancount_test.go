@@ -0,0 +1,76 @@
+package scancount
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+// codeRepository records added scans
+type codeRepository struct {
+	domain.CodeRepository
+	scans map[uint64]uint64
+	calls int
+	err   error
+}
+
+func (r *codeRepository) AddScans(_ context.Context, scans map[uint64]uint64) error {
+	r.calls++
+	if r.err != nil {
+		return r.err
+	}
+	for id, n := range scans {
+		r.scans[id] += n
+	}
+	return nil
+}
+
+func TestCounter_Flush(t *testing.T) {
+	repo := &codeRepository{scans: make(map[uint64]uint64)}
+	c := NewCounter(repo)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Add(uint64(i%3 + 1))
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, uint64(100), c.Pending())
+	assert.Equal(t, 0, repo.calls, "scans are not written one by one")
+
+	n, err := c.Flush(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, 1, repo.calls)
+	assert.Equal(t, map[uint64]uint64{1: 34, 2: 33, 3: 33}, repo.scans)
+	assert.Equal(t, uint64(0), c.Pending())
+
+	n, err = c.Flush(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 1, repo.calls, "empty batch isn't written")
+}
+
+func TestCounter_FlushError(t *testing.T) {
+	repo := &codeRepository{scans: make(map[uint64]uint64), err: errors.New("database is locked")}
+	c := NewCounter(repo)
+	ctx := context.Background()
+
+	c.Add(1)
+	_, err := c.Flush(ctx)
+	assert.Error(t, err)
+	c.Add(1)
+	assert.Equal(t, uint64(2), c.Pending(), "scans are kept for next flush")
+
+	repo.err = nil
+	_, err = c.Flush(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint64]uint64{1: 2}, repo.scans)
+}
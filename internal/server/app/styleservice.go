@@ -0,0 +1,279 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/pkg/errors"
+	"image"
+	"image/png"
+)
+
+// CreateLogo validates uploaded PNG or JPEG image and stores it as PNG logo of user
+func (s CodeService) CreateLogo(ctx context.Context, userID uint64, name string, data []byte) (entities.Logo, error) {
+	normalized, img, err := qrencoder.NormalizeLogo(data)
+	if err != nil {
+		return entities.Logo{}, errors.Wrap(err, "CreateLogo: NormalizeLogo: ")
+	}
+	logo := entities.Logo{
+		UserID: userID,
+		Name:   name,
+		Data:   normalized,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+	logo.ID, err = s.styleRepo.CreateLogo(ctx, logo)
+	if err != nil {
+		return logo, errors.Wrap(err, "CreateLogo: CreateLogo: ")
+	}
+	return logo, nil
+}
+
+// GetLogos returns logos of user without image data
+func (s CodeService) GetLogos(ctx context.Context, userID uint64) ([]entities.Logo, error) {
+	logos, err := s.styleRepo.ListLogos(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetLogos: ListLogos: ")
+	}
+	return logos, nil
+}
+
+// GetLogo returns logo with image data
+func (s CodeService) GetLogo(ctx context.Context, logoID uint64) (entities.Logo, error) {
+	logo, err := s.styleRepo.GetLogo(ctx, logoID)
+	if err != nil {
+		return logo, errors.Wrap(err, "GetLogo: GetLogo: ")
+	}
+	return logo, nil
+}
+
+// DeleteLogo removes logo. Logos used by styles couldn't be removed
+func (s CodeService) DeleteLogo(ctx context.Context, logoID uint64) error {
+	err := s.styleRepo.DeleteLogo(ctx, logoID)
+	if err != nil {
+		return errors.Wrap(err, "DeleteLogo: DeleteLogo: ")
+	}
+	return nil
+}
+
+// CreateStyle creates style template of user
+func (s CodeService) CreateStyle(ctx context.Context, style entities.Style) (uint64, error) {
+	err := s.checkStyleLogo(ctx, style)
+	if err != nil {
+		return 0, errors.Wrap(err, "CreateStyle: ")
+	}
+	id, err := s.styleRepo.CreateStyle(ctx, style)
+	if err != nil {
+		return 0, errors.Wrap(err, "CreateStyle: CreateStyle: ")
+	}
+	return id, nil
+}
+
+// GetStyles returns style templates of user
+func (s CodeService) GetStyles(ctx context.Context, userID uint64) ([]entities.Style, error) {
+	styles, err := s.styleRepo.ListStyles(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetStyles: ListStyles: ")
+	}
+	return styles, nil
+}
+
+// GetStyle returns style template
+func (s CodeService) GetStyle(ctx context.Context, styleID uint64) (entities.Style, error) {
+	style, err := s.styleRepo.GetStyle(ctx, styleID)
+	if err != nil {
+		return style, errors.Wrap(err, "GetStyle: GetStyle: ")
+	}
+	return style, nil
+}
+
+// UpdateStyle updates design of style template. Codes using it are changed as well
+func (s CodeService) UpdateStyle(ctx context.Context, style entities.Style) error {
+	err := s.checkStyleLogo(ctx, style)
+	if err != nil {
+		return errors.Wrap(err, "UpdateStyle: ")
+	}
+	err = s.styleRepo.UpdateStyle(ctx, style)
+	if err != nil {
+		return errors.Wrap(err, "UpdateStyle: UpdateStyle: ")
+	}
+	return nil
+}
+
+// DeleteStyle removes style template. Its codes and users return to default design
+func (s CodeService) DeleteStyle(ctx context.Context, styleID uint64) error {
+	err := s.styleRepo.DeleteStyle(ctx, styleID)
+	if err != nil {
+		return errors.Wrap(err, "DeleteStyle: DeleteStyle: ")
+	}
+	return nil
+}
+
+// SetDefaultStyle sets style used for codes of user without own style. StyleID 0 removes it
+func (s CodeService) SetDefaultStyle(ctx context.Context, userID uint64, styleID uint64) error {
+	err := s.checkUserStyle(ctx, userID, styleID)
+	if err != nil {
+		return errors.Wrap(err, "SetDefaultStyle: ")
+	}
+	err = s.userRepo.SetDefaultStyle(ctx, userID, styleID)
+	if err != nil {
+		return errors.Wrap(err, "SetDefaultStyle: SetDefaultStyle: ")
+	}
+	return nil
+}
+
+// SetCodeStyle sets style of code. StyleID 0 makes code use default style of owner
+func (s CodeService) SetCodeStyle(ctx context.Context, code entities.Code, styleID uint64) (entities.Code, error) {
+	err := s.checkUserStyle(ctx, code.UserID, styleID)
+	if err != nil {
+		return code, errors.Wrap(err, "SetCodeStyle: ")
+	}
+	code.StyleID = styleID
+	err = s.codeRepo.Update(ctx, code)
+	if err != nil {
+		return code, errors.Wrap(err, "SetCodeStyle: Update: ")
+	}
+	return code, nil
+}
+
+// checkUserStyle checks that style belongs to user. StyleID 0 is always allowed
+func (s CodeService) checkUserStyle(ctx context.Context, userID uint64, styleID uint64) error {
+	if styleID == 0 {
+		return nil
+	}
+	style, err := s.styleRepo.GetStyle(ctx, styleID)
+	if err != nil {
+		return errors.Wrap(err, "GetStyle: ")
+	}
+	if style.UserID != userID {
+		return errors.Wrap(domain.ErrStyleNotFound, "style of another user")
+	}
+	return nil
+}
+
+// checkStyleLogo checks that logo of style belongs to owner of style
+func (s CodeService) checkStyleLogo(ctx context.Context, style entities.Style) error {
+	if style.LogoID == 0 {
+		return nil
+	}
+	logo, err := s.styleRepo.GetLogo(ctx, style.LogoID)
+	if err != nil {
+		return errors.Wrap(err, "GetLogo: ")
+	}
+	if logo.UserID != style.UserID {
+		return errors.Wrap(domain.ErrLogoNotFound, "logo of another user")
+	}
+	return nil
+}
+
+// codeStyle returns style of code or default style of owner.
+// False is returned for codes without any style, they are drawn by default encoder.
+func (s CodeService) codeStyle(ctx context.Context, owner entities.User, code entities.Code) (entities.Style, bool, error) {
+	styleID := code.StyleID
+	if styleID == 0 {
+		styleID = owner.DefaultStyleID
+	}
+	if styleID == 0 {
+		return entities.Style{}, false, nil
+	}
+	style, err := s.styleRepo.GetStyle(ctx, styleID)
+	if err != nil {
+		if errors.Is(err, domain.ErrStyleNotFound) {
+			return entities.Style{}, false, nil
+		}
+		return style, false, errors.Wrap(err, "GetStyle: ")
+	}
+	return style, true, nil
+}
+
+// renderFingerprint identifies QR image by its content and design.
+// Any change of style gives new fingerprint, so images of old design are never served.
+func renderFingerprint(content string, style entities.Style, styled bool) string {
+	h := sha256.New()
+	h.Write([]byte(content))
+	if styled {
+		fmt.Fprintf(h, "\x00%s\x00%s\x00%s\x00%d\x00%s\x00%d",
+			style.Foreground, style.Background, style.Shape, style.QuietZone, style.ErrorCorrection, style.LogoID)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// styleEncoder returns encoder which draws QR in style. Additional options are applied after style
+func (s CodeService) styleEncoder(ctx context.Context, style entities.Style, additional ...qrencoder.YeqownOption) (qrencoder.Yeqown, error) {
+	_, logo, err := s.styleLogo(ctx, style)
+	if err != nil {
+		return s.qrEncoder, err
+	}
+	options, err := yeqownStyleOptions(style, logo)
+	if err != nil {
+		return s.qrEncoder, err
+	}
+	options = append(options, qrencoder.WithQRWidth(6))
+	return qrencoder.NewYeqown(append(options, additional...)...), nil
+}
+
+// styleLogo returns logo of style with its decoded image. Image is nil for styles without logo
+func (s CodeService) styleLogo(ctx context.Context, style entities.Style) (entities.Logo, image.Image, error) {
+	if style.LogoID == 0 {
+		return entities.Logo{}, nil, nil
+	}
+	logo, err := s.styleRepo.GetLogo(ctx, style.LogoID)
+	if err != nil {
+		return logo, nil, errors.Wrap(err, "GetLogo: ")
+	}
+	// stored logos are always PNG
+	img, err := png.Decode(bytes.NewReader(logo.Data))
+	if err != nil {
+		return logo, nil, errors.Wrap(err, "decode logo: ")
+	}
+	return logo, img, nil
+}
+
+// yeqownStyleOptions returns options of raster encoder for style
+func yeqownStyleOptions(style entities.Style, logo image.Image) ([]qrencoder.YeqownOption, error) {
+	fg, err := qrencoder.ParseHexColor(style.Foreground)
+	if err != nil {
+		return nil, errors.Wrap(err, "foreground: ")
+	}
+	bg, err := qrencoder.ParseHexColor(style.Background)
+	if err != nil {
+		return nil, errors.Wrap(err, "background: ")
+	}
+	options := []qrencoder.YeqownOption{
+		qrencoder.WithColors(fg, bg),
+		qrencoder.WithShape(qrencoder.Shape(style.Shape)),
+		qrencoder.WithQuietZone(style.QuietZone),
+		qrencoder.WithErrorCorrection(qrencoder.ECLevel(style.ErrorCorrection)),
+	}
+	if logo != nil {
+		options = append(options, qrencoder.WithLogo(logo))
+	}
+	return options, nil
+}
+
+// svgStyleOptions returns options of vector encoder for style
+func svgStyleOptions(style entities.Style, logo entities.Logo) ([]qrencoder.SVGOption, error) {
+	fg, err := qrencoder.ParseHexColor(style.Foreground)
+	if err != nil {
+		return nil, errors.Wrap(err, "foreground: ")
+	}
+	bg, err := qrencoder.ParseHexColor(style.Background)
+	if err != nil {
+		return nil, errors.Wrap(err, "background: ")
+	}
+	options := []qrencoder.SVGOption{
+		qrencoder.WithSVGColors(fg, bg),
+		qrencoder.WithSVGShape(qrencoder.Shape(style.Shape)),
+		qrencoder.WithSVGQuietZone(style.QuietZone),
+		qrencoder.WithSVGErrorCorrection(qrencoder.ECLevel(style.ErrorCorrection)),
+	}
+	if len(logo.Data) > 0 {
+		options = append(options, qrencoder.WithSVGLogo(logo.Data, logo.Width, logo.Height))
+	}
+	return options, nil
+}
@@ -0,0 +1,57 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var domainLabelValidator = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// NormalizeDomain returns domain in canonical (lower) case
+func NormalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// ValidateDomain checks if domain could be used as custom domain for links.
+// Domain has to be a plain host name (without scheme, port and path) with at least two labels.
+func ValidateDomain(domain string) error {
+	domain = NormalizeDomain(domain)
+	if domain == "" || len(domain) > 253 {
+		return errors.New("domain has to be 1-253 symbols long")
+	}
+	if net.ParseIP(domain) != nil {
+		return errors.New("domain couldn't be IP address")
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return errors.New("domain has to contain at least two labels")
+	}
+	for _, label := range labels {
+		if !domainLabelValidator.MatchString(label) {
+			return errors.New("domain has wrong format")
+		}
+	}
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return errors.New("top level domain couldn't be numeric")
+	}
+	return nil
+}
+
+// DomainVerificationRecord returns name and value of TXT record which proves ownership of domain
+func DomainVerificationRecord(domain, domainToken string) (name string, value string) {
+	return "_griz-verification." + NormalizeDomain(domain), "griz-verification=" + domainToken
+}
+
+// NewDomainToken returns random token of domain verification
+func NewDomainToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "NewDomainToken: ")
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -6,30 +6,112 @@ import (
 	"strings"
 )
 
-// ExtractHashFromLink ...
-func ExtractHashFromLink(link string) (string, error) {
+// DefaultBaseURL is used if base URL is not configured
+const DefaultBaseURL = "https://griz.grizzlytics.com"
+
+// ErrNotGrizLink is returned if link doesn't belong to griz domains
+var ErrNotGrizLink = errors.New("not griz link")
+
+// Linker builds and parses griz links for configured domains
+type Linker struct {
+	base    *url.URL
+	domains map[string]struct{} // base domain and aliases
+}
+
+// NewLinker creates Linker.
+// baseURL is used for new links (scheme and host only).
+// aliases are legacy domains which are still accepted during extraction.
+func NewLinker(baseURL string, aliases ...string) (Linker, error) {
+	base, err := url.ParseRequestURI(baseURL)
+	if err != nil {
+		return Linker{}, errors.Wrap(err, "base URL: ")
+	}
+	if base.Scheme != "https" && base.Scheme != "http" {
+		return Linker{}, errors.New("base URL has to be http(s)")
+	}
+	if strings.Trim(base.Path, "/") != "" || base.RawQuery != "" {
+		return Linker{}, errors.New("base URL couldn't contain path or query")
+	}
+	base.Path = ""
+	base.Host = strings.ToLower(base.Host)
+
+	domains := map[string]struct{}{base.Host: {}}
+	for _, alias := range aliases {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		if alias == "" {
+			continue
+		}
+		domains[alias] = struct{}{}
+	}
+	return Linker{base: base, domains: domains}, nil
+}
+
+// IsOwnDomain returns true if host is base domain or alias
+func (l Linker) IsOwnDomain(host string) bool {
+	_, ok := l.domains[strings.ToLower(host)]
+	return ok
+}
+
+// ExtractHashFromLink returns hash or slug from link on base or alias domain.
+func (l Linker) ExtractHashFromLink(link string) (string, error) {
+	host, hashToken, err := ParseLink(link)
+	if err != nil {
+		return "", err
+	}
+	if !l.IsOwnDomain(host) {
+		return "", ErrNotGrizLink
+	}
+	return hashToken, nil
+}
+
+// BuildLink returns link by hash or short link by slug
+func (l Linker) BuildLink(hashToken string) string {
+	return buildLink(l.base.Scheme+"://"+l.base.Host, hashToken)
+}
+
+// BuildLinkForDomain returns link on custom domain
+func (l Linker) BuildLinkForDomain(domain string, hashToken string) string {
+	return buildLink("https://"+domain, hashToken)
+}
+
+// BuildPageLink returns link to hosted page of dynamic code
+func (l Linker) BuildPageLink(hashToken string) string {
+	return l.base.Scheme + "://" + l.base.Host + "/p/" + hashToken
+}
+
+// ParseLink returns host and hash (or slug) of link on any domain.
+// It supports full links (/app?d=hash) and short links (/slug).
+// All returned errors wrap ErrNotGrizLink.
+func ParseLink(link string) (host string, hashToken string, err error) {
 	urlObj, err := url.ParseRequestURI(link)
 	if err != nil {
-		return "", errors.Wrap(err, "ParseRequestURI: ")
+		return "", "", errors.Wrap(ErrNotGrizLink, "ParseRequestURI: "+err.Error())
+	}
+	if urlObj.Scheme != "https" && urlObj.Scheme != "http" {
+		return "", "", ErrNotGrizLink
 	}
-	if !strings.HasPrefix(link, "https://griz.grizzlytics.com/app?d=") {
-		return "", errors.New("not griz link")
+	host = strings.ToLower(urlObj.Host)
+	if urlObj.Path != "/app" {
+		slug := strings.TrimPrefix(urlObj.Path, "/")
+		if err := ValidateSlug(slug); err != nil {
+			return "", "", errors.Wrap(ErrNotGrizLink, "short link: "+err.Error())
+		}
+		return host, NormalizeSlug(slug), nil
 	}
-	//if !(link.Host == "griz.grizzlytics.com") {
-	//	return "", errors.New("not griz link")
-	//}
 	m, err := url.ParseQuery(urlObj.RawQuery)
 	if err != nil {
-		return "", errors.Wrap(err, "query is not right: ")
+		return "", "", errors.Wrap(ErrNotGrizLink, "query is not right: "+err.Error())
 	}
 	res := m.Get("d")
 	if res == "" {
-		return "", errors.New("query doesn't contain necessary params")
+		return "", "", errors.Wrap(ErrNotGrizLink, "query doesn't contain necessary params")
 	}
-	return res, nil
+	return host, res, nil
 }
 
-// BuildLink ...
-func BuildLink(hashToken string) string {
-	return "https://griz.grizzlytics.com/app?d=" + hashToken
+func buildLink(origin string, hashToken string) string {
+	if !IsHash(hashToken) {
+		return origin + "/" + NormalizeSlug(hashToken)
+	}
+	return origin + "/app?d=" + hashToken
 }
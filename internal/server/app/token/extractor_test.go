@@ -5,7 +5,7 @@ import (
 	"testing"
 )
 
-func TestExtractHashFromLink(t *testing.T) {
+func TestLinker_ExtractHashFromLink(t *testing.T) {
 	tests := []struct {
 		url      string
 		wantErr  bool
@@ -26,10 +26,44 @@ func TestExtractHashFromLink(t *testing.T) {
 			wantErr:  true,
 			wantHash: "",
 		},
+		{
+			url:      "https://griz.grizzlytics.com/Summer-Sale",
+			wantErr:  false,
+			wantHash: "summer-sale",
+		},
+		{
+			url:      "https://griz.grizzlytics.com/apps",
+			wantErr:  true,
+			wantHash: "",
+		},
+		{
+			url:      "https://griz.grizzlytics.com/summer/sale",
+			wantErr:  true,
+			wantHash: "",
+		},
+		{
+			url:      "https://griz.grizzlytics.com.evil.com/summer-sale",
+			wantErr:  true,
+			wantHash: "",
+		},
+		{
+			url:      "https://staging.griz.dev/app?d=v015cf58619ad623291c8c3b26c108720f7",
+			wantErr:  false,
+			wantHash: "v015cf58619ad623291c8c3b26c108720f7",
+		},
+		{
+			url:      "https://OLD.griz.dev/summer-sale",
+			wantErr:  false,
+			wantHash: "summer-sale",
+		},
+	}
+	l, err := NewLinker("https://staging.griz.dev", "griz.grizzlytics.com", "old.griz.dev")
+	if !assert.NoError(t, err) {
+		return
 	}
 	for _, tt := range tests {
 		t.Run(tt.url, func(t *testing.T) {
-			link, err := ExtractHashFromLink(tt.url)
+			link, err := l.ExtractHashFromLink(tt.url)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -40,3 +74,83 @@ func TestExtractHashFromLink(t *testing.T) {
 		})
 	}
 }
+
+func TestLinker_BuildLink(t *testing.T) {
+	tests := []struct {
+		token    string
+		wantLink string
+	}{
+		{
+			token:    "v015cf58619ad623291c8c3b26c108720f7",
+			wantLink: "http://localhost:8081/app?d=v015cf58619ad623291c8c3b26c108720f7",
+		},
+		{
+			token:    "Summer-Sale",
+			wantLink: "http://localhost:8081/summer-sale",
+		},
+	}
+	l, err := NewLinker("http://LOCALHOST:8081/")
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			link := l.BuildLink(tt.token)
+			assert.Equal(t, tt.wantLink, link)
+			token, err := l.ExtractHashFromLink(link)
+			if assert.NoError(t, err) {
+				assert.Equal(t, NormalizeSlug(tt.token), token)
+			}
+		})
+	}
+}
+
+func TestNewLinker(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		wantErr bool
+	}{
+		{baseURL: "https://griz.grizzlytics.com", wantErr: false},
+		{baseURL: "https://griz.grizzlytics.com/", wantErr: false},
+		{baseURL: "griz.grizzlytics.com", wantErr: true},
+		{baseURL: "ftp://griz.grizzlytics.com", wantErr: true},
+		{baseURL: "https://griz.grizzlytics.com/app", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.baseURL, func(t *testing.T) {
+			_, err := NewLinker(tt.baseURL)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateDomain(t *testing.T) {
+	tests := []struct {
+		domain  string
+		wantErr bool
+	}{
+		{domain: "qr.example.com", wantErr: false},
+		{domain: "QR.Example.com.", wantErr: false},
+		{domain: "example", wantErr: true},
+		{domain: "127.0.0.1", wantErr: true},
+		{domain: "example.123", wantErr: true},
+		{domain: "https://qr.example.com", wantErr: true},
+		{domain: "qr.example.com:8080", wantErr: true},
+		{domain: "-qr.example.com", wantErr: true},
+		{domain: "qr_code.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			err := ValidateDomain(tt.domain)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
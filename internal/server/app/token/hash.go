@@ -3,35 +3,236 @@ package token
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
-// AES creates tokens by key according to aes crypto library
+// LegacyVersion is prefix of tokens created before key rotation was introduced
+const LegacyVersion = "v01"
+
+// macSize is length of truncated HMAC tag in bytes
+const macSize = 8
+
+var versionValidator = regexp.MustCompile(`^v[0-9]{2}$`)
+
+// Version creates and decodes tokens with single key.
+// Every token starts with version prefix, so it could be decoded after key rotation.
+type Version interface {
+	// Prefix returns version prefix (like v01)
+	Prefix() string
+	// Create returns token as a string
+	Create(id uint64) (string, error)
+	// Decode decodes token to id
+	Decode(token string) (uint64, error)
+}
+
+// AES creates tokens by key according to aes crypto library.
+// Key length defines AES-128, AES-192 or AES-256.
 type AES struct {
+	prefix   string
 	aesblock cipher.Block
 }
 
-// NewAES creates new AES with set key
+var _ Version = AES{}
+
+// NewAES creates new legacy (v01) AES with set 16 bytes key
 func NewAES(key string) (AES, error) {
 	keyB := []byte(key)
 	if len(keyB) != aes.BlockSize {
 		return AES{}, errors.New("key has to be 16 bytes long")
 	}
-	block, err := aes.NewCipher(keyB)
+	return NewAESVersion(LegacyVersion, keyB)
+}
+
+// NewAESVersion creates new AES with version prefix and 16, 24 or 32 bytes key
+func NewAESVersion(prefix string, key []byte) (AES, error) {
+	if !versionValidator.MatchString(prefix) {
+		return AES{}, errors.New("version has wrong format")
+	}
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return AES{}, err
 	}
-	return AES{aesblock: block}, nil
+	return AES{prefix: prefix, aesblock: block}, nil
+}
+
+// Prefix returns version prefix
+func (a AES) Prefix() string {
+	return a.prefix
 }
 
 // Create returns token as a string
 func (a AES) Create(id uint64) (string, error) {
+	dst, err := encryptID(a.aesblock, id)
+	if err != nil {
+		return "", err
+	}
+	return a.prefix + hex.EncodeToString(dst), nil
+}
+
+// Decode decodes token to id (uint64)
+func (a AES) Decode(token string) (uint64, error) {
+	if !strings.HasPrefix(token, a.prefix) {
+		return 0, errors.New("token has wrong format")
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(token, a.prefix))
+	if err != nil {
+		return 0, err
+	}
+	return decryptID(a.aesblock, b)
+}
+
+// AuthAES creates authenticated tokens: AES-256 encrypted id with truncated HMAC-SHA256 tag.
+// Tampered tokens are rejected before decryption.
+type AuthAES struct {
+	prefix   string
+	aesblock cipher.Block
+	macKey   []byte
+}
+
+var _ Version = AuthAES{}
+
+// NewAuthAES creates new AuthAES with version prefix and 32 bytes key
+func NewAuthAES(prefix string, key []byte) (AuthAES, error) {
+	if !versionValidator.MatchString(prefix) {
+		return AuthAES{}, errors.New("version has wrong format")
+	}
+	if len(key) != 32 {
+		return AuthAES{}, errors.New("key has to be 32 bytes long")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return AuthAES{}, err
+	}
+	// separate key for MAC is derived from main key
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("griz hash mac"))
+	return AuthAES{prefix: prefix, aesblock: block, macKey: h.Sum(nil)}, nil
+}
+
+// Prefix returns version prefix
+func (a AuthAES) Prefix() string {
+	return a.prefix
+}
+
+// Create returns token as a string
+func (a AuthAES) Create(id uint64) (string, error) {
+	dst, err := encryptID(a.aesblock, id)
+	if err != nil {
+		return "", err
+	}
+	return a.prefix + hex.EncodeToString(dst) + hex.EncodeToString(a.mac(dst)), nil
+}
+
+// Decode decodes token to id (uint64)
+func (a AuthAES) Decode(token string) (uint64, error) {
+	if !strings.HasPrefix(token, a.prefix) {
+		return 0, errors.New("token has wrong format")
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(token, a.prefix))
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != aes.BlockSize+macSize {
+		return 0, errors.New("decoded token has wrong length")
+	}
+	src, tag := b[:aes.BlockSize], b[aes.BlockSize:]
+	if !hmac.Equal(tag, a.mac(src)) {
+		return 0, errors.New("token is not authentic")
+	}
+	return decryptID(a.aesblock, src)
+}
+
+func (a AuthAES) mac(b []byte) []byte {
+	h := hmac.New(sha256.New, a.macKey)
+	h.Write([]byte(a.prefix))
+	h.Write(b)
+	return h.Sum(nil)[:macSize]
+}
+
+// Keyring creates tokens with current version and decodes tokens of all registered versions
+type Keyring struct {
+	current  Version
+	versions map[string]Version
+}
+
+// NewKeyring creates Keyring. New tokens are created with current version.
+func NewKeyring(current Version, old ...Version) (Keyring, error) {
+	if current == nil {
+		return Keyring{}, errors.New("current version is not set")
+	}
+	versions := map[string]Version{current.Prefix(): current}
+	for _, v := range old {
+		if _, ok := versions[v.Prefix()]; ok {
+			return Keyring{}, fmt.Errorf("version %s is registered twice", v.Prefix())
+		}
+		versions[v.Prefix()] = v
+	}
+	return Keyring{current: current, versions: versions}, nil
+}
+
+// ParseVersion creates Version from spec "prefix:format:key".
+// Formats: aes (16, 24 or 32 bytes key), aes-hmac (32 bytes key).
+func ParseVersion(spec string) (Version, error) {
+	parts := strings.SplitN(spec, ":", 3)
+	if len(parts) != 3 {
+		return nil, errors.New("version spec has to be prefix:format:key")
+	}
+	switch parts[1] {
+	case "aes":
+		return NewAESVersion(parts[0], []byte(parts[2]))
+	case "aes-hmac":
+		return NewAuthAES(parts[0], []byte(parts[2]))
+	}
+	return nil, fmt.Errorf("unknown format %s", parts[1])
+}
+
+// Current returns prefix of version used for new tokens
+func (k Keyring) Current() string {
+	return k.current.Prefix()
+}
+
+// Create returns token of current version
+func (k Keyring) Create(id uint64) (string, error) {
+	return k.current.Create(id)
+}
+
+// Decode decodes token of any registered version.
+// Only canonical tokens (exactly as they were created) are accepted,
+// so every id has the only valid token per version.
+func (k Keyring) Decode(token string) (uint64, error) {
+	if len(token) < 3 {
+		return 0, errors.New("token has wrong format")
+	}
+	v, ok := k.versions[token[:3]]
+	if !ok {
+		return 0, errors.New("token version is unknown")
+	}
+	id, err := v.Decode(token)
+	if err != nil {
+		return 0, err
+	}
+	canonical, err := v.Create(id)
+	if err != nil {
+		return 0, err
+	}
+	if subtle.ConstantTimeCompare([]byte(canonical), []byte(token)) != 1 {
+		return 0, errors.New("token is not canonical")
+	}
+	return id, nil
+}
+
+// encryptID encrypts id padded to single block
+func encryptID(block cipher.Block, id uint64) ([]byte, error) {
 	if id < 1 {
-		return "", errors.New("minimal value for id is 1")
+		return nil, errors.New("minimal value for id is 1")
 	}
 
 	src := strconv.FormatUint(id, 16) //Always max 16 bytes
@@ -39,27 +240,27 @@ func (a AES) Create(id uint64) (string, error) {
 
 	srcBytes := []byte(src)
 	if len(srcBytes) != aes.BlockSize {
-		return "", errors.New("src has to be 16 bytes long")
+		return nil, errors.New("src has to be 16 bytes long")
 	}
 
 	dst := make([]byte, aes.BlockSize)
-	a.aesblock.Encrypt(dst, []byte(src))
-	return "v01" + hex.EncodeToString(dst), nil
+	block.Encrypt(dst, srcBytes)
+	return dst, nil
 }
 
-// Decode decodes token to id (uint64)
-func (a AES) Decode(token string) (uint64, error) {
-	if !strings.HasPrefix(token, "v01") {
-		return 0, errors.New("token has wrong format")
+// decryptID decrypts single block to id
+func decryptID(block cipher.Block, b []byte) (uint64, error) {
+	if len(b) != aes.BlockSize {
+		return 0, errors.New("decoded token has to be 16 bytes")
 	}
-	b, err := hex.DecodeString(strings.TrimLeft(token, "v01"))
+	dst := make([]byte, aes.BlockSize)
+	block.Decrypt(dst, b)
+	id, err := strconv.ParseUint(strings.TrimLeft(string(dst), " "), 16, 64)
 	if err != nil {
 		return 0, err
 	}
-	if len(b) != aes.BlockSize {
-		return 0, errors.New("decoded token has to be 16 bytes")
+	if id < 1 {
+		return 0, errors.New("minimal value for id is 1")
 	}
-	dst := make([]byte, aes.BlockSize)
-	a.aesblock.Decrypt(dst, b)
-	return strconv.ParseUint(strings.TrimLeft(string(dst), " "), 16, 64)
+	return id, nil
 }
@@ -120,3 +120,171 @@ func TestAES_Decode(t *testing.T) {
 		}
 	}
 }
+
+func TestAuthAES_Decode(t *testing.T) {
+	key := []byte("12345678901234567890123456789012")
+	a, err := NewAuthAES("v02", key)
+	if !assert.NoError(t, err) {
+		return
+	}
+	valid, err := a.Create(100)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, valid, 3+32+16)
+
+	flipped := []byte(valid)
+	if flipped[5] == 'a' {
+		flipped[5] = 'b'
+	} else {
+		flipped[5] = 'a'
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantId  uint64
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			token:   valid,
+			wantId:  100,
+			wantErr: false,
+		},
+		{
+			name:    "bit flipped",
+			token:   string(flipped),
+			wantErr: true,
+		},
+		{
+			name:    "without mac",
+			token:   valid[:35],
+			wantErr: true,
+		},
+		{
+			name:    "wrong version",
+			token:   "v03" + valid[3:],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := a.Decode(tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantId, id)
+			}
+		})
+	}
+}
+
+func TestKeyring_Decode(t *testing.T) {
+	legacy, err := NewAES("1234567890123456")
+	if !assert.NoError(t, err) {
+		return
+	}
+	aes256, err := ParseVersion("v02:aes:12345678901234567890123456789012")
+	if !assert.NoError(t, err) {
+		return
+	}
+	current, err := ParseVersion("v03:aes-hmac:abcdefghijklmnopqrstuvwxyz123456")
+	if !assert.NoError(t, err) {
+		return
+	}
+	k, err := NewKeyring(current, legacy, aes256)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	token, err := k.Create(1000000)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "v03", token[:3])
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantId  uint64
+		wantErr bool
+	}{
+		{
+			name:    "legacy",
+			token:   "v0129e576ad8c4e2a9de4a99ebecd032c6c",
+			wantId:  1000000,
+			wantErr: false,
+		},
+		{
+			name:    "current",
+			token:   token,
+			wantId:  1000000,
+			wantErr: false,
+		},
+		{
+			name:    "unknown version",
+			token:   "v0929e576ad8c4e2a9de4a99ebecd032c6c",
+			wantErr: true,
+		},
+		{
+			name:    "too short",
+			token:   "v0",
+			wantErr: true,
+		},
+		{
+			name:    "upper case legacy",
+			token:   "v0129E576AD8C4E2A9DE4A99EBECD032C6C",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := k.Decode(tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantId, id)
+			}
+		})
+	}
+
+	aes256Token, err := aes256.Create(42)
+	if assert.NoError(t, err) {
+		id, err := k.Decode(aes256Token)
+		if assert.NoError(t, err) {
+			assert.Equal(t, uint64(42), id)
+		}
+	}
+
+	_, err = NewKeyring(current, legacy, legacy)
+	assert.Error(t, err)
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "v02:aes:1234567890123456", wantErr: false},
+		{spec: "v02:aes:12345678901234567890123456789012", wantErr: false},
+		{spec: "v02:aes:123", wantErr: true},
+		{spec: "v02:aes-hmac:1234567890123456", wantErr: true},
+		{spec: "v02:des:1234567890123456", wantErr: true},
+		{spec: "version2:aes:1234567890123456", wantErr: true},
+		{spec: "v02", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParseVersion(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
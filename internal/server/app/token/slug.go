@@ -0,0 +1,64 @@
+package token
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+)
+
+var slugValidator = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,30}[a-z0-9]$`)
+
+var hashValidator = regexp.MustCompile(`^v[0-9]{2}[0-9a-f]{32,}$`)
+
+// reservedSlugs are paths which are used by griz itself
+var reservedSlugs = map[string]struct{}{
+	"admin":   {},
+	"api":     {},
+	"app":     {},
+	"apps":    {},
+	"assets":  {},
+	"blog":    {},
+	"docs":    {},
+	"healthz": {},
+	"help":    {},
+	"login":   {},
+	"logout":  {},
+	"metrics": {},
+	"p":       {},
+	"public":  {},
+	"qr":      {},
+	"readyz":  {},
+	"s":       {},
+	"signup":  {},
+	"static":  {},
+	"support": {},
+	"version": {},
+	"www":     {},
+}
+
+// NormalizeSlug returns slug in canonical (lower) case
+func NormalizeSlug(slug string) string {
+	return strings.ToLower(strings.TrimSpace(slug))
+}
+
+// ValidateSlug checks if slug could be claimed.
+// Slug is 3-32 symbols long, contains latin letters, digits and hyphens.
+func ValidateSlug(slug string) error {
+	slug = NormalizeSlug(slug)
+	if !slugValidator.MatchString(slug) {
+		return errors.New("slug has to be 3-32 symbols long and contain only letters, digits and hyphens")
+	}
+	if strings.Contains(slug, "--") {
+		return errors.New("slug couldn't contain double hyphens")
+	}
+	if _, ok := reservedSlugs[slug]; ok {
+		return errors.New("slug is reserved")
+	}
+	return nil
+}
+
+// IsHash returns true if token looks like hash (not slug).
+// Hashes are always longer than slugs.
+func IsHash(token string) bool {
+	return hashValidator.MatchString(token)
+}
@@ -0,0 +1,53 @@
+package token
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateSlug(t *testing.T) {
+	tests := []struct {
+		slug    string
+		wantErr bool
+	}{
+		{slug: "summer-sale", wantErr: false},
+		{slug: "Summer-Sale", wantErr: false},
+		{slug: "abc", wantErr: false},
+		{slug: "ab", wantErr: true},
+		{slug: "abcdefghijklmnopqrstuvwxyz0123456", wantErr: true},
+		{slug: "-abc", wantErr: true},
+		{slug: "abc-", wantErr: true},
+		{slug: "ab--c", wantErr: true},
+		{slug: "ab_c", wantErr: true},
+		{slug: "apps", wantErr: true},
+		{slug: "API", wantErr: true},
+		{slug: "healthz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			err := ValidateSlug(tt.slug)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestIsHash(t *testing.T) {
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{token: "v015cf58619ad623291c8c3b26c108720f7", want: true},
+		{token: "v015CF58619AD623291C8C3B26C108720F7", want: false},
+		{token: "v015cf58619ad623291c8c3b26c1087", want: false},
+		{token: "summer-sale", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsHash(tt.token))
+		})
+	}
+}
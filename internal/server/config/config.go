@@ -0,0 +1,307 @@
+package config
+
+import (
+	"github.com/hotafrika/griz-backend/internal/server/app/token"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/instagram"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/ratelimit"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Insecure default keys. They are accepted in dev mode only
+const (
+	DevPasswordKey  = "abc"
+	DevAuthTokenKey = "abc"
+	DevHashKey      = "1234567812345678"
+)
+
+// MinAdminTokenLength is minimal length of admin token outside dev mode
+const MinAdminTokenLength = 32
+
+// redacted replaces secrets in printed configuration
+const redacted = "[REDACTED]"
+
+// Config is configuration of server and migrator.
+// Every field is set by file key, env variable and flag named as file key with dashes.
+// Precedence is flags, env, file, defaults.
+type Config struct {
+	Dev bool `yaml:"dev" toml:"dev" env:"DEV_MODE" usage:"allow insecure default keys"`
+
+	BindAddress         string   `yaml:"bind_address" toml:"bind_address" env:"BACKEND_ADDRESS" usage:"address of API"`
+	RequestTimeout      Duration `yaml:"request_timeout" toml:"request_timeout" env:"REQUEST_TIMEOUT" usage:"timeout of API request"`
+	ParseRequestTimeout Duration `yaml:"parse_request_timeout" toml:"parse_request_timeout" env:"PARSE_REQUEST_TIMEOUT" usage:"timeout of API request which parses social pages"`
+	ReadTimeout         Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT" usage:"max time to read whole request with body"`
+	WriteTimeout        Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" usage:"max time from end of request headers to end of response"`
+	IdleTimeout         Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" usage:"max time of keep-alive connection between requests"`
+	ShutdownTimeout     Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"max time to finish in-flight requests and workers on stop"`
+	ReadinessTimeout    Duration `yaml:"readiness_timeout" toml:"readiness_timeout" env:"READINESS_TIMEOUT" usage:"timeout of each readiness check"`
+	ReadinessCacheTTL   Duration `yaml:"readiness_cache_ttl" toml:"readiness_cache_ttl" env:"READINESS_CACHE_TTL" usage:"time result of readiness checks is reused"`
+	ReadinessURLs       []string `yaml:"readiness_urls" toml:"readiness_urls" env:"READINESS_URLS" usage:"comma separated upstream URLs checked by /readyz"`
+	MetricsEnabled      bool     `yaml:"metrics_enabled" toml:"metrics_enabled" env:"METRICS_ENABLED" usage:"serve Prometheus metrics on public /metrics without auth"`
+	TracingEndpoint     string   `yaml:"tracing_endpoint" toml:"tracing_endpoint" env:"TRACING_ENDPOINT" usage:"URL of OTLP/HTTP collector, e.g. http://localhost:4318, empty disables tracing"`
+	TracingSampleRatio  float64  `yaml:"tracing_sample_ratio" toml:"tracing_sample_ratio" env:"TRACING_SAMPLE_RATIO" usage:"fraction of traces started by server which are sampled, from 0 to 1"`
+	LogLevel            int      `yaml:"log_level" toml:"log_level" env:"LOG_LEVEL" usage:"from -1 (trace) up to 5 (panic), 6 disables log"`
+
+	RateLimitStore  string          `yaml:"rate_limit_store" toml:"rate_limit_store" env:"RATE_LIMIT_STORE" usage:"store of rate limits: memory of instance or shared cache"`
+	RateLimitToken  ratelimit.Limit `yaml:"rate_limit_token" toml:"rate_limit_token" env:"RATE_LIMIT_TOKEN" usage:"login requests by client IP as requests/period, e.g. 10/m, off disables limit"`
+	RateLimitScan   ratelimit.Limit `yaml:"rate_limit_scan" toml:"rate_limit_scan" env:"RATE_LIMIT_SCAN" usage:"scans of social posts by client IP"`
+	RateLimitPublic ratelimit.Limit `yaml:"rate_limit_public" toml:"rate_limit_public" env:"RATE_LIMIT_PUBLIC" usage:"public API, hosted pages, embedded images and /readyz requests by client IP"`
+	RateLimitUser   ratelimit.Limit `yaml:"rate_limit_user" toml:"rate_limit_user" env:"RATE_LIMIT_USER" usage:"authenticated API requests by user"`
+	TrustedProxies  []string        `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES" usage:"comma separated IPs and CIDRs of proxies whose X-Forwarded-For is trusted"`
+
+	LoginUserThreshold int      `yaml:"login_user_threshold" toml:"login_user_threshold" env:"LOGIN_USER_THRESHOLD" usage:"failed logins of username which lock it, backoff starts after third of them"`
+	LoginIPThreshold   int      `yaml:"login_ip_threshold" toml:"login_ip_threshold" env:"LOGIN_IP_THRESHOLD" usage:"failed logins from client IP which lock it"`
+	LoginBackoff       Duration `yaml:"login_backoff" toml:"login_backoff" env:"LOGIN_BACKOFF" usage:"first delay after failed login, doubled by every next failure"`
+	LoginLockout       Duration `yaml:"login_lockout" toml:"login_lockout" env:"LOGIN_LOCKOUT" usage:"time of lock after threshold of failed logins"`
+	AdminToken         string   `yaml:"admin_token" toml:"admin_token" env:"ADMIN_TOKEN" secret:"true" usage:"token of /admin operations, empty disables them"`
+
+	InstagramImageHosts []string `yaml:"instagram_image_hosts" toml:"instagram_image_hosts" env:"INSTAGRAM_IMAGE_HOSTS" usage:"comma separated hosts of instagram images, *.example.com matches subdomains"`
+	ImageMaxMB          int      `yaml:"image_max_mb" toml:"image_max_mb" env:"IMAGE_MAX_MB" usage:"max size of downloaded image of post"`
+	ImageMaxRedirects   int      `yaml:"image_max_redirects" toml:"image_max_redirects" env:"IMAGE_MAX_REDIRECTS" usage:"max redirects of image download"`
+	QRWorkers           int      `yaml:"qr_workers" toml:"qr_workers" env:"QR_WORKERS" usage:"images of posts downloaded and decoded at once by all requests"`
+	QRRequestWorkers    int      `yaml:"qr_request_workers" toml:"qr_request_workers" env:"QR_REQUEST_WORKERS" usage:"images of post downloaded and decoded at once by one request"`
+	QRImageTimeout      Duration `yaml:"qr_image_timeout" toml:"qr_image_timeout" env:"QR_IMAGE_TIMEOUT" usage:"timeout of download and decoding of one image"`
+
+	AuthTokenTTL  Duration `yaml:"auth_token_ttl" toml:"auth_token_ttl" env:"CACHE_AUTH_TOKEN_TTL" usage:"TTL of auth token"`
+	HashTTL       Duration `yaml:"hash_ttl" toml:"hash_ttl" env:"CACHE_HASH_TTL" usage:"TTL of cached hash"`
+	SocialLinkTTL Duration `yaml:"social_link_ttl" toml:"social_link_ttl" env:"CACHE_SOCIAL_LINK_TTL" usage:"TTL of cached social link"`
+	ImageCacheMB  int      `yaml:"image_cache_mb" toml:"image_cache_mb" env:"IMAGE_CACHE_MB" usage:"max size of rendered QR images kept in memory, 0 disables cache"`
+
+	PasswordKey        string   `yaml:"password_key" toml:"password_key" env:"PASSWORD_ENCRYPTION_KEY" secret:"true" usage:"key of password hashes"`
+	AuthTokenKey       string   `yaml:"auth_token_key" toml:"auth_token_key" env:"AUTH_TOKEN_ENCRYPTION_KEY" secret:"true" usage:"key of auth tokens"`
+	HashKey            string   `yaml:"hash_key" toml:"hash_key" env:"HASH_ENCRYPTION_KEY" secret:"true" usage:"16 bytes key of legacy v01 hashes"`
+	HashKeys           []string `yaml:"hash_keys" toml:"hash_keys" env:"HASH_ENCRYPTION_KEYS" secret:"true" usage:"comma separated key versions as prefix:format:key"`
+	HashCurrentVersion string   `yaml:"hash_current_version" toml:"hash_current_version" env:"HASH_CURRENT_VERSION" usage:"key version of new hashes"`
+
+	LinkBaseURL      string   `yaml:"link_base_url" toml:"link_base_url" env:"LINK_BASE_URL" usage:"scheme and host of new links"`
+	LinkAliasDomains []string `yaml:"link_alias_domains" toml:"link_alias_domains" env:"LINK_ALIAS_DOMAINS" usage:"comma separated legacy domains of links"`
+
+	TrashRetention     Duration `yaml:"trash_retention" toml:"trash_retention" env:"TRASH_RETENTION" usage:"time deleted codes are kept in trash"`
+	TrashPurgeInterval Duration `yaml:"trash_purge_interval" toml:"trash_purge_interval" env:"TRASH_PURGE_INTERVAL" usage:"interval between purges of expired trash"`
+	ScanFlushInterval  Duration `yaml:"scan_flush_interval" toml:"scan_flush_interval" env:"SCAN_FLUSH_INTERVAL" usage:"interval between writes of counted scans to database"`
+
+	PausedCodeURL   string `yaml:"paused_code_url" toml:"paused_code_url" env:"PAUSED_CODE_URL" usage:"default fallback URL of paused codes"`
+	MaintenancePage string `yaml:"maintenance_page" toml:"maintenance_page" env:"MAINTENANCE_PAGE" usage:"path to HTML page of paused codes without fallback URL"`
+
+	DBDriver           string `yaml:"db_driver" toml:"db_driver" env:"DB_DRIVER" usage:"database driver"`
+	DBConnectionString string `yaml:"db_connection_string" toml:"db_connection_string" env:"DB_CONNECTION_STRING" secret:"true" usage:"database connection string"`
+}
+
+// Default returns configuration without file, env and flags
+func Default() Config {
+	return Config{
+		BindAddress:         ":8081",
+		RequestTimeout:      Duration(10 * time.Second),
+		ParseRequestTimeout: Duration(20 * time.Second),
+		ReadTimeout:         Duration(30 * time.Second),
+		WriteTimeout:        Duration(60 * time.Second),
+		IdleTimeout:         Duration(120 * time.Second),
+		ShutdownTimeout:     Duration(30 * time.Second),
+		ReadinessTimeout:    Duration(2 * time.Second),
+		ReadinessCacheTTL:   Duration(5 * time.Second),
+		TracingSampleRatio:  1,
+		RateLimitStore:      "memory",
+		RateLimitToken:      ratelimit.Limit{Requests: 10, Per: time.Minute},
+		RateLimitScan:       ratelimit.Limit{Requests: 20, Per: time.Minute},
+		RateLimitPublic:     ratelimit.Limit{Requests: 120, Per: time.Minute},
+		RateLimitUser:       ratelimit.Limit{Requests: 600, Per: time.Minute},
+		LogLevel:            -1,
+		LoginUserThreshold:  10,
+		LoginIPThreshold:    50,
+		LoginBackoff:        Duration(time.Second),
+		LoginLockout:        Duration(15 * time.Minute),
+		InstagramImageHosts: instagram.ImageHosts,
+		ImageMaxMB:          10,
+		ImageMaxRedirects:   3,
+		QRWorkers:           instagram.DefaultOptions.Workers,
+		QRRequestWorkers:    instagram.DefaultOptions.RequestWorkers,
+		QRImageTimeout:      Duration(instagram.DefaultOptions.ImageTimeout),
+		AuthTokenTTL:        Duration(1800 * time.Second),
+		HashTTL:             Duration(1800 * time.Second),
+		SocialLinkTTL:       Duration(1800 * time.Second),
+		ImageCacheMB:        64,
+		PasswordKey:         DevPasswordKey,
+		AuthTokenKey:        DevAuthTokenKey,
+		HashKey:             DevHashKey,
+		HashCurrentVersion:  token.LegacyVersion,
+		LinkBaseURL:         token.DefaultBaseURL,
+		TrashRetention:      Duration(30 * 24 * time.Hour),
+		TrashPurgeInterval:  Duration(time.Hour),
+		ScanFlushInterval:   Duration(10 * time.Second),
+		DBDriver:            "sqlite3",
+		DBConnectionString:  "db.sqlite3",
+	}
+}
+
+// Validate checks all fields and returns all problems at once
+func (c Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, errors.Errorf(format, args...).Error())
+	}
+
+	if c.BindAddress == "" {
+		add("bind_address is empty")
+	}
+	if c.LogLevel < -1 || c.LogLevel > 6 {
+		add("log_level has to be between -1 and 6")
+	}
+	positive := []struct {
+		name  string
+		value Duration
+	}{
+		{"request_timeout", c.RequestTimeout},
+		{"parse_request_timeout", c.ParseRequestTimeout},
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+		{"idle_timeout", c.IdleTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+		{"readiness_timeout", c.ReadinessTimeout},
+		{"readiness_cache_ttl", c.ReadinessCacheTTL},
+		{"login_backoff", c.LoginBackoff},
+		{"login_lockout", c.LoginLockout},
+		{"qr_image_timeout", c.QRImageTimeout},
+		{"auth_token_ttl", c.AuthTokenTTL},
+		{"hash_ttl", c.HashTTL},
+		{"social_link_ttl", c.SocialLinkTTL},
+		{"trash_retention", c.TrashRetention},
+		{"trash_purge_interval", c.TrashPurgeInterval},
+		{"scan_flush_interval", c.ScanFlushInterval},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			add("%s has to be positive", p.name)
+		}
+	}
+	// responses of slow requests would be cut by connection deadline
+	if c.WriteTimeout <= c.RequestTimeout || c.WriteTimeout <= c.ParseRequestTimeout {
+		add("write_timeout has to be longer than request_timeout and parse_request_timeout")
+	}
+	if c.LoginUserThreshold <= 0 || c.LoginIPThreshold <= 0 {
+		add("login_user_threshold and login_ip_threshold have to be positive")
+	}
+	if len(c.InstagramImageHosts) == 0 {
+		add("instagram_image_hosts are required")
+	}
+	if c.ImageMaxMB <= 0 {
+		add("image_max_mb has to be positive")
+	}
+	if c.ImageMaxRedirects < 0 {
+		add("image_max_redirects couldn't be negative")
+	}
+	if c.QRWorkers <= 0 || c.QRRequestWorkers <= 0 {
+		add("qr_workers and qr_request_workers have to be positive")
+	}
+	if c.ImageCacheMB < 0 {
+		add("image_cache_mb couldn't be negative")
+	}
+
+	if c.PasswordKey == "" {
+		add("password_key is required")
+	}
+	if c.AuthTokenKey == "" {
+		add("auth_token_key is required")
+	}
+	if len(c.HashKey) != 16 {
+		add("hash_key has to be 16 bytes")
+	}
+	if !c.Dev {
+		if c.PasswordKey == DevPasswordKey {
+			add("password_key has insecure default value, set own key or enable dev mode")
+		}
+		if c.AuthTokenKey == DevAuthTokenKey {
+			add("auth_token_key has insecure default value, set own key or enable dev mode")
+		}
+		if c.HashKey == DevHashKey {
+			add("hash_key has insecure default value, set own key or enable dev mode")
+		}
+		if c.AdminToken != "" && len(c.AdminToken) < MinAdminTokenLength {
+			add("admin_token has to be at least %d bytes outside dev mode", MinAdminTokenLength)
+		}
+	}
+	if c.HashCurrentVersion == "" {
+		add("hash_current_version is required")
+	}
+
+	if c.PausedCodeURL != "" {
+		u, err := url.ParseRequestURI(c.PausedCodeURL)
+		if err != nil || u.Host == "" {
+			add("paused_code_url has to be absolute URL")
+		}
+	}
+	if c.TracingEndpoint != "" {
+		u, err := url.ParseRequestURI(c.TracingEndpoint)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			add("tracing_endpoint has to be absolute http or https URL")
+		}
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		add("tracing_sample_ratio has to be between 0 and 1")
+	}
+	if c.RateLimitStore != "memory" && c.RateLimitStore != "cache" {
+		add("rate_limit_store has to be memory or cache")
+	}
+	if _, err := ratelimit.ParseTrustedProxies(c.TrustedProxies); err != nil {
+		add("trusted_proxies: %v", err)
+	}
+	for _, u := range c.ReadinessURLs {
+		parsed, err := url.ParseRequestURI(u)
+		if err != nil || parsed.Host == "" {
+			add("readiness_urls has to contain absolute URLs")
+			break
+		}
+	}
+	if c.DBDriver == "" {
+		add("db_driver is required")
+	}
+	if c.DBConnectionString == "" {
+		add("db_connection_string is required")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Redacted returns copy of configuration with hidden secrets.
+// Prefixes and formats of hash key versions are kept.
+func (c Config) Redacted() Config {
+	r := c
+	for _, f := range fields(&r) {
+		if !f.secret {
+			continue
+		}
+		switch f.value.Kind() {
+		case reflect.String:
+			if f.value.String() != "" {
+				f.value.SetString(redacted)
+			}
+		case reflect.Slice:
+			specs := make([]string, 0, f.value.Len())
+			for _, spec := range f.value.Interface().([]string) {
+				parts := strings.SplitN(spec, ":", 3)
+				parts[len(parts)-1] = redacted
+				specs = append(specs, strings.Join(parts, ":"))
+			}
+			f.value.Set(reflect.ValueOf(specs))
+		}
+	}
+	return r
+}
+
+// Print writes configuration with hidden secrets as YAML file
+func (c Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	err := enc.Encode(c.Redacted())
+	if err != nil {
+		return errors.Wrap(err, "unable to encode configuration: ")
+	}
+	return enc.Close()
+}
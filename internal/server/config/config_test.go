@@ -0,0 +1,236 @@
+package config
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns lookup function of fixed env variables
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "1800", want: 1800 * time.Second},
+		{in: "90s", want: 90 * time.Second},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "30d", want: 30 * 24 * time.Hour},
+		{in: "", wantErr: true},
+		{in: "ten", wantErr: true},
+		{in: "1.5d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := ParseDuration(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, d.Duration())
+		})
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "griz.yaml")
+	assert.NoError(t, os.WriteFile(yamlFile, []byte(`
+bind_address: ":9000"
+request_timeout: 15s
+log_level: 2
+hash_keys:
+  - v02:aes-hmac:12345678901234567890123456789012
+`), 0o600))
+	tomlFile := filepath.Join(dir, "griz.toml")
+	assert.NoError(t, os.WriteFile(tomlFile, []byte(`
+bind_address = ":9000"
+request_timeout = 15
+log_level = 2
+hash_keys = ["v02:aes-hmac:12345678901234567890123456789012"]
+`), 0o600))
+
+	tests := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		wantAddress string
+		wantTimeout time.Duration
+		wantLevel   int
+	}{
+		{
+			name:        "defaults",
+			wantAddress: ":8081",
+			wantTimeout: 10 * time.Second,
+			wantLevel:   -1,
+		},
+		{
+			name:        "yaml file",
+			args:        []string{"--config", yamlFile},
+			wantAddress: ":9000",
+			wantTimeout: 15 * time.Second,
+			wantLevel:   2,
+		},
+		{
+			name:        "toml file from env",
+			env:         map[string]string{FileEnv: tomlFile},
+			wantAddress: ":9000",
+			wantTimeout: 15 * time.Second,
+			wantLevel:   2,
+		},
+		{
+			name:        "env overrides file",
+			args:        []string{"--config", yamlFile},
+			env:         map[string]string{"BACKEND_ADDRESS": ":9001", "REQUEST_TIMEOUT": "20"},
+			wantAddress: ":9001",
+			wantTimeout: 20 * time.Second,
+			wantLevel:   2,
+		},
+		{
+			name:        "flags override env",
+			args:        []string{"--config", yamlFile, "--bind-address", ":9002", "--log-level=3"},
+			env:         map[string]string{"BACKEND_ADDRESS": ":9001"},
+			wantAddress: ":9002",
+			wantTimeout: 15 * time.Second,
+			wantLevel:   3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := Load("griz", tt.args, env(tt.env))
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantAddress, cfg.BindAddress)
+				assert.Equal(t, tt.wantTimeout, cfg.RequestTimeout.Duration())
+				assert.Equal(t, tt.wantLevel, cfg.LogLevel)
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	misspelled := filepath.Join(dir, "griz.yaml")
+	assert.NoError(t, os.WriteFile(misspelled, []byte("bind_adress: \":9000\"\n"), 0o600))
+	unknownFormat := filepath.Join(dir, "griz.json")
+	assert.NoError(t, os.WriteFile(unknownFormat, []byte("{}"), 0o600))
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "malformed number", env: map[string]string{"LOG_LEVEL": "debug"}},
+		{name: "malformed duration", env: map[string]string{"CACHE_HASH_TTL": "30 minutes"}},
+		{name: "malformed ratio", env: map[string]string{"TRACING_SAMPLE_RATIO": "10%"}},
+		{name: "malformed flag", args: []string{"--trash-retention", "month"}},
+		{name: "unknown flag", args: []string{"--bind"}},
+		{name: "misspelled key", args: []string{"--config", misspelled}},
+		{name: "unknown format", args: []string{"--config", unknownFormat}},
+		{name: "missing file", args: []string{"--config", filepath.Join(dir, "none.yaml")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Load("griz", tt.args, env(tt.env))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoad_LegacyTrashRetention(t *testing.T) {
+	cfg, _, err := Load("griz", nil, env(map[string]string{"TRASH_RETENTION_DAYS": "7"}))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 7*24*time.Hour, cfg.TrashRetention.Duration())
+	}
+
+	cfg, _, err = Load("griz", nil, env(map[string]string{"TRASH_RETENTION_DAYS": "7", "TRASH_RETENTION": "48h"}))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 48*time.Hour, cfg.TrashRetention.Duration())
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := Default()
+	assert.False(t, cfg.MetricsEnabled, "metrics are served without auth, so they are opt-in")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	secure := func(c *Config) {
+		c.PasswordKey = "password-secret"
+		c.AuthTokenKey = "auth-token-secret"
+		c.HashKey = "abcdefghijklmnop"
+	}
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{name: "defaults outside dev mode", modify: func(c *Config) {}, wantErr: true},
+		{name: "defaults in dev mode", modify: func(c *Config) { c.Dev = true }},
+		{name: "own keys", modify: secure},
+		{name: "default password key", modify: func(c *Config) { secure(c); c.PasswordKey = DevPasswordKey }, wantErr: true},
+		{name: "default hash key", modify: func(c *Config) { secure(c); c.HashKey = DevHashKey }, wantErr: true},
+		{name: "empty auth key in dev mode", modify: func(c *Config) { c.Dev = true; c.AuthTokenKey = "" }, wantErr: true},
+		{name: "short hash key", modify: func(c *Config) { secure(c); c.HashKey = "short" }, wantErr: true},
+		{name: "admin token", modify: func(c *Config) { secure(c); c.AdminToken = strings.Repeat("a", MinAdminTokenLength) }},
+		{name: "short admin token", modify: func(c *Config) { secure(c); c.AdminToken = "admin" }, wantErr: true},
+		{name: "short admin token in dev mode", modify: func(c *Config) { c.Dev = true; c.AdminToken = "admin" }},
+		{name: "log level", modify: func(c *Config) { secure(c); c.LogLevel = 7 }, wantErr: true},
+		{name: "zero timeout", modify: func(c *Config) { secure(c); c.RequestTimeout = 0 }, wantErr: true},
+		{name: "write timeout shorter than scan", modify: func(c *Config) { secure(c); c.WriteTimeout = c.ParseRequestTimeout }, wantErr: true},
+		{name: "relative paused URL", modify: func(c *Config) { secure(c); c.PausedCodeURL = "/paused" }, wantErr: true},
+		{name: "tracing endpoint", modify: func(c *Config) { secure(c); c.TracingEndpoint = "http://localhost:4318" }},
+		{name: "tracing endpoint without scheme", modify: func(c *Config) { secure(c); c.TracingEndpoint = "localhost:4318" }, wantErr: true},
+		{name: "sample ratio", modify: func(c *Config) { secure(c); c.TracingSampleRatio = 1.5 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConfig_Print(t *testing.T) {
+	cfg := Default()
+	cfg.PasswordKey = "password-secret"
+	cfg.HashKeys = []string{"v02:aes-hmac:12345678901234567890123456789012"}
+	cfg.TrashRetention = Duration(48 * time.Hour)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, cfg.Print(buf))
+	out := buf.String()
+	assert.NotContains(t, out, "password-secret")
+	assert.NotContains(t, out, "12345678901234567890123456789012")
+	assert.Contains(t, out, "password_key: '[REDACTED]'")
+	assert.Contains(t, out, "v02:aes-hmac:[REDACTED]")
+	assert.Contains(t, out, "trash_retention: 48h0m0s")
+	assert.Equal(t, "password-secret", cfg.PasswordKey, "original config is not changed")
+	assert.Equal(t, "v02:aes-hmac:12345678901234567890123456789012", cfg.HashKeys[0])
+
+	// printed configuration is loaded back
+	file := filepath.Join(t.TempDir(), "printed.yaml")
+	assert.NoError(t, os.WriteFile(file, buf.Bytes(), 0o600))
+	loaded, _, err := Load("griz", []string{"--config", file}, env(nil))
+	if assert.NoError(t, err) {
+		assert.Equal(t, cfg.TrashRetention, loaded.TrashRetention)
+	}
+}
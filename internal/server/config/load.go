@@ -0,0 +1,282 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnv is env variable with path to configuration file. It is overridden by --config flag
+const FileEnv = "CONFIG_FILE"
+
+// legacyTrashRetentionEnv is env variable of trash retention in days used before TRASH_RETENTION
+const legacyTrashRetentionEnv = "TRASH_RETENTION_DAYS"
+
+// Options are command line options which are not part of configuration
+type Options struct {
+	// File is path to YAML or TOML configuration file
+	File string
+	// PrintConfig asks to print configuration with hidden secrets and exit
+	PrintConfig bool
+}
+
+// Load reads configuration from defaults, file, env and command line arguments of program.
+// Configuration isn't validated, so it could be printed to find the problem.
+func Load(program string, args []string, lookupEnv func(string) (string, bool)) (Config, Options, error) {
+	cfg := Default()
+	opts := Options{}
+	opts.File, _ = lookupEnv(FileEnv)
+
+	fs := flag.NewFlagSet(program, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Func("config", "path to YAML or TOML configuration file", func(s string) error {
+		opts.File = s
+		return nil
+	})
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print configuration with hidden secrets and exit")
+
+	// flags are applied after file and env
+	type flagValue struct {
+		field reflect.Value
+		value string
+	}
+	var flagValues []flagValue
+	for _, f := range fields(&cfg) {
+		f := f
+		fs.Var(&fieldFlag{
+			isBool: f.value.Kind() == reflect.Bool,
+			set: func(s string) error {
+				// value is checked at once to report wrong flag
+				if err := setField(reflect.New(f.value.Type()).Elem(), s); err != nil {
+					return err
+				}
+				flagValues = append(flagValues, flagValue{field: f.value, value: s})
+				return nil
+			},
+		}, f.flag, f.usage)
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return cfg, opts, err
+		}
+		return cfg, opts, errors.Wrap(err, "unable to parse flags: ")
+	}
+	if fs.NArg() > 0 {
+		return cfg, opts, errors.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if opts.File != "" {
+		err = loadFile(&cfg, opts.File)
+		if err != nil {
+			return cfg, opts, err
+		}
+	}
+
+	err = loadEnv(&cfg, lookupEnv)
+	if err != nil {
+		return cfg, opts, err
+	}
+
+	for _, fv := range flagValues {
+		// values were checked during parsing
+		_ = setField(fv.field, fv.value)
+	}
+
+	return cfg, opts, nil
+}
+
+// MustLoad loads and validates configuration of running program.
+// Program exits after --help and --print-config, and on invalid configuration.
+func MustLoad() Config {
+	program := filepath.Base(os.Args[0])
+	cfg, opts, err := Load(program, os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		Usage(program, os.Stdout)
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.PrintConfig {
+		err = cfg.Print(os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	// printed configuration is validated too, so exit code shows if it is usable
+	err = cfg.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.PrintConfig {
+		os.Exit(0)
+	}
+	return cfg
+}
+
+// Usage writes description of flags and env variables
+func Usage(program string, w io.Writer) {
+	fmt.Fprintf(w, "Usage of %s:\n", program)
+	fmt.Fprintf(w, "  --config string\n\tpath to YAML or TOML configuration file (env %s)\n", FileEnv)
+	fmt.Fprintf(w, "  --print-config\n\tprint configuration with hidden secrets and exit\n")
+	cfg := Default()
+	for _, f := range fields(&cfg) {
+		fmt.Fprintf(w, "  --%s\n\t%s (env %s, default %q)\n", f.flag, f.usage, f.env, formatField(f.value))
+	}
+}
+
+// loadFile decodes YAML or TOML file by its extension. Unknown keys are rejected as misspelled
+func loadFile(cfg *Config, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "unable to read configuration file: ")
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+		if err != nil && err != io.EOF {
+			return errors.Wrapf(err, "unable to decode %s: ", path)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(b), cfg)
+		if err != nil {
+			return errors.Wrapf(err, "unable to decode %s: ", path)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return errors.Errorf("unable to decode %s: unknown key %s", path, undecoded[0])
+		}
+	default:
+		return errors.Errorf("configuration file %s has to be .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// loadEnv sets fields from env variables. Malformed values are errors, not ignored
+func loadEnv(cfg *Config, lookupEnv func(string) (string, bool)) error {
+	for _, f := range fields(cfg) {
+		v, ok := lookupEnv(f.env)
+		if !ok {
+			continue
+		}
+		err := setField(f.value, v)
+		if err != nil {
+			return errors.Wrapf(err, "env %s: ", f.env)
+		}
+	}
+
+	if _, ok := lookupEnv("TRASH_RETENTION"); !ok {
+		if v, ok := lookupEnv(legacyTrashRetentionEnv); ok {
+			days, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return errors.Wrapf(err, "env %s: ", legacyTrashRetentionEnv)
+			}
+			cfg.TrashRetention = Duration(time.Duration(days) * 24 * time.Hour)
+		}
+	}
+	return nil
+}
+
+// field is configurable field of Config
+type field struct {
+	value  reflect.Value
+	env    string
+	flag   string
+	usage  string
+	secret bool
+}
+
+// fields returns configurable fields of cfg in declaration order
+func fields(cfg *Config) []field {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	res := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		res = append(res, field{
+			value:  v.Field(i),
+			env:    sf.Tag.Get("env"),
+			flag:   strings.ReplaceAll(sf.Tag.Get("yaml"), "_", "-"),
+			usage:  sf.Tag.Get("usage"),
+			secret: sf.Tag.Get("secret") == "true",
+		})
+	}
+	return res
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setField parses s to field value. Lists are comma separated
+func setField(v reflect.Value, s string) error {
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		i, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return errors.Errorf("invalid number %q", s)
+		}
+		v.SetInt(int64(i))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return errors.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return errors.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		list := make([]string, 0)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return errors.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// formatField returns value in form accepted by setField
+func formatField(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// fieldFlag is flag.Value of configuration field
+type fieldFlag struct {
+	isBool bool
+	set    func(string) error
+}
+
+func (f *fieldFlag) String() string { return "" }
+
+func (f *fieldFlag) Set(s string) error { return f.set(s) }
+
+// IsBoolFlag allows --dev without value
+func (f *fieldFlag) IsBoolFlag() bool { return f.isBool }
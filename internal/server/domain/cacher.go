@@ -13,3 +13,10 @@ type Cacher interface {
 	Set(context.Context, fmt.Stringer, string, time.Duration) error
 	Delete(context.Context, fmt.Stringer) error
 }
+
+// ImageCacher keeps rendered images. Old images are evicted when cache is full
+type ImageCacher interface {
+	GetImage(context.Context, fmt.Stringer) ([]byte, error)
+	SetImage(context.Context, fmt.Stringer, []byte) error
+	DeleteImage(context.Context, fmt.Stringer) error
+}
@@ -0,0 +1,15 @@
+package entities
+
+import "time"
+
+// Campaign groups codes of user
+type Campaign struct {
+	ID          uint64
+	UserID      uint64
+	Name        string
+	Description string
+	CodeCount   uint64 // number of codes in campaign
+	ScanCount   uint64 // total scans of codes in campaign
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -2,11 +2,61 @@ package entities
 
 import "time"
 
+// CodeType defines what kind of content is stored in QR code
+type CodeType string
+
+const (
+	CodeTypeURL   CodeType = "url"
+	CodeTypeVCard CodeType = "vcard"
+	CodeTypeWiFi  CodeType = "wifi"
+	CodeTypeEmail CodeType = "email"
+	CodeTypeSMS   CodeType = "sms"
+	CodeTypeGeo   CodeType = "geo"
+	CodeTypeText  CodeType = "text"
+)
+
+// IsValid returns true if type is known
+func (t CodeType) IsValid() bool {
+	switch t {
+	case CodeTypeURL, CodeTypeVCard, CodeTypeWiFi, CodeTypeEmail, CodeTypeSMS, CodeTypeGeo, CodeTypeText:
+		return true
+	}
+	return false
+}
+
+// IsStatic returns true if QR contains payload itself instead of griz link.
+// Static codes couldn't be changed after printing.
+func (t CodeType) IsStatic() bool {
+	switch t {
+	case CodeTypeWiFi, CodeTypeEmail, CodeTypeSMS, CodeTypeGeo, CodeTypeText:
+		return true
+	}
+	return false
+}
+
 type Code struct {
-	ID        uint64
-	UserID    uint64
-	SrcURL    string
-	Hash      string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID            uint64
+	UserID        uint64
+	Type          CodeType
+	SrcURL        string
+	Payload       string // JSON of typed payload. Empty for url codes
+	Hash          string
+	Slug          string // Optional vanity slug for short link
+	Name          string // Human readable label
+	Description   string // Notes about code
+	Tags          []string
+	CampaignID    uint64 // 0 if code doesn't belong to campaign
+	ScanCount     uint64 // number of resolutions
+	Paused        bool   // paused codes are resolved to fallback URL instead of their content
+	PausedURL     string // optional fallback URL of paused code
+	StyleID       uint64 // 0 if code uses default style of user
+	EmbedDisabled bool   // owner forbids public embedding of code image
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     time.Time // moment code was moved to trash. Zero for active codes
+}
+
+// IsTrashed returns true if code was deleted but not purged yet
+func (c Code) IsTrashed() bool {
+	return !c.DeletedAt.IsZero()
 }
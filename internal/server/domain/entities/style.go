@@ -0,0 +1,29 @@
+package entities
+
+import "time"
+
+// Logo is image of user put in the center of QR codes. Data is always PNG
+type Logo struct {
+	ID        uint64
+	UserID    uint64
+	Name      string
+	Data      []byte // empty in lists
+	Width     int
+	Height    int
+	CreatedAt time.Time
+}
+
+// Style is named template of QR code design
+type Style struct {
+	ID              uint64
+	UserID          uint64
+	Name            string
+	Foreground      string // #rrggbb
+	Background      string // #rrggbb
+	Shape           string // square, circle or rounded
+	QuietZone       int    // border in modules
+	ErrorCorrection string // L, M, Q or H
+	LogoID          uint64 // 0 if style has no logo
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
@@ -5,4 +5,10 @@ type User struct {
 	Username string
 	Password string //Always empty or encrypted
 	Email    string
+	Domain   string // Optional custom domain for links. It is set only after ownership is verified
+	// PendingDomain is custom domain waiting for verification by TXT record with DomainToken
+	PendingDomain string
+	DomainToken   string
+	// DefaultStyleID is style of codes without own style. 0 means default design of griz
+	DefaultStyleID uint64
 }
@@ -1,8 +1,10 @@
 package domain
 
+import "context"
+
 // QRDecoder is an interface for any kind of QR decoders
 type QRDecoder interface {
-	Decode([]byte) ([]byte, error)
+	Decode(context.Context, []byte) ([]byte, error)
 }
 
 // QREncoder is an interface for any kind of QR encoders
@@ -4,10 +4,19 @@ import (
 	"context"
 	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
 	"github.com/pkg/errors"
+	"time"
 )
 
 var ErrUserNotFound = errors.New("user not found")
 
+var ErrDomainAlreadyExists = errors.New("domain already exists")
+
+// ErrDomainNotPending is returned on verification of user without pending domain
+var ErrDomainNotPending = errors.New("domain is not pending verification")
+
+// ErrDomainNotVerified is returned when DNS doesn't contain verification record of pending domain
+var ErrDomainNotVerified = errors.New("domain ownership is not verified")
+
 type UserRepository interface {
 	// Get (ctx, UserID) -> (User, error)
 	Get(context.Context, uint64) (entities.User, error)
@@ -15,23 +24,118 @@ type UserRepository interface {
 	Create(context.Context, entities.User) (uint64, error)
 	// GetByUsernameAndPass (ctx, User) -> (UserID, error)
 	GetByUsernameAndPass(context.Context, entities.User) (uint64, error)
+	// GetByDomain (ctx, domain) -> (User, error). Domain is case-insensitive
+	GetByDomain(context.Context, string) (entities.User, error)
+	// SetDomain (ctx, UserID, domain) -> (error). Empty domain removes it.
+	// Returns ErrDomainAlreadyExists if domain is taken
+	SetDomain(context.Context, uint64, string) error
+	// SetPendingDomain (ctx, UserID, domain, token) -> (error). Pending domains aren't unique, empty domain removes it
+	SetPendingDomain(context.Context, uint64, string, string) error
+	// SetDefaultStyle (ctx, UserID, StyleID) -> (error). StyleID 0 removes default style
+	SetDefaultStyle(context.Context, uint64, uint64) error
 }
 
 var ErrCodeNotFound = errors.New("code not found")
 
+// ErrCodeDisabled is returned on resolution of code which exists but mustn't be resolved
+var ErrCodeDisabled = errors.New("code is disabled")
+
+// ErrCodePaused is returned on resolution of paused code without fallback URL
+var ErrCodePaused = errors.New("code is paused")
+
+// ErrEmbedDisabled is returned for public image of code which owner doesn't allow to embed
+var ErrEmbedDisabled = errors.New("embedding of code is disabled")
+
+// ErrStyleNotSupported is returned for styled codes which are rendered to format without styles
+var ErrStyleNotSupported = errors.New("style is not supported by format")
+
+var ErrSlugAlreadyExists = errors.New("slug already exists")
+
+// CodeFilter selects codes of user. Empty filter selects all codes
+type CodeFilter struct {
+	// Tags code has to have all of them
+	Tags []string
+	// Query is case-insensitive substring of name or description
+	Query string
+	// CampaignID selects codes of campaign. 0 selects codes of any campaign
+	CampaignID uint64
+	// Trashed selects trashed codes instead of active ones
+	Trashed bool
+}
+
 type CodeRepository interface {
-	// List (ctx, UserID, offset, limit) -> ([]Code, error)
+	// List (ctx, UserID, offset, limit) -> ([]Code, error). Trashed codes are not listed
+	// by List, ListAll and ListByFilter without Trashed flag
 	List(context.Context, uint64, int64, int64) ([]entities.Code, error)
 	// ListAll (ctx, UserID) -> ([]Code, error)
 	ListAll(context.Context, uint64) ([]entities.Code, error)
-	// Get (ctx, CodeID) -> (Code, error)
+	// ListByFilter (ctx, UserID, CodeFilter) -> ([]Code, error). Codes are ordered by ID
+	ListByFilter(context.Context, uint64, CodeFilter) ([]entities.Code, error)
+	// Get (ctx, CodeID) -> (Code, error). Get and GetBy* return trashed codes too
 	Get(context.Context, uint64) (entities.Code, error)
 	// GetByHash (ctx, token) -> (Code, error)
 	GetByHash(context.Context, string) (entities.Code, error)
-	// Create (ctx, Code) -> (CodeID, error)
+	// GetBySlug (ctx, slug) -> (Code, error). Slug is case-insensitive
+	GetBySlug(context.Context, string) (entities.Code, error)
+	// Create (ctx, Code) -> (CodeID, error). Returns ErrSlugAlreadyExists if slug is taken
 	Create(context.Context, entities.Code) (uint64, error)
-	// Update (ctx, Code) -> (error)
+	// CreateBatch (ctx, []Code, hasher) -> ([]CodeID, error). All codes are created or none of them.
+	// hasher builds hash by new CodeID
+	CreateBatch(context.Context, []entities.Code, func(uint64) (string, error)) ([]uint64, error)
+	// Update (ctx, Code) -> (error). Returns ErrSlugAlreadyExists if slug is taken
 	Update(context.Context, entities.Code) error
-	// Delete (ctx, CodeID) -> (error)
+	// Delete (ctx, CodeID) -> (error). Code is removed permanently
 	Delete(context.Context, uint64) error
+	// Trash (ctx, CodeID, deletedAt) -> (error). Code is kept until purge
+	Trash(context.Context, uint64, time.Time) error
+	// Restore (ctx, CodeID) -> (error). Returns ErrCodeNotFound if code is not trashed
+	Restore(context.Context, uint64) error
+	// Purge (ctx, before) -> ([]Code, error). Removes codes trashed before moment and returns them
+	Purge(context.Context, time.Time) ([]entities.Code, error)
+	// AddScans (ctx, CodeID -> scans) -> (error). Scans are added in single transaction, unknown codes are skipped
+	AddScans(context.Context, map[uint64]uint64) error
+	// SetCampaign (ctx, CampaignID, []CodeID) -> (error). CampaignID 0 removes codes from campaigns
+	SetCampaign(context.Context, uint64, []uint64) error
+}
+
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+type CampaignRepository interface {
+	// List (ctx, UserID) -> ([]Campaign, error). Campaigns contain scan stats
+	List(context.Context, uint64) ([]entities.Campaign, error)
+	// Get (ctx, CampaignID) -> (Campaign, error). Campaign contains scan stats
+	Get(context.Context, uint64) (entities.Campaign, error)
+	// Create (ctx, Campaign) -> (CampaignID, error)
+	Create(context.Context, entities.Campaign) (uint64, error)
+	// Update (ctx, Campaign) -> (error)
+	Update(context.Context, entities.Campaign) error
+	// Delete (ctx, CampaignID) -> (error). Codes of campaign are not deleted
+	Delete(context.Context, uint64) error
+}
+
+var ErrStyleNotFound = errors.New("style not found")
+
+var ErrLogoNotFound = errors.New("logo not found")
+
+var ErrLogoInUse = errors.New("logo is used by style")
+
+type StyleRepository interface {
+	// ListStyles (ctx, UserID) -> ([]Style, error)
+	ListStyles(context.Context, uint64) ([]entities.Style, error)
+	// GetStyle (ctx, StyleID) -> (Style, error)
+	GetStyle(context.Context, uint64) (entities.Style, error)
+	// CreateStyle (ctx, Style) -> (StyleID, error)
+	CreateStyle(context.Context, entities.Style) (uint64, error)
+	// UpdateStyle (ctx, Style) -> (error)
+	UpdateStyle(context.Context, entities.Style) error
+	// DeleteStyle (ctx, StyleID) -> (error). Codes and users of style return to default design
+	DeleteStyle(context.Context, uint64) error
+	// ListLogos (ctx, UserID) -> ([]Logo, error). Logos are returned without data
+	ListLogos(context.Context, uint64) ([]entities.Logo, error)
+	// GetLogo (ctx, LogoID) -> (Logo, error)
+	GetLogo(context.Context, uint64) (entities.Logo, error)
+	// CreateLogo (ctx, Logo) -> (LogoID, error)
+	CreateLogo(context.Context, entities.Logo) (uint64, error)
+	// DeleteLogo (ctx, LogoID) -> (error). Returns ErrLogoInUse if any style uses logo
+	DeleteLogo(context.Context, uint64) error
 }
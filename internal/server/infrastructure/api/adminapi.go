@@ -0,0 +1,60 @@
+package api
+
+import (
+	"crypto/subtle"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"net"
+	"net/http"
+)
+
+// EnableAdmin serves operations of operators on /admin. Requests are authorized by token in Authorization header.
+// Guessing of token is limited as logins are
+func (rest *Rest) EnableAdmin(token string) {
+	rest.router.Route("/admin", func(r chi.Router) {
+		r.Use(middleware.RequestID)
+		r.Use(rest.requestLogMiddleware)
+		r.Use(middleware.Recoverer)
+		r.Use(middleware.Timeout(rest.timeout))
+		r.Use(rest.rateLimitMiddleware(rest.limits.Token, rest.clientIPKey))
+		r.Use(rest.adminMiddleware(token))
+
+		r.Delete("/lockouts", rest.unlockLoginHandler)
+	})
+}
+
+// adminMiddleware compares token in constant time
+func (rest *Rest) adminMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := r.Header.Get("Authorization")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				rest.writeErrorCode(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// unlockLoginHandler resets failed logins of username and IP from query
+func (rest *Rest) unlockLoginHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("username")
+	ip := r.URL.Query().Get("ip")
+	if username == "" && ip == "" {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "username or ip is required")
+		return
+	}
+	if ip != "" && net.ParseIP(ip) == nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "ip is invalid")
+		return
+	}
+
+	err := rest.service.UnlockLogin(r.Context(), username, ip)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
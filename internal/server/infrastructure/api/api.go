@@ -7,12 +7,21 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/hotafrika/griz-backend/internal/server/app"
+	"github.com/hotafrika/griz-backend/internal/server/app/health"
+	"github.com/hotafrika/griz-backend/internal/server/app/lockout"
+	"github.com/hotafrika/griz-backend/internal/server/app/payload"
+	"github.com/hotafrika/griz-backend/internal/server/app/token"
 	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
 	"github.com/hotafrika/griz-backend/internal/server/infrastructure/api/resources"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/ratelimit"
 	"github.com/rs/zerolog"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,11 +35,40 @@ type Rest struct {
 	service     app.CodeService
 	router      chi.Router
 	server      *http.Server
+	checker     *health.Checker
+	build       health.BuildInfo
+	limits      RateLimits
+	stopping    int32 // set by Shutdown, readiness fails since then
 }
 
-// NewRest creates Rest api
-// TODO
-func NewRest(bindAddr string, timeout time.Duration, parseTimeout time.Duration, logger *zerolog.Logger, service app.CodeService) *Rest {
+// RateLimits limit routes by client. Nil limiters don't limit
+type RateLimits struct {
+	// Token limits login attempts by client IP
+	Token *ratelimit.Limiter
+	// Public limits all public routes by client IP
+	Public *ratelimit.Limiter
+	// Scan additionally limits scans of social posts by client IP, every scan makes requests to social platform
+	Scan *ratelimit.Limiter
+	// User limits authenticated routes by user ID. API clients are authenticated by auth token too
+	User *ratelimit.Limiter
+	// Proxies are trusted to set X-Forwarded-For with client IP
+	Proxies ratelimit.TrustedProxies
+}
+
+// ServerTimeouts limit connections of http server. Zero value means no limit
+type ServerTimeouts struct {
+	// Read is max time to read whole request with body
+	Read time.Duration
+	// Write is max time from end of request headers to end of response.
+	// It has to be longer than timeouts of handlers.
+	Write time.Duration
+	// Idle is max time of keep-alive connection between requests
+	Idle time.Duration
+}
+
+// NewRest creates Rest api. Nil checker has no readiness checks.
+// Middlewares wrap all routes, e.g. for metrics.
+func NewRest(bindAddr string, timeout time.Duration, parseTimeout time.Duration, serverTimeouts ServerTimeouts, logger *zerolog.Logger, service app.CodeService, checker *health.Checker, build health.BuildInfo, limits RateLimits, middlewares ...func(http.Handler) http.Handler) *Rest {
 	r := &Rest{
 		bindAddr:    bindAddr,
 		timeout:     timeout,
@@ -38,30 +76,74 @@ func NewRest(bindAddr string, timeout time.Duration, parseTimeout time.Duration,
 		logger:      logger,
 		service:     service,
 		router:      chi.NewRouter(),
+		checker:     checker,
+		build:       build,
+		limits:      limits,
 	}
+	r.router.Use(middlewares...)
 	r.configureRouter()
+	r.server = &http.Server{
+		Addr:         bindAddr,
+		Handler:      r.router,
+		ReadTimeout:  serverTimeouts.Read,
+		WriteTimeout: serverTimeouts.Write,
+		IdleTimeout:  serverTimeouts.Idle,
+	}
 
 	return r
 }
 
-// Start starts http listeners
+// Handle serves additional handler without auth and request log, e.g. metrics
+func (rest *Rest) Handle(pattern string, handler http.Handler) {
+	rest.router.Handle(pattern, handler)
+}
+
+// Start starts http listener on bind address. It returns nil after Shutdown
 func (rest *Rest) Start() error {
-	rest.server = &http.Server{
-		Addr:    rest.bindAddr,
-		Handler: rest.router,
+	l, err := net.Listen("tcp", rest.bindAddr)
+	if err != nil {
+		return err
+	}
+	return rest.Serve(l)
+}
+
+// Serve accepts connections on l. It returns nil after Shutdown
+func (rest *Rest) Serve(l net.Listener) error {
+	err := rest.server.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
 	}
-	return rest.server.ListenAndServe()
+	return err
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests.
+// Connections which are still active when ctx is done are left and ctx error is returned.
+func (rest *Rest) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&rest.stopping, 1)
+	return rest.server.Shutdown(ctx)
 }
 
 func (rest *Rest) configureRouter() {
 	// content block
 	rest.router.Get("/", rest.homepageHandler)
 	rest.router.Get("/apps", rest.downloadAppsHandler)
+	// probes are without auth and request log
+	rest.router.Get("/healthz", rest.healthHandler)
+	rest.router.Get("/version", rest.versionHandler)
+	// public routes which load DB, cache or renderer share limit of public API
+	rest.router.Group(func(r chi.Router) {
+		r.Use(rest.rateLimitMiddleware(rest.limits.Public, rest.clientIPKey))
+		r.Get("/readyz", rest.readyHandler)
+		r.Get("/p/{token}", rest.hostedPageHandler)
+		r.Get("/qr/{token}.png", rest.embedPNGHandler)
+		r.Get("/qr/{token}.svg", rest.embedSVGHandler)
+		r.Get("/{token}", rest.hostedPageHandler) // short links by slug
+	})
 
 	// /api
 	rest.router.Route("/api", func(r chi.Router) {
 		r.Use(middleware.RequestID)
-		r.Use(middleware.Logger)
+		r.Use(rest.requestLogMiddleware)
 		r.Use(middleware.Recoverer)
 		r.Use(middleware.Timeout(rest.timeout))
 
@@ -70,22 +152,30 @@ func (rest *Rest) configureRouter() {
 			// with auth
 			r.Group(func(r chi.Router) {
 				r.Use(rest.authMiddleware)
+				r.Use(rest.rateLimitMiddleware(rest.limits.User, rest.userKey))
 				// api/v1/code...
 				r.Mount("/codes", rest.CodesRouter())
+				r.Mount("/campaigns", rest.CampaignsRouter())
+				r.Mount("/styles", rest.StylesRouter())
+				r.Mount("/logos", rest.LogosRouter())
 				r.Get("/self", rest.userSelfHandler)
+				r.Put("/self/domain", rest.userDomainHandler)
+				r.Post("/self/domain/verify", rest.verifyDomainHandler)
+				r.Put("/self/style", rest.userStyleHandler)
 			})
 			// api/v1/public/...
 			r.Route("/public", func(r chi.Router) {
+				r.Use(rest.rateLimitMiddleware(rest.limits.Public, rest.clientIPKey))
 				r.Post("/url", rest.urlHandler)
 				r.Group(func(r chi.Router) {
-					//r.Use(middleware.Throttle(10))
+					r.Use(rest.rateLimitMiddleware(rest.limits.Scan, rest.clientIPKey))
 					r.Use(middleware.Timeout(rest.scanTimeout))
 					r.Post("/scan", rest.scanHandler)
 				})
 			})
 			// api/v1/token
 			r.Group(func(r chi.Router) {
-				//r.Use(middleware.Throttle(10))
+				r.Use(rest.rateLimitMiddleware(rest.limits.Token, rest.clientIPKey))
 				r.Post("/token", rest.tokenHandler)
 			})
 		})
@@ -105,6 +195,52 @@ func (rest *Rest) downloadAppsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("download page"))
 }
 
+// hostedPageHandler serves content of dynamic codes for browsers
+func (rest *Rest) hostedPageHandler(w http.ResponseWriter, r *http.Request) {
+	code, err := rest.service.GetCodeByHash(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		if errors.Is(err, domain.ErrCodeNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "page not found")
+			return
+		}
+		if errors.Is(err, domain.ErrCodeDisabled) {
+			rest.writeErrorCode(w, http.StatusGone, "code is disabled")
+			return
+		}
+		if errors.Is(err, domain.ErrCodePaused) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(rest.service.MaintenancePage()))
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if code.Paused {
+		http.Redirect(w, r, rest.service.PausedURL(code), http.StatusFound)
+		return
+	}
+
+	switch code.Type {
+	case entities.CodeTypeURL:
+		http.Redirect(w, r, code.SrcURL, http.StatusFound)
+	case entities.CodeTypeVCard:
+		content, err := payload.Encode(code)
+		if err != nil {
+			rest.log(r).Error().Err(err).Send()
+			rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="contact.vcf"`)
+		w.Write([]byte(content))
+	default:
+		rest.writeErrorCode(w, http.StatusNotFound, "page not found")
+	}
+}
+
 func (rest *Rest) userSelfHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("user_id").(uint64)
 	if !ok {
@@ -118,20 +254,16 @@ func (rest *Rest) userSelfHandler(w http.ResponseWriter, r *http.Request) {
 			rest.writeErrorCode(w, http.StatusNotFound, "user not found")
 			return
 		}
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 
-	resourceUser := resources.SelfUserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-	}
+	resourceUser := resources.NewSelfUserResponse(user)
 
 	body, err := json.Marshal(resourceUser)
 	if err != nil {
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
 		return
 	}
@@ -139,6 +271,110 @@ func (rest *Rest) userSelfHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
+func (rest *Rest) userDomainHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	dr := resources.UserDomainRequest{}
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "unable to read body")
+		return
+	}
+	defer r.Body.Close()
+	err = json.Unmarshal(reqBody, &dr)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "unable to deserialize body")
+		return
+	}
+
+	err = dr.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	err = rest.service.SetUserDomain(r.Context(), userID, dr.Domain)
+	if err != nil {
+		if errors.Is(err, domain.ErrDomainAlreadyExists) {
+			rest.writeErrorCode(w, http.StatusConflict, "domain is already taken")
+			return
+		}
+		if errors.Is(err, domain.ErrUserNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "user not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	rest.userSelfHandler(w, r)
+}
+
+// verifyDomainHandler activates pending domain of user by its TXT record
+func (rest *Rest) verifyDomainHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	err := rest.service.VerifyUserDomain(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDomainNotPending):
+			rest.writeErrorCode(w, http.StatusNotFound, "no domain waits for verification")
+		case errors.Is(err, domain.ErrDomainNotVerified):
+			rest.writeErrorCode(w, http.StatusUnprocessableEntity, "verification record is not found in DNS")
+		case errors.Is(err, domain.ErrDomainAlreadyExists):
+			rest.writeErrorCode(w, http.StatusConflict, "domain is already taken")
+		case errors.Is(err, domain.ErrUserNotFound):
+			rest.writeErrorCode(w, http.StatusNotFound, "user not found")
+		default:
+			rest.log(r).Error().Err(err).Send()
+			rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	rest.userSelfHandler(w, r)
+}
+
+// userStyleHandler sets default style for codes of user
+func (rest *Rest) userStyleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	sr := resources.StyleIDRequest{}
+	if !rest.readRequest(w, r, &sr) {
+		return
+	}
+
+	err := rest.service.SetDefaultStyle(r.Context(), userID, sr.StyleID)
+	if err != nil {
+		if errors.Is(err, domain.ErrStyleNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "style not found")
+			return
+		}
+		if errors.Is(err, domain.ErrUserNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "user not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	rest.userSelfHandler(w, r)
+}
+
 func (rest *Rest) tokenHandler(w http.ResponseWriter, r *http.Request) {
 	tr := resources.AuthTokenRequest{}
 	reqBody, err := io.ReadAll(r.Body)
@@ -164,20 +400,26 @@ func (rest *Rest) tokenHandler(w http.ResponseWriter, r *http.Request) {
 		Password: tr.Password,
 	}
 
-	authToken, err := rest.service.CreateAuthToken(r.Context(), user)
+	authToken, err := rest.service.CreateAuthToken(r.Context(), user, rest.limits.Proxies.ClientIP(r))
 	if err != nil {
 		if errors.Is(err, domain.ErrUserNotFound) {
 			rest.writeErrorCode(w, http.StatusUnauthorized, "wrong credentials")
 			return
 		}
-		rest.logger.Error().Err(err).Send()
+		var locked *lockout.LockedError
+		if errors.As(err, &locked) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			rest.writeErrorCode(w, http.StatusTooManyRequests, "too many failed logins, try later")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 
 	body, err := json.Marshal(resources.AuthTokenResponse{Token: authToken})
 	if err != nil {
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
 		return
 	}
@@ -199,26 +441,38 @@ func (rest *Rest) urlHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := l.Parse()
+	err = l.Validate()
 	if err != nil {
 		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "link is not compatible")
 		return
 	}
 
-	link, err := rest.service.FindCodeByHash(r.Context(), token)
+	link, err := rest.service.FindCodeByLink(r.Context(), l.URL)
 	if err != nil {
+		if errors.Is(err, token.ErrNotGrizLink) {
+			rest.writeErrorCode(w, http.StatusUnprocessableEntity, "link is not compatible")
+			return
+		}
 		if errors.Is(err, domain.ErrCodeNotFound) {
 			rest.writeErrorCode(w, http.StatusNotFound, "link not found")
 			return
 		}
-		rest.logger.Error().Err(err).Send()
+		if errors.Is(err, domain.ErrCodeDisabled) {
+			rest.writeErrorCode(w, http.StatusGone, "code is disabled")
+			return
+		}
+		if errors.Is(err, domain.ErrCodePaused) {
+			rest.writeErrorCode(w, http.StatusServiceUnavailable, "code is paused")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 
 	body, err := json.Marshal(resources.LinkHashResponse{URL: link})
 	if err != nil {
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
 		return
 	}
@@ -248,14 +502,22 @@ func (rest *Rest) scanHandler(w http.ResponseWriter, r *http.Request) {
 
 	link, err := rest.service.FindCodeBySocial(r.Context(), sl.URL)
 	if err != nil {
-		rest.logger.Info().Str("link", sl.URL).Str("error", err.Error()).Msg("unable to process link")
+		if errors.Is(err, domain.ErrCodeDisabled) {
+			rest.writeErrorCode(w, http.StatusGone, "code is disabled")
+			return
+		}
+		if errors.Is(err, domain.ErrCodePaused) {
+			rest.writeErrorCode(w, http.StatusServiceUnavailable, "code is paused")
+			return
+		}
+		rest.log(r).Info().Str("link", sl.URL).Str("error", err.Error()).Msg("unable to process link")
 		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "unable to process link")
 		return
 	}
 
 	body, err := json.Marshal(resources.SocialLinkResponse{URL: link})
 	if err != nil {
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
 		return
 	}
@@ -277,16 +539,92 @@ func (rest *Rest) authMiddleware(next http.Handler) http.Handler {
 				rest.writeErrorCode(w, http.StatusUnauthorized, "unauthorized")
 				return
 			}
-			rest.logger.Error().Err(err).Send()
+			rest.log(r).Error().Err(err).Send()
 			rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 			return
 		}
 		r = r.WithContext(context.WithValue(r.Context(), userIdInCtx, userID))
+		zerolog.Ctx(r.Context()).UpdateContext(func(c zerolog.Context) zerolog.Context {
+			return c.Uint64(userIdInCtx, userID)
+		})
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// rateLimitMiddleware denies requests over limit of client key with 429.
+// Failures of limit store are logged and requests pass, so limits don't take API down.
+func (rest *Rest) rateLimitMiddleware(limiter *ratelimit.Limiter, key func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := limiter.Allow(r.Context(), key(r))
+			if err != nil {
+				rest.log(r).Error().Err(err).Msg("unable to check rate limit")
+				next.ServeHTTP(w, r)
+				return
+			}
+			ratelimit.WriteHeaders(w, res)
+			if !res.Allowed {
+				rest.writeErrorCode(w, http.StatusTooManyRequests, "too many requests")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIPKey is rate limit key of client IP
+func (rest *Rest) clientIPKey(r *http.Request) string {
+	return "ip_" + rest.limits.Proxies.ClientIP(r)
+}
+
+// userKey is rate limit key of authenticated user
+func (rest *Rest) userKey(r *http.Request) string {
+	userID, _ := r.Context().Value(userIdInCtx).(uint64)
+	return "user_" + strconv.FormatUint(userID, 10)
+}
+
+// requestLogMiddleware puts logger with request ID to request context.
+// Handlers and service log through it, auth adds user ID to it.
+// Access log entry is written by the same logger after response.
+func (rest *Rest) requestLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		logger := rest.logger.With().Str("request_id", middleware.GetReqID(r.Context())).Logger()
+		ctx := logger.WithContext(r.Context())
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		event := logger.Info()
+		if status >= http.StatusInternalServerError {
+			event = logger.Error()
+		}
+		event.Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", status).
+			Int("bytes", ww.BytesWritten()).
+			Dur("duration", time.Since(start)).
+			Str("remote_addr", r.RemoteAddr).
+			Str("user_agent", r.UserAgent()).
+			Msg("request")
+	})
+}
+
+// log returns request-scoped logger. Requests outside request log use logger of Rest
+func (rest *Rest) log(r *http.Request) *zerolog.Logger {
+	if logger := zerolog.Ctx(r.Context()); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return rest.logger
+}
+
 func (rest *Rest) writeErrorCode(w http.ResponseWriter, code int, message string) {
 	w.WriteHeader(code)
 	b, _ := json.Marshal(
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/hotafrika/griz-backend/internal/server/app"
+	"github.com/hotafrika/griz-backend/internal/server/app/health"
+	"github.com/hotafrika/griz-backend/internal/server/app/token"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/ratelimit"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRest_Shutdown(t *testing.T) {
+	logger := zerolog.Nop()
+	rest := NewRest("", time.Second, time.Second, ServerTimeouts{Write: 5 * time.Second}, &logger, app.CodeService{}, nil, health.BuildInfo{}, RateLimits{})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	rest.router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- rest.Serve(l)
+	}()
+
+	type response struct {
+		status int
+		body   string
+		err    error
+	}
+	inFlight := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String() + "/slow")
+		if err != nil {
+			inFlight <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		inFlight <- response{status: resp.StatusCode, body: string(b), err: err}
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- rest.Shutdown(context.Background())
+	}()
+
+	// shutdown waits for in-flight request
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("shutdown returned before in-flight request was finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// new connections are refused
+	_, err = net.DialTimeout("tcp", l.Addr().String(), time.Second)
+	assert.Error(t, err)
+
+	close(release)
+	resp := <-inFlight
+	if assert.NoError(t, resp.err) {
+		assert.Equal(t, http.StatusOK, resp.status)
+		assert.Equal(t, "done", resp.body)
+	}
+	assert.NoError(t, <-shutdownErr)
+	assert.NoError(t, <-serveErr, "Serve returns nil after Shutdown")
+}
+
+func TestRest_ShutdownTimeout(t *testing.T) {
+	logger := zerolog.Nop()
+	rest := NewRest("", time.Second, time.Second, ServerTimeouts{}, &logger, app.CodeService{}, nil, health.BuildInfo{}, RateLimits{})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	rest.router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	go rest.Serve(l)
+	go http.Get("http://" + l.Addr().String() + "/slow")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, rest.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func TestRest_requestLogMiddleware(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := zerolog.New(buf)
+	rest := &Rest{logger: &logger}
+	handler := middleware.RequestID(rest.requestLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zerolog.Ctx(r.Context()).UpdateContext(func(c zerolog.Context) zerolog.Context {
+			return c.Uint64(userIdInCtx, 42)
+		})
+		rest.log(r).Error().Msg("handler failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/codes", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+	var entry, access map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+	assert.Equal(t, "handler failed", entry["message"])
+	assert.NotEmpty(t, entry["request_id"])
+	assert.Equal(t, entry["request_id"], access["request_id"])
+	assert.Equal(t, float64(42), access[userIdInCtx], "user ID set by auth is in access log")
+	assert.Equal(t, "error", access["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), access["status"])
+	assert.Equal(t, "/api/v1/codes", access["path"])
+}
+
+func TestRest_log(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := zerolog.New(buf)
+	rest := &Rest{logger: &logger}
+	rest.log(httptest.NewRequest(http.MethodGet, "/qr/token.png", nil)).Info().Msg("outside request log")
+	assert.Contains(t, buf.String(), "outside request log")
+}
+
+func TestRest_readyHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := zerolog.New(buf)
+	checker := health.NewChecker(time.Second, time.Minute)
+	checker.Add("db", func(ctx context.Context) error { return nil })
+	checker.Add("upstream 1", func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.0.7:443: connection refused")
+	})
+	rest := NewRest("", time.Second, time.Second, ServerTimeouts{}, &logger, app.CodeService{}, checker, health.BuildInfo{}, RateLimits{})
+
+	rec := httptest.NewRecorder()
+	rest.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"not ready","checks":[{"name":"db","status":"ok"},{"name":"upstream 1","status":"failed"}]}`, rec.Body.String())
+	assert.Contains(t, buf.String(), "10.0.0.7", "error is logged")
+}
+
+func TestRest_EnableAdmin_RateLimit(t *testing.T) {
+	logger := zerolog.Nop()
+	limit := ratelimit.Limit{Requests: 3, Per: time.Minute}
+	rest := NewRest("", time.Second, time.Second, ServerTimeouts{}, &logger, app.CodeService{}, nil, health.BuildInfo{}, RateLimits{
+		Token: ratelimit.NewLimiter("token", limit, ratelimit.NewMemoryStore()),
+	})
+	rest.EnableAdmin(strings.Repeat("a", 32))
+	rest.Handle("/metrics", http.NotFoundHandler())
+
+	for i := 0; i < limit.Requests; i++ {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/admin/lockouts?ip=203.0.113.7", nil)
+		req.Header.Set("Authorization", "guess")
+		rest.router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/admin/lockouts?ip=203.0.113.7", nil)
+	req.Header.Set("Authorization", strings.Repeat("a", 32))
+	rest.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "guessing of token is limited")
+}
+
+// Slug is served by root /{token} route, so every other root path has to be reserved
+func TestRest_ReservedSlugs(t *testing.T) {
+	logger := zerolog.Nop()
+	rest := NewRest("", time.Second, time.Second, ServerTimeouts{}, &logger, app.CodeService{}, nil, health.BuildInfo{}, RateLimits{})
+	rest.EnableAdmin(strings.Repeat("a", 32))
+
+	err := chi.Walk(rest.router, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		segment := strings.SplitN(strings.TrimPrefix(route, "/"), "/", 2)[0]
+		if segment == "" || segment == "{token}" {
+			return nil
+		}
+		assert.Error(t, token.ValidateSlug(segment), "root path %s could be claimed as slug", route)
+		return nil
+	})
+	assert.NoError(t, err)
+}
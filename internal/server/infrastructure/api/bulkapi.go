@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/api/resources"
+	"github.com/pkg/errors"
+	"mime"
+	"net/http"
+)
+
+// maxBulkBodySize limits body of bulk request
+const maxBulkBodySize = 16 << 20
+
+// bulkCreateCodes creates url codes from CSV or JSON lines body.
+// Nothing is created if any row is invalid.
+func (rest *Rest) bulkCreateCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	body := http.MaxBytesReader(w, r.Body, maxBulkBodySize)
+	defer body.Close()
+
+	var req resources.BulkCreateRequest
+	var err error
+	switch mediaType {
+	case "text/csv":
+		req, err = resources.ParseBulkCSV(body)
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		req, err = resources.ParseBulkJSONLines(body)
+	default:
+		rest.writeErrorCode(w, http.StatusUnsupportedMediaType, "body has to be text/csv or application/x-ndjson")
+		return
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			rest.writeErrorCode(w, http.StatusRequestEntityTooLarge, "body is too large")
+			return
+		}
+		rest.writeErrorCode(w, http.StatusBadRequest, "unable to read body: "+err.Error())
+		return
+	}
+	if len(req.Rows) == 0 {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "no codes in body")
+		return
+	}
+
+	if rowErrors := req.Validate(); len(rowErrors) > 0 {
+		rest.writeJSON(w, http.StatusUnprocessableEntity, resources.BulkErrorResponse{
+			Message: "wrong data",
+			Errors:  rowErrors,
+		})
+		return
+	}
+
+	codes := make([]entities.Code, 0, len(req.Rows))
+	for _, row := range req.Rows {
+		codes = append(codes, row.Code(userID))
+	}
+	ids, err := rest.service.CreateCodes(r.Context(), codes)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	rest.writeJSON(w, http.StatusCreated, resources.BulkCreateResponse{IDs: ids})
+}
+
+// exportCodes returns all codes of user as CSV (default) or JSON
+func (rest *Rest) exportCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		rest.writeErrorCode(w, http.StatusBadRequest, "format has to be csv or json")
+		return
+	}
+
+	codes, err := rest.service.ExportCodes(r.Context(), userID)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	rows := make([]resources.ExportCode, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, resources.NewExportCode(code.Code, code.Link))
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Disposition", `attachment; filename="codes.json"`)
+		rest.writeJSON(w, http.StatusOK, rows)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="codes.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write(resources.ExportCSVHeader)
+	for _, row := range rows {
+		cw.Write(row.CSVRecord())
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		rest.log(r).Error().Err(err).Send()
+	}
+}
+
+// writeJSON writes value as JSON body with status
+func (rest *Rest) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		rest.logger.Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
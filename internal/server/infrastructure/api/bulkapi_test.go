@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRest_bulkCreateCodes_TooLarge(t *testing.T) {
+	e := newEmbedEnv(t, RateLimits{})
+	body := "https://example.com/,label,tag," + strings.Repeat("a", maxBulkBodySize) + "\n"
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/codes/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	req = req.WithContext(context.WithValue(req.Context(), userIdInCtx, e.owner))
+	rec := httptest.NewRecorder()
+	e.rest.bulkCreateCodes(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestRest_exportCodes_Formula(t *testing.T) {
+	e := newEmbedEnv(t, RateLimits{})
+	e.createCode(t, "=HYPERLINK(\"https://evil.example\")")
+	e.createCode(t, "plain")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/codes/export", nil)
+	req = req.WithContext(context.WithValue(req.Context(), userIdInCtx, e.owner))
+	rec := httptest.NewRecorder()
+	e.rest.exportCodes(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	assert.NoError(t, err)
+	names := make([]string, 0, len(records))
+	for _, record := range records[1:] {
+		names = append(names, record[3])
+	}
+	assert.ElementsMatch(t, []string{"'=HYPERLINK(\"https://evil.example\")", "plain"}, names)
+}
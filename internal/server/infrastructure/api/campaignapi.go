@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/api/resources"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// CampaignsRouter returns router for campaigns of user
+func (rest *Rest) CampaignsRouter() chi.Router {
+	router := chi.NewRouter()
+
+	router.Post("/", rest.createCampaign)
+	router.Get("/", rest.listCampaigns)
+
+	router.Route("/{campaignID}", func(r chi.Router) {
+		r.Get("/", rest.getCampaign)
+		r.Put("/", rest.updateCampaign)
+		r.Delete("/", rest.deleteCampaign)
+		r.Get("/codes", rest.listCampaignCodes)
+		r.Post("/codes", rest.moveCampaignCodes)
+	})
+
+	return router
+}
+
+func (rest *Rest) createCampaign(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	cr := resources.CampaignRequest{}
+	if !rest.readRequest(w, r, &cr) {
+		return
+	}
+	err := cr.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "wrong data")
+		return
+	}
+
+	campaign := entities.Campaign{UserID: userID}
+	cr.Fill(&campaign)
+	id, err := rest.service.CreateCampaign(r.Context(), campaign)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	rest.writeJSON(w, http.StatusCreated, resources.CampaignCreateResponse{ID: id})
+}
+
+func (rest *Rest) listCampaigns(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	campaigns, err := rest.service.GetCampaigns(r.Context(), userID)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	resp := resources.GetCampaignsResponse{
+		Campaigns: make([]resources.GetCampaignResponse, 0, len(campaigns)),
+	}
+	for _, campaign := range campaigns {
+		resp.Campaigns = append(resp.Campaigns, resources.NewGetCampaignResponse(campaign))
+	}
+	rest.writeJSON(w, http.StatusOK, resp)
+}
+
+func (rest *Rest) getCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := rest.userCampaign(w, r)
+	if !ok {
+		return
+	}
+	rest.writeJSON(w, http.StatusOK, resources.NewGetCampaignResponse(campaign))
+}
+
+func (rest *Rest) updateCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := rest.userCampaign(w, r)
+	if !ok {
+		return
+	}
+
+	cr := resources.CampaignRequest{}
+	if !rest.readRequest(w, r, &cr) {
+		return
+	}
+	err := cr.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "wrong data")
+		return
+	}
+
+	cr.Fill(&campaign)
+	err = rest.service.UpdateCampaign(r.Context(), campaign)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (rest *Rest) deleteCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := rest.userCampaign(w, r)
+	if !ok {
+		return
+	}
+
+	err := rest.service.DeleteCampaign(r.Context(), campaign.ID)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (rest *Rest) listCampaignCodes(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := rest.userCampaign(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := rest.service.GetCodes(r.Context(), campaign.UserID, domain.CodeFilter{CampaignID: campaign.ID})
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	resp := resources.GetCodesResponse{
+		Codes: make([]resources.GetCodeResponse, 0, len(codes)),
+	}
+	for _, code := range codes {
+		resp.Codes = append(resp.Codes, resources.NewGetCodeResponse(code))
+	}
+	rest.writeJSON(w, http.StatusOK, resp)
+}
+
+func (rest *Rest) moveCampaignCodes(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := rest.userCampaign(w, r)
+	if !ok {
+		return
+	}
+
+	mr := resources.MoveCodesRequest{}
+	if !rest.readRequest(w, r, &mr) {
+		return
+	}
+	err := mr.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "wrong data")
+		return
+	}
+
+	rest.moveCodes(w, r, campaign.UserID, campaign.ID, mr.IDs)
+}
+
+// moveCodes moves codes to campaign and writes response
+func (rest *Rest) moveCodes(w http.ResponseWriter, r *http.Request, userID, campaignID uint64, codeIDs []uint64) {
+	err := rest.service.MoveCodes(r.Context(), userID, campaignID, codeIDs)
+	if err != nil {
+		if errors.Is(err, domain.ErrCodeNotFound) || errors.Is(err, domain.ErrCampaignNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// userCampaign returns campaign from URL if it belongs to user.
+// Error response is written when false is returned.
+func (rest *Rest) userCampaign(w http.ResponseWriter, r *http.Request) (entities.Campaign, bool) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return entities.Campaign{}, false
+	}
+
+	campaignID, err := strconv.ParseUint(chi.URLParam(r, "campaignID"), 10, 64)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "wrong campaign id")
+		return entities.Campaign{}, false
+	}
+
+	campaign, err := rest.service.GetCampaign(r.Context(), campaignID)
+	if err != nil {
+		if errors.Is(err, domain.ErrCampaignNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+			return campaign, false
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return campaign, false
+	}
+	if campaign.UserID != userID {
+		rest.writeErrorCode(w, http.StatusForbidden, "unauthorized")
+		return campaign, false
+	}
+
+	return campaign, true
+}
+
+// readRequest deserializes JSON body of request to v.
+// Error response is written when false is returned.
+func (rest *Rest) readRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "unable to read body")
+		return false
+	}
+	defer r.Body.Close()
+	err = json.Unmarshal(reqBody, v)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "unable to deserialize body")
+		return false
+	}
+	return true
+}
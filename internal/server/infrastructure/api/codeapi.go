@@ -1,8 +1,11 @@
 package api
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"github.com/go-chi/chi/v5"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/token"
 	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
 	"github.com/hotafrika/griz-backend/internal/server/infrastructure/api/resources"
@@ -10,6 +13,8 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // CodesRouter returns router for
@@ -18,12 +23,23 @@ func (rest *Rest) CodesRouter() chi.Router {
 
 	router.Post("/", rest.createCode)
 	router.Get("/", rest.listCodes)
+	router.Post("/bulk", rest.bulkCreateCodes)
+	router.Get("/export", rest.exportCodes)
+	router.Post("/render", rest.renderCodes)
+	router.Get("/trash", rest.listTrashedCodes)
 
 	router.Route("/{codeID}", func(r chi.Router) {
 		r.Get("/", rest.getCode)
 		r.Get("/download", rest.downloadCode)
 		r.Put("/", rest.updateCode)
+		r.Put("/slug", rest.setCodeSlug)
+		r.Put("/campaign", rest.setCodeCampaign)
+		r.Put("/style", rest.setCodeStyle)
+		r.Put("/embed", rest.setCodeEmbed)
 		r.Delete("/", rest.deleteCode)
+		r.Post("/restore", rest.restoreCode)
+		r.Post("/pause", rest.pauseCode)
+		r.Post("/resume", rest.resumeCode)
 	})
 
 	return router
@@ -57,19 +73,28 @@ func (rest *Rest) createCode(w http.ResponseWriter, r *http.Request) {
 
 	code := entities.Code{
 		UserID: userID,
-		SrcURL: cr.URL,
+		Slug:   token.NormalizeSlug(cr.Slug),
+	}
+	err = cr.Fill(&code)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "wrong data")
+		return
 	}
 
 	id, err := rest.service.CreateCode(r.Context(), code)
 	if err != nil {
-		rest.logger.Error().Err(err).Send()
+		if errors.Is(err, domain.ErrSlugAlreadyExists) {
+			rest.writeErrorCode(w, http.StatusConflict, "slug is already taken")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 
 	body, err := json.Marshal(resources.CodeCreateResponse{ID: id})
 	if err != nil {
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
 		return
 	}
@@ -85,10 +110,17 @@ func (rest *Rest) listCodes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	codes, err := rest.service.GetCodes(r.Context(), userID)
+	lr := resources.NewCodeListRequest(r.URL.Query())
+	err := lr.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "wrong data")
+		return
+	}
+
+	codes, err := rest.service.GetCodes(r.Context(), userID, lr.Filter())
 	if err != nil {
 		if !errors.Is(err, domain.ErrCodeNotFound) {
-			rest.logger.Error().Err(err).Send()
+			rest.log(r).Error().Err(err).Send()
 			rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 			return
 		}
@@ -96,10 +128,7 @@ func (rest *Rest) listCodes(w http.ResponseWriter, r *http.Request) {
 
 	newCodes := make([]resources.GetCodeResponse, 0, len(codes))
 	for _, code := range codes {
-		newCodes = append(newCodes, resources.GetCodeResponse{
-			ID:  code.ID,
-			URL: code.SrcURL,
-		})
+		newCodes = append(newCodes, resources.NewGetCodeResponse(code))
 	}
 	newCodesR := resources.GetCodesResponse{
 		Codes: newCodes,
@@ -107,7 +136,7 @@ func (rest *Rest) listCodes(w http.ResponseWriter, r *http.Request) {
 
 	body, err := json.Marshal(newCodesR)
 	if err != nil {
-		rest.logger.Error().Msg(err.Error())
+		rest.log(r).Error().Msg(err.Error())
 		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
 		return
 	}
@@ -135,7 +164,7 @@ func (rest *Rest) getCode(w http.ResponseWriter, r *http.Request) {
 			rest.writeErrorCode(w, http.StatusNotFound, "not found")
 			return
 		}
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 		return
 	}
@@ -145,12 +174,9 @@ func (rest *Rest) getCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := json.Marshal(resources.GetCodeResponse{
-		ID:  code.ID,
-		URL: code.SrcURL,
-	})
+	body, err := json.Marshal(resources.NewGetCodeResponse(code))
 	if err != nil {
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
 		return
 	}
@@ -178,7 +204,7 @@ func (rest *Rest) downloadCode(w http.ResponseWriter, r *http.Request) {
 			rest.writeErrorCode(w, http.StatusNotFound, "not found")
 			return
 		}
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 		return
 	}
@@ -188,19 +214,35 @@ func (rest *Rest) downloadCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	encodedQR, err := rest.service.DownloadCodeByHash(r.Context(), code.Hash)
+	image, err := rest.service.DownloadCode(r.Context(), code)
 	if err != nil {
 		if errors.Is(err, domain.ErrCodeNotFound) {
 			rest.writeErrorCode(w, http.StatusNotFound, "not found")
 			return
 		}
+		if errors.Is(err, qrdecoder.ErrUnscannable) {
+			rest.writeErrorCode(w, http.StatusUnprocessableEntity, "design of code is not scannable, change its style")
+			return
+		}
 		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 
-	body, err := json.Marshal(resources.DownloadCodeResponse{Code: encodedQR})
+	// clients revalidate image, it is changed with content and style of code
+	etag := `"` + image.ETag + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, err := json.Marshal(resources.DownloadCodeResponse{
+		Code:         base64.StdEncoding.EncodeToString(image.Data),
+		Scannability: resources.NewScannabilityResponse(image.Report),
+	})
 	if err != nil {
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
 		return
 	}
@@ -247,7 +289,7 @@ func (rest *Rest) updateCode(w http.ResponseWriter, r *http.Request) {
 			rest.writeErrorCode(w, http.StatusNotFound, "not found")
 			return
 		}
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 		return
 	}
@@ -257,7 +299,15 @@ func (rest *Rest) updateCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	code.SrcURL = cr.URL
+	if cr.CodeType() != code.Type {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "type of code couldn't be changed")
+		return
+	}
+	err = cr.Fill(&code)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "wrong data")
+		return
+	}
 
 	err = rest.service.UpdateCode(r.Context(), code)
 	if err != nil {
@@ -269,9 +319,84 @@ func (rest *Rest) updateCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := json.Marshal(resources.GetCodeResponse{ID: code.ID, URL: code.SrcURL})
+	body, err := json.Marshal(resources.NewGetCodeResponse(code))
 	if err != nil {
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
+		return
+	}
+
+	w.Write(body)
+}
+
+func (rest *Rest) setCodeSlug(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	codeIDString := chi.URLParam(r, "codeID")
+	codeID, err := strconv.ParseUint(codeIDString, 10, 64)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "wrong code id")
+		return
+	}
+
+	sr := resources.CodeSlugRequest{}
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "unable to read body")
+		return
+	}
+	defer r.Body.Close()
+	err = json.Unmarshal(reqBody, &sr)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "unable to deserialize body")
+		return
+	}
+
+	err = sr.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	code, err := rest.service.GetCode(r.Context(), codeID)
+	if err != nil {
+		if errors.Is(err, domain.ErrCodeNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if code.UserID != userID {
+		rest.writeErrorCode(w, http.StatusForbidden, "unauthorized")
+		return
+	}
+
+	if code.Type.IsStatic() {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "static code couldn't have slug")
+		return
+	}
+
+	code, err = rest.service.SetCodeSlug(r.Context(), code, sr.Slug)
+	if err != nil {
+		if errors.Is(err, domain.ErrSlugAlreadyExists) {
+			rest.writeErrorCode(w, http.StatusConflict, "slug is already taken")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	body, err := json.Marshal(resources.NewGetCodeResponse(code))
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
 		return
 	}
@@ -299,7 +424,7 @@ func (rest *Rest) deleteCode(w http.ResponseWriter, r *http.Request) {
 			rest.writeErrorCode(w, http.StatusNotFound, "not found")
 			return
 		}
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
 		return
 	}
@@ -321,10 +446,267 @@ func (rest *Rest) deleteCode(w http.ResponseWriter, r *http.Request) {
 
 	body, err := json.Marshal(resources.DeleteCodeResponse{Status: "ok"})
 	if err != nil {
-		rest.logger.Error().Err(err).Send()
+		rest.log(r).Error().Err(err).Send()
 		rest.writeErrorCode(w, http.StatusInternalServerError, "error during building response")
 		return
 	}
 
 	w.Write(body)
 }
+
+func (rest *Rest) setCodeCampaign(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	codeIDString := chi.URLParam(r, "codeID")
+	codeID, err := strconv.ParseUint(codeIDString, 10, 64)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "wrong code id")
+		return
+	}
+
+	cr := resources.CodeCampaignRequest{}
+	if !rest.readRequest(w, r, &cr) {
+		return
+	}
+
+	rest.moveCodes(w, r, userID, cr.CampaignID, []uint64{codeID})
+}
+
+func (rest *Rest) setCodeStyle(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	codeIDString := chi.URLParam(r, "codeID")
+	codeID, err := strconv.ParseUint(codeIDString, 10, 64)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "wrong code id")
+		return
+	}
+
+	sr := resources.StyleIDRequest{}
+	if !rest.readRequest(w, r, &sr) {
+		return
+	}
+
+	code, err := rest.service.GetCode(r.Context(), codeID)
+	if err != nil {
+		if errors.Is(err, domain.ErrCodeNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if code.UserID != userID {
+		rest.writeErrorCode(w, http.StatusForbidden, "unauthorized")
+		return
+	}
+
+	code, err = rest.service.SetCodeStyle(r.Context(), code, sr.StyleID)
+	if err != nil {
+		if errors.Is(err, domain.ErrStyleNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "style not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	rest.writeJSON(w, http.StatusOK, resources.NewGetCodeResponse(code))
+}
+
+func (rest *Rest) setCodeEmbed(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	codeIDString := chi.URLParam(r, "codeID")
+	codeID, err := strconv.ParseUint(codeIDString, 10, 64)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "wrong code id")
+		return
+	}
+
+	er := resources.CodeEmbedRequest{}
+	if !rest.readRequest(w, r, &er) {
+		return
+	}
+
+	code, err := rest.service.GetCode(r.Context(), codeID)
+	if err != nil {
+		if errors.Is(err, domain.ErrCodeNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if code.UserID != userID {
+		rest.writeErrorCode(w, http.StatusForbidden, "unauthorized")
+		return
+	}
+
+	code, err = rest.service.SetCodeEmbedding(r.Context(), code, er.Enabled)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	rest.writeJSON(w, http.StatusOK, resources.NewGetCodeResponse(code))
+}
+
+func (rest *Rest) listTrashedCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	codes, err := rest.service.GetCodes(r.Context(), userID, domain.CodeFilter{Trashed: true})
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	resp := resources.GetCodesResponse{
+		Codes: make([]resources.GetCodeResponse, 0, len(codes)),
+	}
+	for _, code := range codes {
+		resp.Codes = append(resp.Codes, resources.NewGetCodeResponse(code))
+	}
+	rest.writeJSON(w, http.StatusOK, resp)
+}
+
+func (rest *Rest) restoreCode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	codeIDString := chi.URLParam(r, "codeID")
+	codeID, err := strconv.ParseUint(codeIDString, 10, 64)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "wrong code id")
+		return
+	}
+
+	code, err := rest.service.GetTrashedCode(r.Context(), codeID)
+	if err != nil {
+		if errors.Is(err, domain.ErrCodeNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if code.UserID != userID {
+		rest.writeErrorCode(w, http.StatusForbidden, "unauthorized")
+		return
+	}
+
+	err = rest.service.RestoreCode(r.Context(), code)
+	if err != nil {
+		if errors.Is(err, domain.ErrCodeNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	code.DeletedAt = time.Time{}
+	rest.writeJSON(w, http.StatusOK, resources.NewGetCodeResponse(code))
+}
+
+func (rest *Rest) pauseCode(w http.ResponseWriter, r *http.Request) {
+	pr := resources.PauseCodeRequest{}
+	if r.ContentLength != 0 && !rest.readRequest(w, r, &pr) {
+		return
+	}
+	err := pr.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	rest.setCodePaused(w, r, true, pr.FallbackURL)
+}
+
+func (rest *Rest) resumeCode(w http.ResponseWriter, r *http.Request) {
+	rest.setCodePaused(w, r, false, "")
+}
+
+// setCodePaused changes state of code from URL and writes code
+func (rest *Rest) setCodePaused(w http.ResponseWriter, r *http.Request, paused bool, fallbackURL string) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	codeIDString := chi.URLParam(r, "codeID")
+	codeID, err := strconv.ParseUint(codeIDString, 10, 64)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "wrong code id")
+		return
+	}
+
+	code, err := rest.service.GetCode(r.Context(), codeID)
+	if err != nil {
+		if errors.Is(err, domain.ErrCodeNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if code.UserID != userID {
+		rest.writeErrorCode(w, http.StatusForbidden, "unauthorized")
+		return
+	}
+	if code.Type.IsStatic() {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "static code couldn't be paused")
+		return
+	}
+
+	code, err = rest.service.SetCodePaused(r.Context(), code, paused, fallbackURL)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	rest.writeJSON(w, http.StatusOK, resources.NewGetCodeResponse(code))
+}
+
+// etagMatches checks If-None-Match header against ETag of response
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, v := range strings.Split(ifNoneMatch, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/hotafrika/griz-backend/internal/server/app"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/api/resources"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+// embedMaxAge is time in seconds public images are cached by browsers and CDNs.
+// Changed style is shown after it, ETag lets clients revalidate cheaply.
+const embedMaxAge = 24 * 60 * 60
+
+func (rest *Rest) embedPNGHandler(w http.ResponseWriter, r *http.Request) {
+	rest.embedCode(w, r, app.EmbedPNG)
+}
+
+func (rest *Rest) embedSVGHandler(w http.ResponseWriter, r *http.Request) {
+	rest.embedCode(w, r, app.EmbedSVG)
+}
+
+// embedCode writes public image of code which could be put in <img src>
+func (rest *Rest) embedCode(w http.ResponseWriter, r *http.Request, format string) {
+	er := resources.NewEmbedRequest(r.URL.Query())
+	err := er.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	image, err := rest.service.EmbedCode(r.Context(), chi.URLParam(r, "token"), app.EmbedOptions{
+		Format:     format,
+		Size:       er.Size,
+		Foreground: er.Foreground,
+		Background: er.Background,
+		QuietZone:  er.QuietZone,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrCodeNotFound):
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+		case errors.Is(err, domain.ErrCodeDisabled):
+			rest.writeErrorCode(w, http.StatusGone, "code is disabled")
+		case errors.Is(err, domain.ErrEmbedDisabled):
+			rest.writeErrorCode(w, http.StatusForbidden, "embedding of code is disabled by owner")
+		case errors.Is(err, qrencoder.ErrTooSmall):
+			rest.writeErrorCode(w, http.StatusBadRequest, "size is too small for code")
+		case errors.Is(err, qrdecoder.ErrUnscannable):
+			rest.writeErrorCode(w, http.StatusUnprocessableEntity, "design of code is not scannable")
+		default:
+			rest.log(r).Error().Err(err).Send()
+			rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	etag := `"` + image.ETag + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(embedMaxAge))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	contentType := "image/png"
+	if format == app.EmbedSVG {
+		contentType = "image/svg+xml"
+		// SVG opened directly mustn't run anything
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'")
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.Itoa(len(image.Data)))
+	w.Write(image.Data)
+}
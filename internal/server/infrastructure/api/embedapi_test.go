@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/app"
+	"github.com/hotafrika/griz-backend/internal/server/app/authtoken"
+	"github.com/hotafrika/griz-backend/internal/server/app/health"
+	"github.com/hotafrika/griz-backend/internal/server/app/password"
+	"github.com/hotafrika/griz-backend/internal/server/app/token"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache/inmemory"
+	repos "github.com/hotafrika/griz-backend/internal/server/infrastructure/database/inmemory"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/ratelimit"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// embedEnv is api with in-memory service
+type embedEnv struct {
+	rest    *Rest
+	service app.CodeService
+	codes   *repos.CodeRepository
+	owner   uint64
+}
+
+func newEmbedEnv(t *testing.T, limits RateLimits) embedEnv {
+	users := repos.NewUserRepository()
+	codes := repos.NewCodeRepository()
+	images := inmemory.NewImageCache(1 << 20)
+	c := inmemory.NewCache()
+	t.Cleanup(func() {
+		images.Close()
+		c.Close()
+	})
+	hashEncryptor, err := token.NewAuthAES("v02", []byte("12345678123456781234567812345678"))
+	assert.NoError(t, err)
+	keyring, err := token.NewKeyring(hashEncryptor)
+	assert.NoError(t, err)
+	linker, err := token.NewLinker(token.DefaultBaseURL)
+	assert.NoError(t, err)
+	logger := zerolog.Nop()
+	service := app.NewCodeService(time.Hour, time.Hour, time.Hour, &logger, c, images, codes, users, nil,
+		repos.NewStyleRepository(codes, users), nil, password.Encryptor{}, authtoken.JWT{}, keyring, linker, nil, "", app.DefaultMaintenancePage)
+	owner, err := users.Create(context.Background(), entities.User{Username: "owner"})
+	assert.NoError(t, err)
+	rest := NewRest("", time.Second, time.Second, ServerTimeouts{}, &logger, service, nil, health.BuildInfo{}, limits)
+	return embedEnv{rest: rest, service: service, codes: codes, owner: owner}
+}
+
+func (e embedEnv) createCode(t *testing.T, name string) entities.Code {
+	ctx := context.Background()
+	id, err := e.service.CreateCode(ctx, entities.Code{UserID: e.owner, Type: entities.CodeTypeURL, SrcURL: "https://example.com/" + name, Name: name})
+	assert.NoError(t, err)
+	code, err := e.codes.Get(ctx, id)
+	assert.NoError(t, err)
+	return code
+}
+
+func (e embedEnv) get(target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	e.rest.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRest_embedCode(t *testing.T) {
+	e := newEmbedEnv(t, RateLimits{})
+	code := e.createCode(t, "plain")
+
+	rec := e.get("/qr/"+code.Hash+".png?size=256", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "public, max-age=86400", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get("Content-Length"))
+	assert.Empty(t, rec.Header().Get("Content-Security-Policy"))
+
+	rec = e.get("/qr/"+code.Hash+".png?size=300", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, rec.Code, "size is rounded to the same image")
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+	assert.Zero(t, rec.Body.Len())
+
+	rec = e.get("/qr/"+code.Hash+".png?size=256&fg=%23102030", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusOK, rec.Code, "override is other image")
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	rec = e.get("/qr/"+code.Hash+".svg", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/svg+xml", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "default-src 'none'")
+}
+
+func TestRest_embedCode_Errors(t *testing.T) {
+	e := newEmbedEnv(t, RateLimits{})
+	ctx := context.Background()
+	disabled := e.createCode(t, "disabled")
+	_, err := e.service.SetCodeEmbedding(ctx, disabled, false)
+	assert.NoError(t, err)
+	trashed := e.createCode(t, "trashed")
+	assert.NoError(t, e.codes.Trash(ctx, trashed.ID, time.Now()))
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"embedding disabled", "/qr/" + disabled.Hash + ".png", http.StatusForbidden},
+		{"trashed", "/qr/" + trashed.Hash + ".png", http.StatusGone},
+		{"unknown", "/qr/v02" + disabled.Hash[3:] + "00.png", http.StatusNotFound},
+		{"invalid size", "/qr/" + trashed.Hash + ".png?size=abc", http.StatusBadRequest},
+		{"invalid color", "/qr/" + trashed.Hash + ".png?fg=red", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := e.get(tt.target, nil)
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+			assert.Empty(t, rec.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestRest_embedCode_RateLimit(t *testing.T) {
+	limit := ratelimit.Limit{Requests: 120, Per: time.Minute}
+	e := newEmbedEnv(t, RateLimits{Public: ratelimit.NewLimiter("public", limit, ratelimit.NewMemoryStore())})
+	code := e.createCode(t, "plain")
+
+	for i := 0; i < limit.Requests; i++ {
+		rec := e.get("/qr/"+code.Hash+".png", nil)
+		if !assert.Equal(t, http.StatusOK, rec.Code, i) {
+			return
+		}
+	}
+	rec := e.get("/qr/"+code.Hash+".png", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	for _, target := range []string{"/p/" + code.Hash, "/" + code.Hash, "/readyz"} {
+		assert.Equal(t, http.StatusTooManyRequests, e.get(target, nil).Code, target)
+	}
+	assert.Equal(t, http.StatusOK, e.get("/healthz", nil).Code, "liveness probe isn't limited")
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"github.com/hotafrika/griz-backend/internal/server/app/health"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/api/resources"
+	"net/http"
+	"sync/atomic"
+)
+
+// healthHandler shows that process is alive. It doesn't check dependencies
+func (rest *Rest) healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	rest.writeJSON(w, http.StatusOK, resources.HealthResponse{Status: resources.StatusOK})
+}
+
+// readyHandler shows if service is able to serve requests
+func (rest *Rest) readyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if atomic.LoadInt32(&rest.stopping) == 1 {
+		rest.writeJSON(w, http.StatusServiceUnavailable, resources.ReadyResponse{
+			Status: resources.StatusStopping,
+			Checks: make([]resources.CheckResponse, 0),
+		})
+		return
+	}
+
+	report := health.Report{Ready: true}
+	if rest.checker != nil {
+		report = rest.checker.Check(r.Context())
+	}
+	resp := resources.NewReadyResponse(report)
+	if !report.Ready {
+		for _, c := range report.Results {
+			if c.Err != nil {
+				rest.log(r).Warn().Str("check", c.Name).Err(c.Err).Msg("readiness check failed")
+			}
+		}
+		rest.writeJSON(w, http.StatusServiceUnavailable, resp)
+		return
+	}
+	rest.writeJSON(w, http.StatusOK, resp)
+}
+
+// versionHandler shows build info
+func (rest *Rest) versionHandler(w http.ResponseWriter, r *http.Request) {
+	rest.writeJSON(w, http.StatusOK, resources.NewVersionResponse(rest.build))
+}
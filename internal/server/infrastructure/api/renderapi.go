@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrsheet"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/api/resources"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// renderCodes returns selected codes as ZIP of images or PDF sheet
+func (rest *Rest) renderCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	rr := resources.RenderCodesRequest{}
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "unable to read body")
+		return
+	}
+	defer r.Body.Close()
+	err = json.Unmarshal(reqBody, &rr)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "unable to deserialize body")
+		return
+	}
+	rr.SetDefaults()
+	err = rr.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "wrong data: "+err.Error())
+		return
+	}
+
+	// sheet is written to temporary file, so client gets either whole file or error status
+	out, err := os.CreateTemp("", "griz-render-*")
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	var sheet qrsheet.Sheet
+	var contentType, filename string
+	switch rr.Format {
+	case "pdf":
+		contentType, filename = "application/pdf", "codes.pdf"
+		sheet, err = qrsheet.NewPDF(out, rr.Layout())
+	default:
+		contentType, filename = "application/zip", "codes.zip"
+		var encoder qrsheet.Encoder = qrencoder.DefaultYeqown(qrencoder.WithPNG())
+		if rr.Image == "svg" {
+			encoder = qrencoder.NewSVG(10)
+		}
+		sheet = qrsheet.NewZIP(out, encoder, rr.Image)
+	}
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, "wrong data: "+err.Error())
+		return
+	}
+
+	err = rest.service.RenderCodes(r.Context(), userID, rr.IDs, sheet)
+	if err == nil {
+		err = sheet.Close()
+	}
+	if err != nil {
+		if errors.Is(err, domain.ErrCodeNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "code not found")
+			return
+		}
+		if errors.Is(err, domain.ErrStyleNotSupported) {
+			rest.writeErrorCode(w, http.StatusUnprocessableEntity, "styled codes could be rendered to zip only")
+			return
+		}
+		if errors.Is(err, qrdecoder.ErrUnscannable) {
+			rest.writeErrorCode(w, http.StatusUnprocessableEntity, "design of code is not scannable, change its style")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	size, err := out.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = out.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, out); err != nil {
+		rest.log(r).Error().Err(err).Msg("unable to send sheet")
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRest_renderCodes(t *testing.T) {
+	e := newEmbedEnv(t, RateLimits{})
+	code := e.createCode(t, "sale")
+	render := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/codes/render", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), userIdInCtx, e.owner))
+		rec := httptest.NewRecorder()
+		e.rest.renderCodes(rec, req)
+		return rec
+	}
+
+	rec := render(fmt.Sprintf(`{"ids":[%d],"format":"pdf"}`, code.ID))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get("Content-Length"), "whole sheet is sent")
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "%PDF"))
+
+	rec = render(fmt.Sprintf(`{"ids":[%d,%d]}`, code.ID, code.ID+100))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"message":"code not found"}`, rec.Body.String(), "no part of archive is sent")
+}
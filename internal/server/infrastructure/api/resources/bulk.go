@@ -0,0 +1,236 @@
+package resources
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/pkg/errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MaxBulkRows is max number of codes in single bulk request
+const MaxBulkRows = 10000
+
+// BulkCodeRow is single code of bulk request
+type BulkCodeRow struct {
+	URL         string   `json:"url"`
+	Label       string   `json:"label"`
+	Tags        []string `json:"tags"`
+	Description string   `json:"description"`
+
+	err error // parsing error of row
+}
+
+// Validate ...
+func (r BulkCodeRow) Validate() error {
+	if r.err != nil {
+		return r.err
+	}
+	if _, err := url.ParseRequestURI(r.URL); err != nil {
+		return errors.Wrap(err, "URL validation: ")
+	}
+	if len([]rune(r.Label)) > maxNameLength {
+		return errors.Errorf("label validation: label couldn't be longer than %d symbols", maxNameLength)
+	}
+	if len([]rune(r.Description)) > maxDescriptionLength {
+		return errors.Errorf("description validation: description couldn't be longer than %d symbols", maxDescriptionLength)
+	}
+	return errors.Wrap(ValidateTags(r.Tags), "tags validation: ")
+}
+
+// Code returns url code of user. Row has to be validated
+func (r BulkCodeRow) Code(userID uint64) entities.Code {
+	return entities.Code{
+		UserID:      userID,
+		Type:        entities.CodeTypeURL,
+		SrcURL:      r.URL,
+		Name:        strings.TrimSpace(r.Label),
+		Description: strings.TrimSpace(r.Description),
+		Tags:        NormalizeTags(r.Tags),
+	}
+}
+
+// BulkCreateRequest contains rows of CSV or JSON lines body
+type BulkCreateRequest struct {
+	Rows []BulkCodeRow
+}
+
+// Validate returns errors of all invalid rows
+func (r BulkCreateRequest) Validate() []BulkRowError {
+	var errs []BulkRowError
+	for i, row := range r.Rows {
+		if err := row.Validate(); err != nil {
+			errs = append(errs, BulkRowError{Row: i + 1, Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// ParseBulkCSV parses CSV with url, label, tags and description columns.
+// Header row is optional. Tags are separated by commas or semicolons.
+func ParseBulkCSV(r io.Reader) (BulkCreateRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{"url": 0, "label": 1, "tags": 2, "description": 3}
+	req := BulkCreateRequest{}
+	first := true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return req, errors.Wrap(err, "read CSV: ")
+		}
+		if first {
+			first = false
+			if header, ok := parseBulkHeader(record); ok {
+				columns = header
+				continue
+			}
+		}
+		if len(req.Rows) == MaxBulkRows {
+			return req, errors.Errorf("request couldn't contain more than %d rows", MaxBulkRows)
+		}
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		req.Rows = append(req.Rows, BulkCodeRow{
+			URL:         field("url"),
+			Label:       field("label"),
+			Tags:        splitTags(field("tags")),
+			Description: field("description"),
+		})
+	}
+	return req, nil
+}
+
+// parseBulkHeader returns column indexes if record is header
+func parseBulkHeader(record []string) (map[string]int, bool) {
+	columns := make(map[string]int, len(record))
+	for i, name := range record {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "name" {
+			name = "label"
+		}
+		columns[name] = i
+	}
+	_, ok := columns["url"]
+	return columns, ok
+}
+
+// ParseBulkJSONLines parses one JSON object per line. Empty lines are skipped
+func ParseBulkJSONLines(r io.Reader) (BulkCreateRequest, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	req := BulkCreateRequest{}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if len(req.Rows) == MaxBulkRows {
+			return req, errors.Errorf("request couldn't contain more than %d rows", MaxBulkRows)
+		}
+		row := BulkCodeRow{}
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			row = BulkCodeRow{err: errors.Wrap(err, "unable to deserialize row: ")}
+		}
+		req.Rows = append(req.Rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return req, errors.Wrap(err, "read JSON lines: ")
+	}
+	return req, nil
+}
+
+// BulkRowError describes invalid row. Row is 1-based number of data row (header is not counted)
+type BulkRowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// BulkErrorResponse ...
+type BulkErrorResponse struct {
+	Message string         `json:"message"`
+	Errors  []BulkRowError `json:"errors"`
+}
+
+// BulkCreateResponse ...
+type BulkCreateResponse struct {
+	IDs []uint64 `json:"ids"`
+}
+
+// ExportCode is single code of export
+type ExportCode struct {
+	ID          uint64   `json:"id"`
+	Type        string   `json:"type"`
+	URL         string   `json:"url,omitempty"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Hash        string   `json:"hash"`
+	Slug        string   `json:"slug,omitempty"`
+	Link        string   `json:"link,omitempty"`
+}
+
+// ExportCSVHeader is header row of CSV export
+var ExportCSVHeader = []string{"id", "type", "url", "name", "description", "tags", "hash", "slug", "link"}
+
+// NewExportCode creates export row from code and its link
+func NewExportCode(code entities.Code, link string) ExportCode {
+	tags := code.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	codeType := code.Type
+	if codeType == "" {
+		codeType = entities.CodeTypeURL
+	}
+	return ExportCode{
+		ID:          code.ID,
+		Type:        string(codeType),
+		URL:         code.SrcURL,
+		Name:        code.Name,
+		Description: code.Description,
+		Tags:        tags,
+		Hash:        code.Hash,
+		Slug:        code.Slug,
+		Link:        link,
+	}
+}
+
+// CSVRecord returns code as CSV row of ExportCSVHeader columns.
+// Cells set by user are escaped, so spreadsheets don't evaluate them as formulas
+func (c ExportCode) CSVRecord() []string {
+	return []string{
+		strconv.FormatUint(c.ID, 10),
+		c.Type,
+		csvCell(c.URL),
+		csvCell(c.Name),
+		csvCell(c.Description),
+		csvCell(strings.Join(c.Tags, ";")),
+		c.Hash,
+		c.Slug,
+		c.Link,
+	}
+}
+
+// csvCell prefixes value which spreadsheet would take for formula with quote
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,87 @@
+package resources
+
+import (
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+// MaxMoveCodes is max number of codes moved by single request
+const MaxMoveCodes = 1000
+
+// CampaignRequest is used for creation and update of campaign
+type CampaignRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Validate ...
+func (r CampaignRequest) Validate() error {
+	name := strings.TrimSpace(r.Name)
+	if name == "" || len([]rune(name)) > maxNameLength {
+		return errors.Errorf("name validation: name has to be 1-%d symbols long", maxNameLength)
+	}
+	if len([]rune(r.Description)) > maxDescriptionLength {
+		return errors.Errorf("description validation: description couldn't be longer than %d symbols", maxDescriptionLength)
+	}
+	return nil
+}
+
+// Fill sets requested fields to campaign. Request has to be validated
+func (r CampaignRequest) Fill(campaign *entities.Campaign) {
+	campaign.Name = strings.TrimSpace(r.Name)
+	campaign.Description = strings.TrimSpace(r.Description)
+}
+
+// CampaignCreateResponse ...
+type CampaignCreateResponse struct {
+	ID uint64 `json:"id"`
+}
+
+// GetCampaignResponse ...
+type GetCampaignResponse struct {
+	ID          uint64    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CodeCount   uint64    `json:"code_count"`
+	ScanCount   uint64    `json:"scan_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NewGetCampaignResponse creates response from campaign
+func NewGetCampaignResponse(campaign entities.Campaign) GetCampaignResponse {
+	return GetCampaignResponse{
+		ID:          campaign.ID,
+		Name:        campaign.Name,
+		Description: campaign.Description,
+		CodeCount:   campaign.CodeCount,
+		ScanCount:   campaign.ScanCount,
+		CreatedAt:   campaign.CreatedAt,
+		UpdatedAt:   campaign.UpdatedAt,
+	}
+}
+
+// GetCampaignsResponse ...
+type GetCampaignsResponse struct {
+	Campaigns []GetCampaignResponse `json:"campaigns"`
+}
+
+// MoveCodesRequest lists codes moved to campaign
+type MoveCodesRequest struct {
+	IDs []uint64 `json:"ids"`
+}
+
+// Validate ...
+func (r MoveCodesRequest) Validate() error {
+	if len(r.IDs) == 0 || len(r.IDs) > MaxMoveCodes {
+		return errors.Errorf("ids validation: 1-%d codes have to be selected", MaxMoveCodes)
+	}
+	return nil
+}
+
+// CodeCampaignRequest moves single code. CampaignID 0 removes code from campaign
+type CodeCampaignRequest struct {
+	CampaignID uint64 `json:"campaign_id"`
+}
@@ -1,19 +1,99 @@
 package resources
 
 import (
+	"encoding/json"
+	"github.com/hotafrika/griz-backend/internal/server/app/payload"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/token"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
 	"github.com/pkg/errors"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // CodeCreateRequest ...
+// Slug is used only during creation. Use CodeSlugRequest to change it.
+// Omitted name, description and tags are not changed during update.
 type CodeCreateRequest struct {
-	URL string `json:"url"`
+	Type        string          `json:"type"`
+	URL         string          `json:"url"`
+	Payload     json.RawMessage `json:"payload"`
+	Slug        string          `json:"slug"`
+	Name        *string         `json:"name"`
+	Description *string         `json:"description"`
+	Tags        []string        `json:"tags"`
+}
+
+// CodeType returns type of requested code. URL is default
+func (r CodeCreateRequest) CodeType() entities.CodeType {
+	if r.Type == "" {
+		return entities.CodeTypeURL
+	}
+	return entities.CodeType(r.Type)
 }
 
 // Validate ...
 func (r CodeCreateRequest) Validate() error {
-	_, err := url.ParseRequestURI(r.URL)
-	return errors.Wrap(err, "URL validation: ")
+	t := r.CodeType()
+	if !t.IsValid() {
+		return errors.New("type validation: unknown type")
+	}
+	if r.Slug != "" {
+		if t.IsStatic() {
+			return errors.New("slug validation: static code couldn't have slug")
+		}
+		if err := token.ValidateSlug(r.Slug); err != nil {
+			return errors.Wrap(err, "slug validation: ")
+		}
+	}
+	if r.Name != nil && len([]rune(*r.Name)) > maxNameLength {
+		return errors.Errorf("name validation: name couldn't be longer than %d symbols", maxNameLength)
+	}
+	if r.Description != nil && len([]rune(*r.Description)) > maxDescriptionLength {
+		return errors.Errorf("description validation: description couldn't be longer than %d symbols", maxDescriptionLength)
+	}
+	if err := ValidateTags(r.Tags); err != nil {
+		return errors.Wrap(err, "tags validation: ")
+	}
+	if t == entities.CodeTypeURL {
+		_, err := url.ParseRequestURI(r.URL)
+		return errors.Wrap(err, "URL validation: ")
+	}
+	_, err := payload.Parse(t, r.Payload)
+	return errors.Wrap(err, "payload validation: ")
+}
+
+// Fill sets requested content to code. Request has to be validated
+func (r CodeCreateRequest) Fill(code *entities.Code) error {
+	if r.Name != nil {
+		code.Name = strings.TrimSpace(*r.Name)
+	}
+	if r.Description != nil {
+		code.Description = strings.TrimSpace(*r.Description)
+	}
+	if r.Tags != nil {
+		code.Tags = NormalizeTags(r.Tags)
+	}
+	code.Type = r.CodeType()
+	code.SrcURL = ""
+	code.Payload = ""
+	if code.Type == entities.CodeTypeURL {
+		code.SrcURL = r.URL
+		return nil
+	}
+	p, err := payload.Parse(code.Type, r.Payload)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	code.Payload = string(b)
+	return nil
 }
 
 // CodeCreateResponse ...
@@ -21,6 +101,19 @@ type CodeCreateResponse struct {
 	ID uint64 `json:"id"`
 }
 
+// CodeSlugRequest ...
+type CodeSlugRequest struct {
+	Slug string `json:"slug"`
+}
+
+// Validate ...
+func (r CodeSlugRequest) Validate() error {
+	if r.Slug == "" {
+		return nil
+	}
+	return token.ValidateSlug(r.Slug)
+}
+
 // UpdateCodeRequest ...
 type UpdateCodeRequest struct {
 	ID  uint64 `json:"id"`
@@ -39,10 +132,127 @@ func (r UpdateCodeRequest) Validate() error {
 	return nil
 }
 
+// PauseCodeRequest pauses code. Empty FallbackURL means default fallback of server
+type PauseCodeRequest struct {
+	FallbackURL string `json:"fallback_url"`
+}
+
+// Validate ...
+func (r PauseCodeRequest) Validate() error {
+	if r.FallbackURL == "" {
+		return nil
+	}
+	u, err := url.ParseRequestURI(r.FallbackURL)
+	if err != nil {
+		return errors.Wrap(err, "fallback URL validation: ")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("fallback URL validation: absolute http(s) URL is required")
+	}
+	return nil
+}
+
 // GetCodeResponse ...
 type GetCodeResponse struct {
-	ID  uint64 `json:"id"`
-	URL string `json:"url"`
+	ID          uint64          `json:"id"`
+	Type        string          `json:"type"`
+	URL         string          `json:"url,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Hash        string          `json:"hash,omitempty"`
+	Slug        string          `json:"slug,omitempty"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Tags        []string        `json:"tags"`
+	CampaignID  uint64          `json:"campaign_id,omitempty"`
+	StyleID     uint64          `json:"style_id,omitempty"`
+	Embeddable  bool            `json:"embeddable"`
+	ScanCount   uint64          `json:"scan_count"`
+	Paused      bool            `json:"paused"`
+	PausedURL   string          `json:"paused_url,omitempty"`
+	DeletedAt   *time.Time      `json:"deleted_at,omitempty"`
+}
+
+// NewGetCodeResponse creates response from code
+func NewGetCodeResponse(code entities.Code) GetCodeResponse {
+	r := GetCodeResponse{
+		ID:          code.ID,
+		Type:        string(code.Type),
+		URL:         code.SrcURL,
+		Hash:        code.Hash,
+		Slug:        code.Slug,
+		Name:        code.Name,
+		Description: code.Description,
+		Tags:        code.Tags,
+		CampaignID:  code.CampaignID,
+		StyleID:     code.StyleID,
+		Embeddable:  !code.EmbedDisabled,
+		ScanCount:   code.ScanCount,
+		Paused:      code.Paused,
+		PausedURL:   code.PausedURL,
+	}
+	if r.Tags == nil {
+		r.Tags = []string{}
+	}
+	if r.Type == "" {
+		r.Type = string(entities.CodeTypeURL)
+	}
+	if code.Payload != "" {
+		r.Payload = json.RawMessage(code.Payload)
+	}
+	if code.IsTrashed() {
+		deletedAt := code.DeletedAt
+		r.DeletedAt = &deletedAt
+	}
+	return r
+}
+
+// CodeListRequest is query of code list: ?tag=a&tag=b&q=text&campaign=1.
+// Tags could be also separated by commas.
+type CodeListRequest struct {
+	Tags       []string
+	Query      string
+	CampaignID uint64
+
+	err error // parsing error of query
+}
+
+// NewCodeListRequest parses query values
+func NewCodeListRequest(values url.Values) CodeListRequest {
+	var tags []string
+	for _, v := range values["tag"] {
+		tags = append(tags, splitTags(v)...)
+	}
+	r := CodeListRequest{
+		Tags:  NormalizeTags(tags),
+		Query: strings.TrimSpace(values.Get("q")),
+	}
+	if campaign := values.Get("campaign"); campaign != "" {
+		r.CampaignID, r.err = strconv.ParseUint(campaign, 10, 64)
+	}
+	return r
+}
+
+// Validate ...
+func (r CodeListRequest) Validate() error {
+	if r.err != nil {
+		return errors.Wrap(r.err, "campaign validation: ")
+	}
+	if err := ValidateTags(r.Tags); err != nil {
+		return errors.Wrap(err, "tag validation: ")
+	}
+	if len([]rune(r.Query)) > maxNameLength {
+		return errors.Errorf("query validation: query couldn't be longer than %d symbols", maxNameLength)
+	}
+	return nil
+}
+
+// Filter returns repository filter
+func (r CodeListRequest) Filter() domain.CodeFilter {
+	return domain.CodeFilter{
+		Tags:       r.Tags,
+		Query:      r.Query,
+		CampaignID: r.CampaignID,
+	}
 }
 
 // GetCodesResponse ...
@@ -52,7 +262,26 @@ type GetCodesResponse struct {
 
 // DownloadCodeResponse ...
 type DownloadCodeResponse struct {
-	Code string `json:"code"`
+	Code         string               `json:"code"`
+	Scannability ScannabilityResponse `json:"scannability"`
+}
+
+// ScannabilityResponse is result of decoding rendered QR in simulated conditions
+type ScannabilityResponse struct {
+	Score    int      `json:"score"`
+	Warnings []string `json:"warnings"`
+}
+
+// NewScannabilityResponse creates response from report of decoder
+func NewScannabilityResponse(report qrdecoder.Report) ScannabilityResponse {
+	r := ScannabilityResponse{
+		Score:    report.Score,
+		Warnings: report.Warnings,
+	}
+	if r.Warnings == nil {
+		r.Warnings = []string{}
+	}
+	return r
 }
 
 // DeleteCodeResponse ...
@@ -0,0 +1,91 @@
+package resources
+
+import (
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/pkg/errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MinEmbedSize is min width of public code image in pixels
+	MinEmbedSize = 64
+	// MaxEmbedSize is max width of public code image in pixels
+	MaxEmbedSize = 1024
+	// DefaultEmbedSize is width of public code image without size in query
+	DefaultEmbedSize = 256
+)
+
+// EmbedRequest is query of public code image: ?size=256&fg=1a237e&bg=ffffff&margin=2.
+// Colors are given without # as it starts fragment of URL.
+type EmbedRequest struct {
+	Size       int
+	Foreground string
+	Background string
+	QuietZone  *int
+
+	err error // parsing error of query
+}
+
+// NewEmbedRequest parses query values
+func NewEmbedRequest(values url.Values) EmbedRequest {
+	r := EmbedRequest{
+		Size:       DefaultEmbedSize,
+		Foreground: embedColor(values.Get("fg")),
+		Background: embedColor(values.Get("bg")),
+	}
+	if size := values.Get("size"); size != "" {
+		r.Size, r.err = strconv.Atoi(size)
+		if r.err != nil {
+			r.err = errors.Wrap(r.err, "size validation: ")
+			return r
+		}
+	}
+	if margin := values.Get("margin"); margin != "" {
+		quietZone, err := strconv.Atoi(margin)
+		if err != nil {
+			r.err = errors.Wrap(err, "margin validation: ")
+			return r
+		}
+		r.QuietZone = &quietZone
+	}
+	return r
+}
+
+// Validate ...
+func (r EmbedRequest) Validate() error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.Size < MinEmbedSize || r.Size > MaxEmbedSize {
+		return errors.Errorf("size validation: size has to be %d-%d pixels", MinEmbedSize, MaxEmbedSize)
+	}
+	if r.Foreground != "" {
+		if _, err := qrencoder.ParseHexColor(r.Foreground); err != nil {
+			return errors.Wrap(err, "fg validation: ")
+		}
+	}
+	if r.Background != "" {
+		if _, err := qrencoder.ParseHexColor(r.Background); err != nil {
+			return errors.Wrap(err, "bg validation: ")
+		}
+	}
+	if r.QuietZone != nil && (*r.QuietZone < 0 || *r.QuietZone > qrencoder.MaxQuietZone) {
+		return errors.Errorf("margin validation: margin has to be 0-%d modules", qrencoder.MaxQuietZone)
+	}
+	return nil
+}
+
+// embedColor returns color of query in #rrggbb form
+func embedColor(v string) string {
+	if v == "" {
+		return ""
+	}
+	return qrencoder.NormalizeHexColor("#" + strings.TrimPrefix(v, "#"))
+}
+
+// CodeEmbedRequest allows or forbids public image of code
+type CodeEmbedRequest struct {
+	Enabled bool `json:"enabled"`
+}
@@ -0,0 +1,71 @@
+package resources
+
+import (
+	"github.com/hotafrika/griz-backend/internal/server/app/health"
+)
+
+// Statuses of probes
+const (
+	StatusOK       = "ok"
+	StatusFailed   = "failed"
+	StatusReady    = "ready"
+	StatusNotReady = "not ready"
+	StatusStopping = "stopping"
+)
+
+// HealthResponse ...
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// CheckResponse is result of one readiness check. Errors are only logged, probe is public
+type CheckResponse struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// ReadyResponse ...
+type ReadyResponse struct {
+	Status string          `json:"status"`
+	Checks []CheckResponse `json:"checks"`
+}
+
+// NewReadyResponse creates response from report of checks
+func NewReadyResponse(report health.Report) ReadyResponse {
+	resp := ReadyResponse{
+		Status: StatusReady,
+		Checks: make([]CheckResponse, 0, len(report.Results)),
+	}
+	if !report.Ready {
+		resp.Status = StatusNotReady
+	}
+	for _, r := range report.Results {
+		c := CheckResponse{
+			Name:   r.Name,
+			Status: StatusOK,
+		}
+		if r.Err != nil {
+			c.Status = StatusFailed
+		}
+		resp.Checks = append(resp.Checks, c)
+	}
+	return resp
+}
+
+// VersionResponse ...
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// NewVersionResponse creates response from build info
+func NewVersionResponse(build health.BuildInfo) VersionResponse {
+	return VersionResponse{
+		Version:   build.Version,
+		Commit:    build.Commit,
+		BuildDate: build.BuildDate,
+		GoVersion: build.GoVersion,
+	}
+}
@@ -1,7 +1,7 @@
 package resources
 
 import (
-	"github.com/hotafrika/griz-backend/internal/server/app/token"
+	"github.com/pkg/errors"
 )
 
 // LinkHashRequest is used fot parsing requests
@@ -10,9 +10,12 @@ type LinkHashRequest struct {
 	URL  string `json:"url"`
 }
 
-// Parse return parsed token
-func (sl LinkHashRequest) Parse() (string, error) {
-	return token.ExtractHashFromLink(sl.URL)
+// Validate ...
+func (sl LinkHashRequest) Validate() error {
+	if sl.URL == "" {
+		return errors.New("url is empty")
+	}
+	return nil
 }
 
 // LinkHashResponse serves responses
@@ -0,0 +1,82 @@
+package resources
+
+import (
+	"github.com/hotafrika/griz-backend/internal/server/app/qrsheet"
+	"github.com/pkg/errors"
+)
+
+// MaxRenderCodes is max number of codes in single render request.
+// PNG takes about 17 ms per code, so request is rendered within default request timeout (10s)
+const MaxRenderCodes = 250
+
+// RenderCodesRequest selects codes for ZIP archive or PDF sheet
+type RenderCodesRequest struct {
+	IDs       []uint64 `json:"ids"`
+	Format    string   `json:"format"`     // zip (default) or pdf
+	Image     string   `json:"image"`      // png (default) or svg, zip only
+	Page      string   `json:"page"`       // a4 (default) or letter, pdf only
+	Columns   int      `json:"columns"`    // pdf only, 3 by default
+	Rows      int      `json:"rows"`       // pdf only, 4 by default
+	Captions  *bool    `json:"captions"`   // pdf only, true by default
+	CropMarks bool     `json:"crop_marks"` // pdf only
+}
+
+// SetDefaults sets default values of omitted fields
+func (r *RenderCodesRequest) SetDefaults() {
+	if r.Format == "" {
+		r.Format = "zip"
+	}
+	if r.Image == "" {
+		r.Image = "png"
+	}
+	if r.Page == "" {
+		r.Page = "a4"
+	}
+	if r.Columns == 0 {
+		r.Columns = 3
+	}
+	if r.Rows == 0 {
+		r.Rows = 4
+	}
+	if r.Captions == nil {
+		captions := true
+		r.Captions = &captions
+	}
+}
+
+// Validate ...
+func (r RenderCodesRequest) Validate() error {
+	if len(r.IDs) == 0 || len(r.IDs) > MaxRenderCodes {
+		return errors.Errorf("ids validation: 1-%d codes have to be selected", MaxRenderCodes)
+	}
+	if r.Format != "zip" && r.Format != "pdf" {
+		return errors.New("format validation: format has to be zip or pdf")
+	}
+	if r.Image != "png" && r.Image != "svg" {
+		return errors.New("image validation: image has to be png or svg")
+	}
+	if _, ok := pageSizes[r.Page]; !ok {
+		return errors.New("page validation: page has to be a4 or letter")
+	}
+	if r.Columns < 1 || r.Columns > 10 || r.Rows < 1 || r.Rows > 15 {
+		return errors.New("grid validation: grid could be up to 10 columns and 15 rows")
+	}
+	return nil
+}
+
+// Layout returns PDF layout. Request has to be validated
+func (r RenderCodesRequest) Layout() qrsheet.Layout {
+	return qrsheet.Layout{
+		Page:      pageSizes[r.Page],
+		Columns:   r.Columns,
+		Rows:      r.Rows,
+		Margin:    36,
+		Captions:  r.Captions != nil && *r.Captions,
+		CropMarks: r.CropMarks,
+	}
+}
+
+var pageSizes = map[string]qrsheet.PageSize{
+	"a4":     qrsheet.PageA4,
+	"letter": qrsheet.PageLetter,
+}
@@ -0,0 +1,168 @@
+package resources
+
+import (
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+const (
+	defaultForeground      = "#000000"
+	defaultBackground      = "#ffffff"
+	defaultShape           = qrencoder.ShapeSquare
+	defaultQuietZone       = 4
+	defaultErrorCorrection = qrencoder.ECLevelQuart
+)
+
+// StyleRequest is used for creation and update of style. Omitted fields get default values
+type StyleRequest struct {
+	Name            string `json:"name"`
+	Foreground      string `json:"foreground"`
+	Background      string `json:"background"`
+	Shape           string `json:"shape"`
+	QuietZone       *int   `json:"quiet_zone"`
+	ErrorCorrection string `json:"error_correction"`
+	LogoID          uint64 `json:"logo_id"`
+}
+
+// Validate ...
+func (r StyleRequest) Validate() error {
+	name := strings.TrimSpace(r.Name)
+	if name == "" || len([]rune(name)) > maxNameLength {
+		return errors.Errorf("name validation: name has to be 1-%d symbols long", maxNameLength)
+	}
+	if r.Foreground != "" {
+		if _, err := qrencoder.ParseHexColor(qrencoder.NormalizeHexColor(r.Foreground)); err != nil {
+			return errors.Wrap(err, "foreground validation: ")
+		}
+	}
+	if r.Background != "" {
+		if _, err := qrencoder.ParseHexColor(qrencoder.NormalizeHexColor(r.Background)); err != nil {
+			return errors.Wrap(err, "background validation: ")
+		}
+	}
+	if r.Shape != "" && !qrencoder.Shape(r.Shape).IsValid() {
+		return errors.New("shape validation: shape has to be square, circle or rounded")
+	}
+	if r.QuietZone != nil && (*r.QuietZone < 0 || *r.QuietZone > qrencoder.MaxQuietZone) {
+		return errors.Errorf("quiet_zone validation: quiet zone has to be 0-%d modules", qrencoder.MaxQuietZone)
+	}
+	if r.ErrorCorrection != "" && !qrencoder.ECLevel(strings.ToUpper(r.ErrorCorrection)).IsValid() {
+		return errors.New("error_correction validation: error correction has to be L, M, Q or H")
+	}
+	return nil
+}
+
+// Fill sets requested fields to style. Request has to be validated
+func (r StyleRequest) Fill(style *entities.Style) {
+	style.Name = strings.TrimSpace(r.Name)
+	style.Foreground = defaultForeground
+	if r.Foreground != "" {
+		style.Foreground = qrencoder.NormalizeHexColor(r.Foreground)
+	}
+	style.Background = defaultBackground
+	if r.Background != "" {
+		style.Background = qrencoder.NormalizeHexColor(r.Background)
+	}
+	style.Shape = string(defaultShape)
+	if r.Shape != "" {
+		style.Shape = r.Shape
+	}
+	style.QuietZone = defaultQuietZone
+	if r.QuietZone != nil {
+		style.QuietZone = *r.QuietZone
+	}
+	style.ErrorCorrection = string(defaultErrorCorrection)
+	if r.ErrorCorrection != "" {
+		style.ErrorCorrection = strings.ToUpper(r.ErrorCorrection)
+	}
+	style.LogoID = r.LogoID
+}
+
+// StyleCreateResponse ...
+type StyleCreateResponse struct {
+	ID uint64 `json:"id"`
+}
+
+// GetStyleResponse ...
+type GetStyleResponse struct {
+	ID              uint64    `json:"id"`
+	Name            string    `json:"name"`
+	Foreground      string    `json:"foreground"`
+	Background      string    `json:"background"`
+	Shape           string    `json:"shape"`
+	QuietZone       int       `json:"quiet_zone"`
+	ErrorCorrection string    `json:"error_correction"`
+	LogoID          uint64    `json:"logo_id,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// NewGetStyleResponse creates response from style
+func NewGetStyleResponse(style entities.Style) GetStyleResponse {
+	return GetStyleResponse{
+		ID:              style.ID,
+		Name:            style.Name,
+		Foreground:      style.Foreground,
+		Background:      style.Background,
+		Shape:           style.Shape,
+		QuietZone:       style.QuietZone,
+		ErrorCorrection: style.ErrorCorrection,
+		LogoID:          style.LogoID,
+		CreatedAt:       style.CreatedAt,
+		UpdatedAt:       style.UpdatedAt,
+	}
+}
+
+// GetStylesResponse ...
+type GetStylesResponse struct {
+	Styles []GetStyleResponse `json:"styles"`
+}
+
+// StyleIDRequest selects style of code or default style of user. StyleID 0 removes style
+type StyleIDRequest struct {
+	StyleID uint64 `json:"style_id"`
+}
+
+// LogoNameFromQuery returns name of uploaded logo
+func LogoNameFromQuery(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxNameLength {
+		return "", errors.Errorf("name validation: name has to be 1-%d symbols long", maxNameLength)
+	}
+	return name, nil
+}
+
+// LogoCreateResponse ...
+type LogoCreateResponse struct {
+	ID     uint64 `json:"id"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// GetLogoResponse ...
+type GetLogoResponse struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewGetLogoResponse creates response from logo
+func NewGetLogoResponse(logo entities.Logo) GetLogoResponse {
+	return GetLogoResponse{
+		ID:        logo.ID,
+		Name:      logo.Name,
+		Width:     logo.Width,
+		Height:    logo.Height,
+		CreatedAt: logo.CreatedAt,
+	}
+}
+
+// GetLogosResponse ...
+type GetLogosResponse struct {
+	Logos []GetLogoResponse `json:"logos"`
+}
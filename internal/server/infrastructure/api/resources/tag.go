@@ -0,0 +1,57 @@
+package resources
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// maxTags is max number of tags per code
+const maxTags = 20
+
+// maxTagLength is max length of single tag
+const maxTagLength = 32
+
+// maxNameLength is max length of code name
+const maxNameLength = 255
+
+// maxDescriptionLength is max length of code description
+const maxDescriptionLength = 2000
+
+// NormalizeTags returns trimmed lower case tags without empty and duplicated ones
+func NormalizeTags(tags []string) []string {
+	res := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		res = append(res, tag)
+	}
+	return res
+}
+
+// ValidateTags checks number and length of tags
+func ValidateTags(tags []string) error {
+	tags = NormalizeTags(tags)
+	if len(tags) > maxTags {
+		return errors.Errorf("code couldn't have more than %d tags", maxTags)
+	}
+	for _, tag := range tags {
+		if len([]rune(tag)) > maxTagLength {
+			return errors.Errorf("tag couldn't be longer than %d symbols", maxTagLength)
+		}
+	}
+	return nil
+}
+
+// splitTags splits tags list separated by commas or semicolons
+func splitTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';'
+	})
+}
@@ -1,9 +1,56 @@
 package resources
 
+import (
+	"github.com/hotafrika/griz-backend/internal/server/app/token"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+)
+
 // SelfUserResponse ...
 type SelfUserResponse struct {
 	ID       uint64 `json:"id"`
 	Username string `json:"username"`
 	Password string `json:"password,omitempty"`
 	Email    string `json:"email"`
+	Domain   string `json:"domain,omitempty"`
+	StyleID  uint64 `json:"default_style_id,omitempty"`
+	// PendingDomain is used after DomainRecord is added to its DNS and verified
+	PendingDomain string                `json:"pending_domain,omitempty"`
+	DomainRecord  *DomainRecordResponse `json:"domain_record,omitempty"`
+}
+
+// DomainRecordResponse is DNS record which proves ownership of domain
+type DomainRecordResponse struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// NewSelfUserResponse ...
+func NewSelfUserResponse(user entities.User) SelfUserResponse {
+	resp := SelfUserResponse{
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		Domain:        user.Domain,
+		StyleID:       user.DefaultStyleID,
+		PendingDomain: user.PendingDomain,
+	}
+	if user.PendingDomain != "" {
+		name, value := token.DomainVerificationRecord(user.PendingDomain, user.DomainToken)
+		resp.DomainRecord = &DomainRecordResponse{Type: "TXT", Name: name, Value: value}
+	}
+	return resp
+}
+
+// UserDomainRequest ...
+type UserDomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+// Validate ...
+func (r UserDomainRequest) Validate() error {
+	if r.Domain == "" {
+		return nil
+	}
+	return token.ValidateDomain(r.Domain)
 }
@@ -0,0 +1,327 @@
+package api
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/api/resources"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// invalidLogoMessage describes images accepted as logo
+var invalidLogoMessage = fmt.Sprintf("logo has to be PNG or JPEG image %d-%d pixels wide with sides ratio up to %d:1",
+	qrencoder.MinLogoSide, qrencoder.MaxLogoSide, qrencoder.MaxLogoAspect)
+
+// StylesRouter returns router for style templates of user
+func (rest *Rest) StylesRouter() chi.Router {
+	router := chi.NewRouter()
+
+	router.Post("/", rest.createStyle)
+	router.Get("/", rest.listStyles)
+
+	router.Route("/{styleID}", func(r chi.Router) {
+		r.Get("/", rest.getStyle)
+		r.Put("/", rest.updateStyle)
+		r.Delete("/", rest.deleteStyle)
+	})
+
+	return router
+}
+
+// LogosRouter returns router for logos of user
+func (rest *Rest) LogosRouter() chi.Router {
+	router := chi.NewRouter()
+
+	router.Post("/", rest.createLogo)
+	router.Get("/", rest.listLogos)
+
+	router.Route("/{logoID}", func(r chi.Router) {
+		r.Get("/", rest.getLogo)
+		r.Delete("/", rest.deleteLogo)
+	})
+
+	return router
+}
+
+func (rest *Rest) createStyle(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	sr := resources.StyleRequest{}
+	if !rest.readRequest(w, r, &sr) {
+		return
+	}
+	err := sr.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	style := entities.Style{UserID: userID}
+	sr.Fill(&style)
+	id, err := rest.service.CreateStyle(r.Context(), style)
+	if err != nil {
+		if errors.Is(err, domain.ErrLogoNotFound) {
+			rest.writeErrorCode(w, http.StatusUnprocessableEntity, "logo not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	rest.writeJSON(w, http.StatusCreated, resources.StyleCreateResponse{ID: id})
+}
+
+func (rest *Rest) listStyles(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	styles, err := rest.service.GetStyles(r.Context(), userID)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	resp := resources.GetStylesResponse{
+		Styles: make([]resources.GetStyleResponse, 0, len(styles)),
+	}
+	for _, style := range styles {
+		resp.Styles = append(resp.Styles, resources.NewGetStyleResponse(style))
+	}
+	rest.writeJSON(w, http.StatusOK, resp)
+}
+
+func (rest *Rest) getStyle(w http.ResponseWriter, r *http.Request) {
+	style, ok := rest.userStyle(w, r)
+	if !ok {
+		return
+	}
+	rest.writeJSON(w, http.StatusOK, resources.NewGetStyleResponse(style))
+}
+
+func (rest *Rest) updateStyle(w http.ResponseWriter, r *http.Request) {
+	style, ok := rest.userStyle(w, r)
+	if !ok {
+		return
+	}
+
+	sr := resources.StyleRequest{}
+	if !rest.readRequest(w, r, &sr) {
+		return
+	}
+	err := sr.Validate()
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	sr.Fill(&style)
+	err = rest.service.UpdateStyle(r.Context(), style)
+	if err != nil {
+		if errors.Is(err, domain.ErrLogoNotFound) {
+			rest.writeErrorCode(w, http.StatusUnprocessableEntity, "logo not found")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	style, err = rest.service.GetStyle(r.Context(), style.ID)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	rest.writeJSON(w, http.StatusOK, resources.NewGetStyleResponse(style))
+}
+
+func (rest *Rest) deleteStyle(w http.ResponseWriter, r *http.Request) {
+	style, ok := rest.userStyle(w, r)
+	if !ok {
+		return
+	}
+
+	err := rest.service.DeleteStyle(r.Context(), style.ID)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// createLogo stores raw PNG or JPEG body as logo. Name of logo is taken from query
+func (rest *Rest) createLogo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	name, err := resources.LogoNameFromQuery(r.URL.Query().Get("name"))
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, qrencoder.MaxLogoBytes)
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusRequestEntityTooLarge,
+			"logo couldn't be bigger than "+strconv.Itoa(qrencoder.MaxLogoBytes)+" bytes")
+		return
+	}
+
+	logo, err := rest.service.CreateLogo(r.Context(), userID, name, data)
+	if err != nil {
+		if errors.Is(err, qrencoder.ErrInvalidLogo) {
+			rest.writeErrorCode(w, http.StatusUnprocessableEntity, invalidLogoMessage)
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	rest.writeJSON(w, http.StatusCreated, resources.LogoCreateResponse{
+		ID:     logo.ID,
+		Width:  logo.Width,
+		Height: logo.Height,
+	})
+}
+
+func (rest *Rest) listLogos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return
+	}
+
+	logos, err := rest.service.GetLogos(r.Context(), userID)
+	if err != nil {
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	resp := resources.GetLogosResponse{
+		Logos: make([]resources.GetLogoResponse, 0, len(logos)),
+	}
+	for _, logo := range logos {
+		resp.Logos = append(resp.Logos, resources.NewGetLogoResponse(logo))
+	}
+	rest.writeJSON(w, http.StatusOK, resp)
+}
+
+// getLogo returns image of logo
+func (rest *Rest) getLogo(w http.ResponseWriter, r *http.Request) {
+	logo, ok := rest.userLogo(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(logo.Data)))
+	w.Write(logo.Data)
+}
+
+func (rest *Rest) deleteLogo(w http.ResponseWriter, r *http.Request) {
+	logo, ok := rest.userLogo(w, r)
+	if !ok {
+		return
+	}
+
+	err := rest.service.DeleteLogo(r.Context(), logo.ID)
+	if err != nil {
+		if errors.Is(err, domain.ErrLogoInUse) {
+			rest.writeErrorCode(w, http.StatusConflict, "logo is used by style")
+			return
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// userStyle returns style from URL if it belongs to user.
+// Error response is written when false is returned.
+func (rest *Rest) userStyle(w http.ResponseWriter, r *http.Request) (entities.Style, bool) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return entities.Style{}, false
+	}
+
+	styleID, err := strconv.ParseUint(chi.URLParam(r, "styleID"), 10, 64)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "wrong style id")
+		return entities.Style{}, false
+	}
+
+	style, err := rest.service.GetStyle(r.Context(), styleID)
+	if err != nil {
+		if errors.Is(err, domain.ErrStyleNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+			return style, false
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return style, false
+	}
+	if style.UserID != userID {
+		rest.writeErrorCode(w, http.StatusForbidden, "unauthorized")
+		return style, false
+	}
+
+	return style, true
+}
+
+// userLogo returns logo from URL if it belongs to user.
+// Error response is written when false is returned.
+func (rest *Rest) userLogo(w http.ResponseWriter, r *http.Request) (entities.Logo, bool) {
+	userID, ok := r.Context().Value(userIdInCtx).(uint64)
+	if !ok {
+		rest.writeErrorCode(w, http.StatusBadRequest, "user is not defined")
+		return entities.Logo{}, false
+	}
+
+	logoID, err := strconv.ParseUint(chi.URLParam(r, "logoID"), 10, 64)
+	if err != nil {
+		rest.writeErrorCode(w, http.StatusBadRequest, "wrong logo id")
+		return entities.Logo{}, false
+	}
+
+	logo, err := rest.service.GetLogo(r.Context(), logoID)
+	if err != nil {
+		if errors.Is(err, domain.ErrLogoNotFound) {
+			rest.writeErrorCode(w, http.StatusNotFound, "not found")
+			return logo, false
+		}
+		rest.log(r).Error().Err(err).Send()
+		rest.writeErrorCode(w, http.StatusInternalServerError, "internal error")
+		return logo, false
+	}
+	if logo.UserID != userID {
+		rest.writeErrorCode(w, http.StatusForbidden, "unauthorized")
+		return logo, false
+	}
+
+	return logo, true
+}
@@ -49,3 +49,11 @@ func (c *Cache) Delete(ctx context.Context, key fmt.Stringer) error {
 	c.rw.Unlock()
 	return nil
 }
+
+// Close drops all values. Cache is empty but usable after Close
+func (c *Cache) Close() error {
+	c.rw.Lock()
+	c.data = make(map[string]string)
+	c.rw.Unlock()
+	return nil
+}
@@ -0,0 +1,92 @@
+package inmemory
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"sync"
+)
+
+// ImageCache is LRU cache of images limited by total size of images
+type ImageCache struct {
+	maxBytes int
+	size     int
+	items    map[string]*list.Element
+	order    *list.List // front is most recently used
+	mu       sync.Mutex
+}
+
+type imageEntry struct {
+	key  string
+	data []byte
+}
+
+var _ domain.ImageCacher = (*ImageCache)(nil)
+
+// NewImageCache creates ImageCache which keeps up to maxBytes of images
+func NewImageCache(maxBytes int) *ImageCache {
+	return &ImageCache{
+		maxBytes: maxBytes,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// GetImage receives image by key
+func (c *ImageCache) GetImage(ctx context.Context, key fmt.Stringer) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key.String()]
+	if !ok {
+		return nil, domain.ErrCacheNotExist
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*imageEntry).data, nil
+}
+
+// SetImage puts image in cache and evicts least recently used images if cache is full.
+// Images bigger than cache are not kept.
+func (c *ImageCache) SetImage(ctx context.Context, key fmt.Stringer, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := key.String()
+	c.remove(k)
+	if len(data) > c.maxBytes {
+		return nil
+	}
+	c.items[k] = c.order.PushFront(&imageEntry{key: k, data: data})
+	c.size += len(data)
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back().Value.(*imageEntry).key)
+	}
+	return nil
+}
+
+// DeleteImage removes image by key
+func (c *ImageCache) DeleteImage(ctx context.Context, key fmt.Stringer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(key.String())
+	return nil
+}
+
+// Close drops all images. Cache is empty but usable after Close
+func (c *ImageCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.size = 0
+	return nil
+}
+
+func (c *ImageCache) remove(key string) {
+	e, ok := c.items[key]
+	if !ok {
+		return
+	}
+	c.order.Remove(e)
+	delete(c.items, key)
+	c.size -= len(e.Value.(*imageEntry).data)
+}
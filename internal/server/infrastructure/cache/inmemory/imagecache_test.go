@@ -0,0 +1,55 @@
+package inmemory
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestImageCache_SetImage(t *testing.T) {
+	ctx := context.TODO()
+	c := NewImageCache(10)
+	image := func(n int) []byte {
+		return make([]byte, n)
+	}
+
+	assert.NoError(t, c.SetImage(ctx, cache.CodeImage{Key: "a"}, image(4)))
+	assert.NoError(t, c.SetImage(ctx, cache.CodeImage{Key: "b"}, image(4)))
+	// a becomes most recently used, so b is evicted
+	_, err := c.GetImage(ctx, cache.CodeImage{Key: "a"})
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetImage(ctx, cache.CodeImage{Key: "c"}, image(4)))
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{name: "recently used", key: "a"},
+		{name: "evicted", key: "b", wantErr: domain.ErrCacheNotExist},
+		{name: "last added", key: "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.GetImage(ctx, cache.CodeImage{Key: tt.key})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+	assert.Equal(t, 8, c.size)
+
+	// replaced image doesn't count twice, too big image is not kept
+	assert.NoError(t, c.SetImage(ctx, cache.CodeImage{Key: "c"}, image(2)))
+	assert.Equal(t, 6, c.size)
+	assert.NoError(t, c.SetImage(ctx, cache.CodeImage{Key: "d"}, image(11)))
+	_, err = c.GetImage(ctx, cache.CodeImage{Key: "d"})
+	assert.ErrorIs(t, err, domain.ErrCacheNotExist)
+
+	assert.NoError(t, c.DeleteImage(ctx, cache.CodeImage{Key: "a"}))
+	assert.Equal(t, 2, c.size)
+}
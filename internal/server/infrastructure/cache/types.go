@@ -23,3 +23,56 @@ type HashUrl struct {
 func (h HashUrl) String() string {
 	return "HashUrl_" + h.Key
 }
+
+type SlugHash struct {
+	Key string
+}
+
+func (s SlugHash) String() string {
+	return "SlugHash_" + s.Key
+}
+
+// CodeImage is rendered QR image of code. Key is code hash with fingerprint of content and style
+type CodeImage struct {
+	Key string
+}
+
+func (c CodeImage) String() string {
+	return "CodeImage_" + c.Key
+}
+
+// CodeImageReport is scannability report of rendered QR image
+type CodeImageReport struct {
+	Key string
+}
+
+func (c CodeImageReport) String() string {
+	return "CodeImageReport_" + c.Key
+}
+
+// HealthProbe is temporary value written by readiness check
+type HealthProbe struct {
+	Key string
+}
+
+func (h HealthProbe) String() string {
+	return "HealthProbe_" + h.Key
+}
+
+// RateLimit is token bucket of rate limiter. Key is name of limiter with key of client
+type RateLimit struct {
+	Key string
+}
+
+func (r RateLimit) String() string {
+	return "RateLimit_" + r.Key
+}
+
+// LoginFailures is counter of failed logins. Key is kind of counter with username or client IP
+type LoginFailures struct {
+	Key string
+}
+
+func (l LoginFailures) String() string {
+	return "LoginFailures_" + l.Key
+}
@@ -0,0 +1,97 @@
+package inmemory
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"sort"
+	"sync"
+)
+
+// CampaignRepository is inmemory implementation. Stats are taken from codes repo
+type CampaignRepository struct {
+	campaigns map[uint64]entities.Campaign
+	codes     *CodeRepository
+	lastID    uint64
+	rmu       sync.RWMutex
+}
+
+var _ domain.CampaignRepository = (*CampaignRepository)(nil)
+
+// NewCampaignRepository creates new CampaignRepository
+func NewCampaignRepository(codes *CodeRepository) *CampaignRepository {
+	return &CampaignRepository{
+		campaigns: make(map[uint64]entities.Campaign),
+		codes:     codes,
+		lastID:    0,
+	}
+}
+
+// List returns campaigns by userID
+func (c *CampaignRepository) List(ctx context.Context, u uint64) ([]entities.Campaign, error) {
+	campaigns := make([]entities.Campaign, 0)
+	c.rmu.RLock()
+	for _, campaign := range c.campaigns {
+		if campaign.UserID == u {
+			campaigns = append(campaigns, campaign)
+		}
+	}
+	c.rmu.RUnlock()
+	sort.Slice(campaigns, func(i, j int) bool {
+		return campaigns[i].ID < campaigns[j].ID
+	})
+	for i := range campaigns {
+		campaigns[i].CodeCount, campaigns[i].ScanCount = c.codes.campaignStats(campaigns[i].ID)
+	}
+	return campaigns, nil
+}
+
+// Get returns campaign by its ID
+func (c *CampaignRepository) Get(ctx context.Context, u uint64) (entities.Campaign, error) {
+	c.rmu.RLock()
+	campaign, ok := c.campaigns[u]
+	c.rmu.RUnlock()
+	if !ok {
+		return entities.Campaign{}, domain.ErrCampaignNotFound
+	}
+	campaign.CodeCount, campaign.ScanCount = c.codes.campaignStats(campaign.ID)
+	return campaign, nil
+}
+
+// Create adds new campaign to repo
+func (c *CampaignRepository) Create(ctx context.Context, campaign entities.Campaign) (uint64, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	newID := c.lastID + 1
+	campaign.ID = newID
+	c.campaigns[newID] = campaign
+	c.lastID = newID
+	return newID, nil
+}
+
+// Update updates name and description of campaign
+func (c *CampaignRepository) Update(ctx context.Context, campaign entities.Campaign) error {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	v, ok := c.campaigns[campaign.ID]
+	if !ok {
+		return domain.ErrCampaignNotFound
+	}
+	v.Name = campaign.Name
+	v.Description = campaign.Description
+	c.campaigns[campaign.ID] = v
+	return nil
+}
+
+// Delete removes campaign and detaches its codes
+func (c *CampaignRepository) Delete(ctx context.Context, u uint64) error {
+	c.rmu.Lock()
+	_, ok := c.campaigns[u]
+	delete(c.campaigns, u)
+	c.rmu.Unlock()
+	if !ok {
+		return domain.ErrCampaignNotFound
+	}
+	c.codes.detachCampaign(u)
+	return nil
+}
@@ -0,0 +1,65 @@
+package inmemory
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCampaignRepository_Get(t *testing.T) {
+	ctx := context.TODO()
+	codes := NewCodeRepository()
+	cr := NewCampaignRepository(codes)
+
+	summer, err := cr.Create(ctx, entities.Campaign{UserID: 1, Name: "Summer"})
+	assert.NoError(t, err)
+	winter, err := cr.Create(ctx, entities.Campaign{UserID: 1, Name: "Winter"})
+	assert.NoError(t, err)
+
+	var ids []uint64
+	for i := 0; i < 3; i++ {
+		id, err := codes.Create(ctx, entities.Code{UserID: 1, SrcURL: "url"})
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	assert.NoError(t, codes.SetCampaign(ctx, summer, ids[:2]))
+	assert.NoError(t, codes.SetCampaign(ctx, winter, ids[2:]))
+	assert.ErrorIs(t, codes.SetCampaign(ctx, winter, []uint64{ids[0], 100}), domain.ErrCodeNotFound)
+	assert.NoError(t, codes.AddScans(ctx, map[uint64]uint64{ids[0]: 2, ids[1]: 1, ids[2]: 1, 100: 5}))
+
+	tests := []struct {
+		name      string
+		id        uint64
+		wantCodes uint64
+		wantScans uint64
+	}{
+		{name: "summer", id: summer, wantCodes: 2, wantScans: 3},
+		{name: "winter", id: winter, wantCodes: 1, wantScans: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			campaign, err := cr.Get(ctx, tt.id)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantCodes, campaign.CodeCount)
+				assert.Equal(t, tt.wantScans, campaign.ScanCount)
+			}
+		})
+	}
+
+	// codes are detached, not deleted
+	assert.NoError(t, cr.Delete(ctx, summer))
+	_, err = cr.Get(ctx, summer)
+	assert.ErrorIs(t, err, domain.ErrCampaignNotFound)
+	code, err := codes.Get(ctx, ids[0])
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(0), code.CampaignID)
+		assert.Equal(t, uint64(2), code.ScanCount)
+	}
+
+	campaigns, err := cr.List(ctx, 1)
+	if assert.NoError(t, err) && assert.Len(t, campaigns, 1) {
+		assert.Equal(t, "Winter", campaigns[0].Name)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 // CodeRepository represents inmemory repo
@@ -37,7 +40,7 @@ func (c *CodeRepository) ListAll(ctx context.Context, u uint64) ([]entities.Code
 	var codes []entities.Code
 	c.rmu.RLock()
 	for _, code := range c.codes {
-		if u == code.UserID {
+		if u == code.UserID && !code.IsTrashed() {
 			codes = append(codes, code)
 		}
 	}
@@ -48,6 +51,31 @@ func (c *CodeRepository) ListAll(ctx context.Context, u uint64) ([]entities.Code
 	return codes, nil
 }
 
+// ListByFilter returns codes by userID selected by filter
+func (c *CodeRepository) ListByFilter(ctx context.Context, u uint64, filter domain.CodeFilter) ([]entities.Code, error) {
+	codes := make([]entities.Code, 0)
+	query := strings.ToLower(filter.Query)
+	c.rmu.RLock()
+	for _, code := range c.codes {
+		if u != code.UserID || code.IsTrashed() != filter.Trashed || !hasTags(code, filter.Tags) {
+			continue
+		}
+		if filter.CampaignID != 0 && code.CampaignID != filter.CampaignID {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(code.Name), query) &&
+			!strings.Contains(strings.ToLower(code.Description), query) {
+			continue
+		}
+		codes = append(codes, code)
+	}
+	c.rmu.RUnlock()
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].ID < codes[j].ID
+	})
+	return codes, nil
+}
+
 // Get returns code by its ID
 func (c *CodeRepository) Get(ctx context.Context, u uint64) (entities.Code, error) {
 	c.rmu.RLock()
@@ -78,16 +106,67 @@ func (c *CodeRepository) GetByHash(ctx context.Context, token string) (entities.
 	return result, nil
 }
 
+// GetBySlug returns code by its slug
+func (c *CodeRepository) GetBySlug(ctx context.Context, slug string) (entities.Code, error) {
+	c.rmu.RLock()
+	defer c.rmu.RUnlock()
+	if slug == "" {
+		return entities.Code{}, domain.ErrCodeNotFound
+	}
+	for _, v := range c.codes {
+		if strings.EqualFold(v.Slug, slug) {
+			return v, nil
+		}
+	}
+	return entities.Code{}, domain.ErrCodeNotFound
+}
+
 // Create adds new code to repo
 func (c *CodeRepository) Create(ctx context.Context, code entities.Code) (uint64, error) {
 	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	if c.slugTaken(code.Slug, 0) {
+		return 0, domain.ErrSlugAlreadyExists
+	}
 	newID := c.lastID + 1
+	code.ID = newID
 	c.codes[newID] = code
 	c.lastID = newID
-	c.rmu.Unlock()
 	return newID, nil
 }
 
+// CreateBatch adds all codes to repo or none of them
+func (c *CodeRepository) CreateBatch(ctx context.Context, codes []entities.Code, hasher func(uint64) (string, error)) ([]uint64, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	lastID := c.lastID
+	ids := make([]uint64, 0, len(codes))
+	rollback := func() {
+		for _, id := range ids {
+			delete(c.codes, id)
+		}
+		c.lastID = lastID
+	}
+	for _, code := range codes {
+		if c.slugTaken(code.Slug, 0) {
+			rollback()
+			return nil, domain.ErrSlugAlreadyExists
+		}
+		newID := c.lastID + 1
+		hash, err := hasher(newID)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		code.ID = newID
+		code.Hash = hash
+		c.codes[newID] = code
+		c.lastID = newID
+		ids = append(ids, newID)
+	}
+	return ids, nil
+}
+
 // Update updates existing code in repo
 func (c *CodeRepository) Update(ctx context.Context, code entities.Code) error {
 	c.rmu.Lock()
@@ -96,6 +175,9 @@ func (c *CodeRepository) Update(ctx context.Context, code entities.Code) error {
 	if !ok {
 		return domain.ErrCodeNotFound
 	}
+	if c.slugTaken(code.Slug, code.ID) {
+		return domain.ErrSlugAlreadyExists
+	}
 	c.codes[code.ID] = code
 	return nil
 }
@@ -111,3 +193,146 @@ func (c *CodeRepository) Delete(ctx context.Context, u uint64) error {
 	delete(c.codes, u)
 	return nil
 }
+
+// Trash marks code as deleted
+func (c *CodeRepository) Trash(ctx context.Context, u uint64, deletedAt time.Time) error {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	code, ok := c.codes[u]
+	if !ok || code.IsTrashed() {
+		return domain.ErrCodeNotFound
+	}
+	code.DeletedAt = deletedAt
+	c.codes[u] = code
+	return nil
+}
+
+// Restore returns trashed code back
+func (c *CodeRepository) Restore(ctx context.Context, u uint64) error {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	code, ok := c.codes[u]
+	if !ok || !code.IsTrashed() {
+		return domain.ErrCodeNotFound
+	}
+	code.DeletedAt = time.Time{}
+	c.codes[u] = code
+	return nil
+}
+
+// Purge removes codes trashed before moment
+func (c *CodeRepository) Purge(ctx context.Context, before time.Time) ([]entities.Code, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	codes := make([]entities.Code, 0)
+	for id, code := range c.codes {
+		if code.IsTrashed() && code.DeletedAt.Before(before) {
+			codes = append(codes, code)
+			delete(c.codes, id)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].ID < codes[j].ID
+	})
+	return codes, nil
+}
+
+// AddScans adds scans to counters of codes. Unknown codes are skipped
+func (c *CodeRepository) AddScans(ctx context.Context, scans map[uint64]uint64) error {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	for id, n := range scans {
+		code, ok := c.codes[id]
+		if !ok {
+			continue
+		}
+		code.ScanCount += n
+		c.codes[id] = code
+	}
+	return nil
+}
+
+// SetCampaign moves all codes to campaign or none of them
+func (c *CodeRepository) SetCampaign(ctx context.Context, campaignID uint64, codeIDs []uint64) error {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	for _, id := range codeIDs {
+		if _, ok := c.codes[id]; !ok {
+			return domain.ErrCodeNotFound
+		}
+	}
+	for _, id := range codeIDs {
+		code := c.codes[id]
+		code.CampaignID = campaignID
+		c.codes[id] = code
+	}
+	return nil
+}
+
+// campaignStats returns number of codes and their scans in campaign
+func (c *CodeRepository) campaignStats(campaignID uint64) (uint64, uint64) {
+	c.rmu.RLock()
+	defer c.rmu.RUnlock()
+	var codes, scans uint64
+	for _, code := range c.codes {
+		if code.CampaignID == campaignID && !code.IsTrashed() {
+			codes++
+			scans += code.ScanCount
+		}
+	}
+	return codes, scans
+}
+
+// detachCampaign removes all codes from campaign
+func (c *CodeRepository) detachCampaign(campaignID uint64) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	for id, code := range c.codes {
+		if code.CampaignID == campaignID {
+			code.CampaignID = 0
+			c.codes[id] = code
+		}
+	}
+}
+
+// detachStyle returns codes of style to default design
+func (c *CodeRepository) detachStyle(styleID uint64) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	for id, code := range c.codes {
+		if code.StyleID == styleID {
+			code.StyleID = 0
+			c.codes[id] = code
+		}
+	}
+}
+
+// hasTags checks if code has all tags
+func hasTags(code entities.Code, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, codeTag := range code.Tags {
+			if codeTag == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// slugTaken checks if slug belongs to any code except codeID. Lock has to be held
+func (c *CodeRepository) slugTaken(slug string, codeID uint64) bool {
+	if slug == "" {
+		return false
+	}
+	for id, v := range c.codes {
+		if id != codeID && strings.EqualFold(v.Slug, slug) {
+			return true
+		}
+	}
+	return false
+}
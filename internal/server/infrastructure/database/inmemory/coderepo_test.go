@@ -2,10 +2,12 @@ package inmemory
 
 import (
 	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
 	"github.com/stretchr/testify/assert"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestCodeRepository_Create(t *testing.T) {
@@ -309,3 +311,262 @@ func TestCodeRepository_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestCodeRepository_GetBySlug(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    entities.Code
+		wantErr error
+	}{
+		{
+			name: "first slug",
+			code: entities.Code{
+				UserID: 1,
+				SrcURL: "1",
+				Hash:   "abc",
+				Slug:   "summer-sale",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "without slug",
+			code: entities.Code{
+				UserID: 1,
+				SrcURL: "2",
+				Hash:   "cde",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "another without slug",
+			code: entities.Code{
+				UserID: 2,
+				SrcURL: "3",
+				Hash:   "def",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "taken slug in other case",
+			code: entities.Code{
+				UserID: 2,
+				SrcURL: "4",
+				Hash:   "efg",
+				Slug:   "Summer-Sale",
+			},
+			wantErr: domain.ErrSlugAlreadyExists,
+		},
+	}
+
+	cr := NewCodeRepository()
+	ctx := context.TODO()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cr.Create(ctx, tt.code)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) && tt.code.Slug != "" {
+				code, err := cr.GetBySlug(ctx, "SUMMER-sale")
+				if assert.NoError(t, err) {
+					assert.Equal(t, tt.code.SrcURL, code.SrcURL)
+				}
+			}
+		})
+	}
+
+	_, err := cr.GetBySlug(ctx, "")
+	assert.ErrorIs(t, err, domain.ErrCodeNotFound)
+
+	id, err := cr.Create(ctx, entities.Code{UserID: 2, SrcURL: "5", Hash: "fgh"})
+	if assert.NoError(t, err) {
+		err = cr.Update(ctx, entities.Code{ID: id, UserID: 2, SrcURL: "5", Hash: "fgh", Slug: "SUMMER-SALE"})
+		assert.ErrorIs(t, err, domain.ErrSlugAlreadyExists)
+	}
+}
+
+func TestCodeRepository_CreateBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		codes   []entities.Code
+		wantIDs []uint64
+		wantErr bool
+	}{
+		{
+			name: "two codes",
+			codes: []entities.Code{
+				{UserID: 1, SrcURL: "1", Name: "first", Tags: []string{"shoes"}},
+				{UserID: 1, SrcURL: "2", Slug: "summer-sale"},
+			},
+			wantIDs: []uint64{1, 2},
+			wantErr: false,
+		},
+		{
+			name: "taken slug",
+			codes: []entities.Code{
+				{UserID: 1, SrcURL: "3"},
+				{UserID: 1, SrcURL: "4", Slug: "Summer-Sale"},
+			},
+			wantIDs: nil,
+			wantErr: true,
+		},
+		{
+			name: "after rollback",
+			codes: []entities.Code{
+				{UserID: 2, SrcURL: "5"},
+			},
+			wantIDs: []uint64{3},
+			wantErr: false,
+		},
+	}
+
+	cr := NewCodeRepository()
+	ctx := context.TODO()
+	hasher := func(id uint64) (string, error) {
+		return "h" + strconv.FormatUint(id, 10), nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := cr.CreateBatch(ctx, tt.codes, hasher)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			for i, id := range ids {
+				code, err := cr.Get(ctx, id)
+				if assert.NoError(t, err) {
+					assert.Equal(t, tt.codes[i].SrcURL, code.SrcURL)
+					assert.Equal(t, tt.codes[i].Tags, code.Tags)
+					assert.Equal(t, "h"+strconv.FormatUint(id, 10), code.Hash)
+				}
+			}
+		})
+	}
+	assert.Len(t, cr.codes, 3)
+}
+
+func TestCodeRepository_ListByFilter(t *testing.T) {
+	codes := []entities.Code{
+		{UserID: 1, SrcURL: "1", Name: "Summer shoes", Tags: []string{"shoes", "summer"}},
+		{UserID: 1, SrcURL: "2", Name: "Winter boots", Description: "Shoes for snow", Tags: []string{"shoes", "winter"}},
+		{UserID: 1, SrcURL: "3", Name: "Menu"},
+		{UserID: 2, SrcURL: "4", Name: "Summer menu", Tags: []string{"summer"}},
+	}
+	tests := []struct {
+		name     string
+		userID   uint64
+		filter   domain.CodeFilter
+		wantURLs []string
+	}{
+		{
+			name:     "all",
+			userID:   1,
+			filter:   domain.CodeFilter{},
+			wantURLs: []string{"1", "2", "3"},
+		},
+		{
+			name:     "single tag",
+			userID:   1,
+			filter:   domain.CodeFilter{Tags: []string{"shoes"}},
+			wantURLs: []string{"1", "2"},
+		},
+		{
+			name:     "all tags",
+			userID:   1,
+			filter:   domain.CodeFilter{Tags: []string{"shoes", "summer"}},
+			wantURLs: []string{"1"},
+		},
+		{
+			name:     "query by name and description",
+			userID:   1,
+			filter:   domain.CodeFilter{Query: "SHOES"},
+			wantURLs: []string{"1", "2"},
+		},
+		{
+			name:     "query and tag",
+			userID:   1,
+			filter:   domain.CodeFilter{Query: "menu", Tags: []string{"summer"}},
+			wantURLs: []string{},
+		},
+		{
+			name:     "another user",
+			userID:   2,
+			filter:   domain.CodeFilter{Query: "menu", Tags: []string{"summer"}},
+			wantURLs: []string{"4"},
+		},
+	}
+
+	cr := NewCodeRepository()
+	ctx := context.TODO()
+	for _, code := range codes {
+		_, err := cr.Create(ctx, code)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := cr.ListByFilter(ctx, tt.userID, tt.filter)
+			if assert.NoError(t, err) {
+				urls := make([]string, 0, len(res))
+				for _, code := range res {
+					urls = append(urls, code.SrcURL)
+				}
+				assert.Equal(t, tt.wantURLs, urls)
+			}
+		})
+	}
+}
+
+func TestCodeRepository_Trash(t *testing.T) {
+	now := time.Date(2021, 12, 8, 12, 0, 0, 0, time.UTC)
+	cr := NewCodeRepository()
+	ctx := context.TODO()
+	for _, url := range []string{"1", "2", "3"} {
+		_, err := cr.Create(ctx, entities.Code{UserID: 1, SrcURL: url})
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+	listURLs := func(trashed bool) []string {
+		res, err := cr.ListByFilter(ctx, 1, domain.CodeFilter{Trashed: trashed})
+		assert.NoError(t, err)
+		urls := make([]string, 0, len(res))
+		for _, code := range res {
+			urls = append(urls, code.SrcURL)
+		}
+		return urls
+	}
+
+	assert.NoError(t, cr.Trash(ctx, 1, now.Add(-48*time.Hour)))
+	assert.NoError(t, cr.Trash(ctx, 2, now))
+	assert.ErrorIs(t, cr.Trash(ctx, 2, now), domain.ErrCodeNotFound)
+	assert.Equal(t, []string{"3"}, listURLs(false))
+	assert.Equal(t, []string{"1", "2"}, listURLs(true))
+
+	code, err := cr.Get(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.True(t, code.IsTrashed())
+	}
+
+	assert.NoError(t, cr.Restore(ctx, 2))
+	assert.ErrorIs(t, cr.Restore(ctx, 3), domain.ErrCodeNotFound)
+	assert.Equal(t, []string{"2", "3"}, listURLs(false))
+
+	assert.NoError(t, cr.Trash(ctx, 2, now))
+	purged, err := cr.Purge(ctx, now.Add(-24*time.Hour))
+	if assert.NoError(t, err) && assert.Len(t, purged, 1) {
+		assert.Equal(t, uint64(1), purged[0].ID)
+	}
+	_, err = cr.Get(ctx, 1)
+	assert.ErrorIs(t, err, domain.ErrCodeNotFound)
+	assert.Equal(t, []string{"2"}, listURLs(true))
+}
@@ -0,0 +1,158 @@
+package inmemory
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StyleRepository is inmemory implementation. Deleted styles are detached from codes and users repos
+type StyleRepository struct {
+	styles      map[uint64]entities.Style
+	logos       map[uint64]entities.Logo
+	codes       *CodeRepository
+	users       *UserRepository
+	lastStyleID uint64
+	lastLogoID  uint64
+	rmu         sync.RWMutex
+}
+
+var _ domain.StyleRepository = (*StyleRepository)(nil)
+
+// NewStyleRepository creates new StyleRepository
+func NewStyleRepository(codes *CodeRepository, users *UserRepository) *StyleRepository {
+	return &StyleRepository{
+		styles: make(map[uint64]entities.Style),
+		logos:  make(map[uint64]entities.Logo),
+		codes:  codes,
+		users:  users,
+	}
+}
+
+// ListStyles returns styles of user
+func (s *StyleRepository) ListStyles(ctx context.Context, userID uint64) ([]entities.Style, error) {
+	styles := make([]entities.Style, 0)
+	s.rmu.RLock()
+	for _, style := range s.styles {
+		if style.UserID == userID {
+			styles = append(styles, style)
+		}
+	}
+	s.rmu.RUnlock()
+	sort.Slice(styles, func(i, j int) bool {
+		return styles[i].ID < styles[j].ID
+	})
+	return styles, nil
+}
+
+// GetStyle returns style by id
+func (s *StyleRepository) GetStyle(ctx context.Context, id uint64) (entities.Style, error) {
+	s.rmu.RLock()
+	defer s.rmu.RUnlock()
+	style, ok := s.styles[id]
+	if !ok {
+		return entities.Style{}, domain.ErrStyleNotFound
+	}
+	return style, nil
+}
+
+// CreateStyle adds new style to repo
+func (s *StyleRepository) CreateStyle(ctx context.Context, style entities.Style) (uint64, error) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	newID := s.lastStyleID + 1
+	style.ID = newID
+	style.CreatedAt = time.Now().UTC()
+	style.UpdatedAt = style.CreatedAt
+	s.styles[newID] = style
+	s.lastStyleID = newID
+	return newID, nil
+}
+
+// UpdateStyle updates existing style
+func (s *StyleRepository) UpdateStyle(ctx context.Context, style entities.Style) error {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	v, ok := s.styles[style.ID]
+	if !ok {
+		return domain.ErrStyleNotFound
+	}
+	style.UserID = v.UserID
+	style.CreatedAt = v.CreatedAt
+	style.UpdatedAt = time.Now().UTC()
+	s.styles[style.ID] = style
+	return nil
+}
+
+// DeleteStyle removes style and detaches it from codes and users
+func (s *StyleRepository) DeleteStyle(ctx context.Context, id uint64) error {
+	s.rmu.Lock()
+	_, ok := s.styles[id]
+	delete(s.styles, id)
+	s.rmu.Unlock()
+	if !ok {
+		return domain.ErrStyleNotFound
+	}
+	s.codes.detachStyle(id)
+	s.users.detachStyle(id)
+	return nil
+}
+
+// ListLogos returns logos of user without data
+func (s *StyleRepository) ListLogos(ctx context.Context, userID uint64) ([]entities.Logo, error) {
+	logos := make([]entities.Logo, 0)
+	s.rmu.RLock()
+	for _, logo := range s.logos {
+		if logo.UserID == userID {
+			logo.Data = nil
+			logos = append(logos, logo)
+		}
+	}
+	s.rmu.RUnlock()
+	sort.Slice(logos, func(i, j int) bool {
+		return logos[i].ID < logos[j].ID
+	})
+	return logos, nil
+}
+
+// GetLogo returns logo by id
+func (s *StyleRepository) GetLogo(ctx context.Context, id uint64) (entities.Logo, error) {
+	s.rmu.RLock()
+	defer s.rmu.RUnlock()
+	logo, ok := s.logos[id]
+	if !ok {
+		return entities.Logo{}, domain.ErrLogoNotFound
+	}
+	return logo, nil
+}
+
+// CreateLogo adds new logo to repo
+func (s *StyleRepository) CreateLogo(ctx context.Context, logo entities.Logo) (uint64, error) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	newID := s.lastLogoID + 1
+	logo.ID = newID
+	logo.CreatedAt = time.Now().UTC()
+	s.logos[newID] = logo
+	s.lastLogoID = newID
+	return newID, nil
+}
+
+// DeleteLogo removes logo if no style uses it
+func (s *StyleRepository) DeleteLogo(ctx context.Context, id uint64) error {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	if _, ok := s.logos[id]; !ok {
+		return domain.ErrLogoNotFound
+	}
+	for _, style := range s.styles {
+		if style.LogoID == id {
+			return domain.ErrLogoInUse
+		}
+	}
+	delete(s.logos, id)
+	return nil
+}
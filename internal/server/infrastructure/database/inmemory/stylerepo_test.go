@@ -0,0 +1,49 @@
+package inmemory
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStyleRepository_Delete(t *testing.T) {
+	ctx := context.TODO()
+	codes := NewCodeRepository()
+	users := NewUserRepository()
+	sr := NewStyleRepository(codes, users)
+
+	userID, err := users.Create(ctx, entities.User{Username: "user1"})
+	assert.NoError(t, err)
+	logoID, err := sr.CreateLogo(ctx, entities.Logo{UserID: userID, Name: "logo", Data: []byte{1}})
+	assert.NoError(t, err)
+	styleID, err := sr.CreateStyle(ctx, entities.Style{UserID: userID, Name: "dark", LogoID: logoID})
+	assert.NoError(t, err)
+	codeID, err := codes.Create(ctx, entities.Code{UserID: userID, SrcURL: "url", StyleID: styleID})
+	assert.NoError(t, err)
+	assert.NoError(t, users.SetDefaultStyle(ctx, userID, styleID))
+
+	logos, err := sr.ListLogos(ctx, userID)
+	if assert.NoError(t, err) && assert.Len(t, logos, 1) {
+		assert.Nil(t, logos[0].Data)
+	}
+
+	// logo can't be removed while style uses it
+	assert.ErrorIs(t, sr.DeleteLogo(ctx, logoID), domain.ErrLogoInUse)
+
+	assert.NoError(t, sr.DeleteStyle(ctx, styleID))
+	assert.ErrorIs(t, sr.DeleteStyle(ctx, styleID), domain.ErrStyleNotFound)
+	code, err := codes.Get(ctx, codeID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(0), code.StyleID)
+	}
+	user, err := users.Get(ctx, userID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(0), user.DefaultStyleID)
+	}
+
+	assert.NoError(t, sr.DeleteLogo(ctx, logoID))
+	_, err = sr.GetLogo(ctx, logoID)
+	assert.ErrorIs(t, err, domain.ErrLogoNotFound)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
 	"github.com/pkg/errors"
+	"strings"
 	"sync"
 )
 
@@ -85,3 +86,78 @@ func (u *UserRepository) GetByUsernameAndPass(ctx context.Context, user entities
 
 	return v, nil
 }
+
+// GetByDomain returns User by custom domain
+func (u *UserRepository) GetByDomain(ctx context.Context, domainName string) (entities.User, error) {
+	u.rmu.RLock()
+	defer u.rmu.RUnlock()
+	if domainName == "" {
+		return entities.User{}, domain.ErrUserNotFound
+	}
+	for _, v := range u.users {
+		if strings.EqualFold(v.Domain, domainName) {
+			v.Password = ""
+			return v, nil
+		}
+	}
+	return entities.User{}, domain.ErrUserNotFound
+}
+
+// SetDomain sets or removes custom domain of User
+func (u *UserRepository) SetDomain(ctx context.Context, id uint64, domainName string) error {
+	u.rmu.Lock()
+	defer u.rmu.Unlock()
+	user, ok := u.users[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	if domainName != "" {
+		for _, v := range u.users {
+			if v.ID != id && strings.EqualFold(v.Domain, domainName) {
+				return domain.ErrDomainAlreadyExists
+			}
+		}
+	}
+	user.Domain = domainName
+	u.users[id] = user
+	return nil
+}
+
+// SetPendingDomain sets or removes domain of User which waits for verification
+func (u *UserRepository) SetPendingDomain(ctx context.Context, id uint64, domainName string, domainToken string) error {
+	u.rmu.Lock()
+	defer u.rmu.Unlock()
+	user, ok := u.users[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	user.PendingDomain = domainName
+	user.DomainToken = domainToken
+	u.users[id] = user
+	return nil
+}
+
+// SetDefaultStyle sets or removes (styleID 0) default style of User
+func (u *UserRepository) SetDefaultStyle(ctx context.Context, id uint64, styleID uint64) error {
+	u.rmu.Lock()
+	defer u.rmu.Unlock()
+	user, ok := u.users[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	user.DefaultStyleID = styleID
+	u.users[id] = user
+	return nil
+}
+
+// detachStyle removes default style from users
+func (u *UserRepository) detachStyle(styleID uint64) {
+	u.rmu.Lock()
+	defer u.rmu.Unlock()
+	for id, user := range u.users {
+		if user.DefaultStyleID == styleID {
+			user.DefaultStyleID = 0
+			u.users[id] = user
+		}
+	}
+}
@@ -2,6 +2,7 @@ package inmemory
 
 import (
 	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
 	"github.com/stretchr/testify/assert"
 	"strconv"
@@ -169,3 +170,84 @@ func TestUserRepository_GetByUsernameAndPass(t *testing.T) {
 		})
 	}
 }
+
+func TestUserRepository_SetDomain(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  uint64
+		domain  string
+		wantErr error
+	}{
+		{
+			name:    "first user",
+			userID:  1,
+			domain:  "qr.example.com",
+			wantErr: nil,
+		},
+		{
+			name:    "taken domain",
+			userID:  2,
+			domain:  "QR.example.com",
+			wantErr: domain.ErrDomainAlreadyExists,
+		},
+		{
+			name:    "same domain again",
+			userID:  1,
+			domain:  "qr.example.com",
+			wantErr: nil,
+		},
+		{
+			name:    "unknown user",
+			userID:  3,
+			domain:  "qr2.example.com",
+			wantErr: domain.ErrUserNotFound,
+		},
+	}
+
+	ur := NewUserRepository()
+	ctx := context.TODO()
+	for _, username := range []string{"1", "2"} {
+		_, err := ur.Create(ctx, entities.User{Username: username, Password: username})
+		assert.NoError(t, err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ur.SetDomain(ctx, tt.userID, tt.domain)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				user, err := ur.GetByDomain(ctx, "QR.EXAMPLE.COM")
+				if assert.NoError(t, err) {
+					assert.Equal(t, tt.userID, user.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestUserRepository_SetPendingDomain(t *testing.T) {
+	ur := NewUserRepository()
+	ctx := context.TODO()
+	for _, username := range []string{"1", "2"} {
+		_, err := ur.Create(ctx, entities.User{Username: username, Password: username})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, ur.SetPendingDomain(ctx, 1, "qr.example.com", "token1"))
+	assert.NoError(t, ur.SetPendingDomain(ctx, 2, "qr.example.com", "token2"), "pending domains aren't unique")
+	user, err := ur.Get(ctx, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "qr.example.com", user.PendingDomain)
+		assert.Equal(t, "token1", user.DomainToken)
+	}
+	_, err = ur.GetByDomain(ctx, "qr.example.com")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound, "pending domain isn't resolved")
+
+	assert.NoError(t, ur.SetPendingDomain(ctx, 1, "", ""))
+	user, _ = ur.Get(ctx, 1)
+	assert.Empty(t, user.PendingDomain)
+	assert.ErrorIs(t, ur.SetPendingDomain(ctx, 3, "qr.example.com", "token3"), domain.ErrUserNotFound)
+}
@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/pkg/errors"
+)
+
+// campaignSelect selects campaigns with stats of their codes
+const campaignSelect = `SELECT c.id, c.user_id, c.name, c.description, c.created_at, c.updated_at,
+	COUNT(codes.id), COALESCE(SUM(codes.scan_count), 0)
+	FROM campaigns c LEFT JOIN codes ON codes.campaign_id=c.id AND codes.deleted_at IS NULL`
+
+// CampaignRepository is SQL implementation
+type CampaignRepository struct {
+	db *sql.DB
+}
+
+var _ domain.CampaignRepository = (*CampaignRepository)(nil)
+
+// NewCampaignRepository creates new CampaignRepository
+func NewCampaignRepository(db *sql.DB) CampaignRepository {
+	return CampaignRepository{
+		db: db,
+	}
+}
+
+// List returns campaigns of user
+func (c CampaignRepository) List(ctx context.Context, userID uint64) ([]entities.Campaign, error) {
+	rows, err := c.db.QueryContext(ctx, campaignSelect+` WHERE c.user_id=? GROUP BY c.id ORDER BY c.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	campaigns := make([]entities.Campaign, 0)
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, rows.Err()
+}
+
+// Get returns campaign by id
+func (c CampaignRepository) Get(ctx context.Context, id uint64) (entities.Campaign, error) {
+	campaign, err := scanCampaign(c.db.QueryRowContext(ctx, campaignSelect+` WHERE c.id=? GROUP BY c.id`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return campaign, domain.ErrCampaignNotFound
+		}
+		return campaign, err
+	}
+	return campaign, nil
+}
+
+// Create creates new campaign
+func (c CampaignRepository) Create(ctx context.Context, campaign entities.Campaign) (uint64, error) {
+	result, err := c.db.ExecContext(ctx,
+		`INSERT INTO campaigns(user_id, name, description) VALUES (?, ?, ?)`,
+		campaign.UserID,
+		campaign.Name,
+		campaign.Description)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+// Update updates name and description of campaign
+func (c CampaignRepository) Update(ctx context.Context, campaign entities.Campaign) error {
+	result, err := c.db.ExecContext(ctx,
+		`UPDATE campaigns SET name=?, description=? WHERE id=?`,
+		campaign.Name,
+		campaign.Description,
+		campaign.ID)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return domain.ErrCampaignNotFound
+	}
+	return nil
+}
+
+// Delete removes campaign and detaches its codes
+func (c CampaignRepository) Delete(ctx context.Context, id uint64) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM campaigns WHERE id=?`, id)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return domain.ErrCampaignNotFound
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE codes SET campaign_id=NULL WHERE campaign_id=?`, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// scanCampaign reads row of campaignSelect
+func scanCampaign(row scanner) (entities.Campaign, error) {
+	var campaign entities.Campaign
+	err := row.Scan(&campaign.ID, &campaign.UserID, &campaign.Name, &campaign.Description,
+		&campaign.CreatedAt, &campaign.UpdatedAt, &campaign.CodeCount, &campaign.ScanCount)
+	if err != nil {
+		return entities.Campaign{}, err
+	}
+	return campaign, nil
+}
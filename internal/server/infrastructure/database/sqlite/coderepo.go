@@ -5,9 +5,18 @@ import (
 	"database/sql"
 	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
+	"strings"
+	"time"
 )
 
+// likeEscaper escapes wildcards of LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// codeColumns are selected by scanCode
+const codeColumns = `id, user_id, type, link, payload, hash, slug, name, description, campaign_id, scan_count, deleted_at, paused, paused_url, style_id, embed_disabled`
+
 // CodeRepository is SQL implementation
 type CodeRepository struct {
 	db *sql.DB
@@ -29,101 +38,174 @@ func (c CodeRepository) List(ctx context.Context, userID uint64, offset int64, l
 
 // ListAll returns all codes
 func (c CodeRepository) ListAll(ctx context.Context, userID uint64) ([]entities.Code, error) {
-	rows, err := c.db.QueryContext(ctx, `SELECT id, link, hash FROM codes WHERE user_id=?`, userID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, domain.ErrCodeNotFound
+	return c.ListByFilter(ctx, userID, domain.CodeFilter{})
+}
+
+// ListByFilter returns codes of user selected by filter
+func (c CodeRepository) ListByFilter(ctx context.Context, userID uint64, filter domain.CodeFilter) ([]entities.Code, error) {
+	query := `SELECT ` + codeColumns + ` FROM codes WHERE user_id=?`
+	if filter.Trashed {
+		query += ` AND deleted_at IS NOT NULL`
+	} else {
+		query += ` AND deleted_at IS NULL`
+	}
+	args := []interface{}{userID}
+	if filter.Query != "" {
+		query += ` AND (name LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\')`
+		pattern := "%" + likeEscaper.Replace(filter.Query) + "%"
+		args = append(args, pattern, pattern)
+	}
+	if filter.CampaignID != 0 {
+		query += ` AND campaign_id=?`
+		args = append(args, filter.CampaignID)
+	}
+	if len(filter.Tags) > 0 {
+		query += ` AND id IN (SELECT ct.code_id FROM code_tags ct JOIN tags t ON t.id=ct.tag_id
+			WHERE t.user_id=? AND t.name IN (?` + strings.Repeat(`, ?`, len(filter.Tags)-1) + `)
+			GROUP BY ct.code_id HAVING COUNT(DISTINCT t.id)=?)`
+		args = append(args, userID)
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
 		}
+		args = append(args, len(filter.Tags))
+	}
+	query += ` ORDER BY id`
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
 		return nil, err
 	}
-	var id uint64
-	var link string
-	var hash string
+	defer rows.Close()
 	codes := make([]entities.Code, 0)
 	for rows.Next() {
-		err = rows.Scan(&id, &link, &hash)
+		code, err := scanCode(rows)
 		if err != nil {
 			return nil, err
 		}
-		codes = append(codes, entities.Code{ID: id, SrcURL: link, Hash: hash, UserID: userID})
+		codes = append(codes, code)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := c.loadUserTags(ctx, userID, codes); err != nil {
+		return nil, err
 	}
 	return codes, nil
 }
 
 // Get returns code by id
 func (c CodeRepository) Get(ctx context.Context, id uint64) (entities.Code, error) {
-	var code entities.Code
-	var link string
-	var hash string
-	var userID uint64
-	err := c.db.QueryRowContext(ctx, `SELECT link, hash, user_id from codes WHERE id=?`, id).
-		Scan(&link, &hash, &userID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return code, domain.ErrCodeNotFound
-		}
-		return code, err
-	}
-	return entities.Code{
-		ID:     id,
-		SrcURL: link,
-		Hash:   hash,
-		UserID: userID,
-	}, nil
+	return c.getOne(ctx, `SELECT `+codeColumns+` FROM codes WHERE id=?`, id)
 }
 
 // GetByHash returns code by hash
 func (c CodeRepository) GetByHash(ctx context.Context, hash string) (entities.Code, error) {
-	var code entities.Code
-	var id uint64
-	var userID uint64
-	var link string
-	err := c.db.QueryRowContext(ctx, `SELECT id, link, user_id from codes WHERE hash=?`, hash).
-		Scan(&id, &link, &userID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return code, domain.ErrCodeNotFound
-		}
-		return code, err
-	}
-	return entities.Code{ID: id, SrcURL: link, Hash: hash, UserID: userID}, nil
+	return c.getOne(ctx, `SELECT `+codeColumns+` FROM codes WHERE hash=?`, hash)
+}
+
+// GetBySlug returns code by slug (case-insensitive)
+func (c CodeRepository) GetBySlug(ctx context.Context, slug string) (entities.Code, error) {
+	return c.getOne(ctx, `SELECT `+codeColumns+` FROM codes WHERE slug=? COLLATE NOCASE`, slug)
 }
 
 // Create creates new code
 func (c CodeRepository) Create(ctx context.Context, code entities.Code) (uint64, error) {
-	result, err := c.db.ExecContext(ctx, `INSERT INTO codes(link, user_id) VALUES (?, ?)`, code.SrcURL, code.UserID)
+	tx, err := c.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
-	// TODO maybe replace with getting user by username and pass
-	id, err := result.LastInsertId()
+	defer tx.Rollback()
+
+	id, err := insertCode(ctx, tx, code)
 	if err != nil {
 		return 0, err
 	}
-	return uint64(id), nil
+	err = setTags(ctx, tx, code.UserID, id, code.Tags)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// CreateBatch creates all codes in single transaction
+func (c CodeRepository) CreateBatch(ctx context.Context, codes []entities.Code, hasher func(uint64) (string, error)) ([]uint64, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]uint64, 0, len(codes))
+	for _, code := range codes {
+		id, err := insertCode(ctx, tx, code)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := hasher(id)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE codes SET hash=? WHERE id=?`, hash, id)
+		if err != nil {
+			return nil, err
+		}
+		err = setTags(ctx, tx, code.UserID, id, code.Tags)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, tx.Commit()
 }
 
 // Update updates existing code
 func (c CodeRepository) Update(ctx context.Context, code entities.Code) error {
-	result, err := c.db.ExecContext(ctx,
-		`UPDATE codes SET link=?, hash=?, user_id=? WHERE id=?`,
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE codes SET type=?, link=?, payload=?, hash=?, slug=?, name=?, description=?, campaign_id=?, paused=?, paused_url=?, style_id=?,
+			embed_disabled=?, user_id=? WHERE id=?`,
+		codeTypeOrDefault(code.Type),
 		code.SrcURL,
+		code.Payload,
 		code.Hash,
+		nullString(code.Slug),
+		code.Name,
+		code.Description,
+		nullID(code.CampaignID),
+		code.Paused,
+		code.PausedURL,
+		nullID(code.StyleID),
+		code.EmbedDisabled,
 		code.UserID,
 		code.ID)
 	if err != nil {
-		return err
+		return convertError(err)
 	}
 	n, _ := result.RowsAffected()
 	if n == 0 {
 		return domain.ErrCodeNotFound
 	}
-	return nil
+	err = setTags(ctx, tx, code.UserID, code.ID, code.Tags)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // Delete removes code
 func (c CodeRepository) Delete(ctx context.Context, id uint64) error {
-	result, err := c.db.ExecContext(ctx,
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
 		`DELETE FROM codes WHERE id=?`,
 		id)
 	if err != nil {
@@ -133,5 +215,275 @@ func (c CodeRepository) Delete(ctx context.Context, id uint64) error {
 	if n == 0 {
 		return domain.ErrCodeNotFound
 	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM code_tags WHERE code_id=?`, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Trash marks code as deleted
+func (c CodeRepository) Trash(ctx context.Context, id uint64, deletedAt time.Time) error {
+	result, err := c.db.ExecContext(ctx, `UPDATE codes SET deleted_at=? WHERE id=? AND deleted_at IS NULL`, deletedAt.UTC(), id)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return domain.ErrCodeNotFound
+	}
 	return nil
 }
+
+// Restore returns trashed code back
+func (c CodeRepository) Restore(ctx context.Context, id uint64) error {
+	result, err := c.db.ExecContext(ctx, `UPDATE codes SET deleted_at=NULL WHERE id=? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return domain.ErrCodeNotFound
+	}
+	return nil
+}
+
+// Purge removes codes trashed before moment in single transaction
+func (c CodeRepository) Purge(ctx context.Context, before time.Time) ([]entities.Code, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+codeColumns+` FROM codes WHERE deleted_at < ?`, before.UTC())
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]entities.Code, 0)
+	for rows.Next() {
+		code, err := scanCode(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		_, err = tx.ExecContext(ctx, `DELETE FROM code_tags WHERE code_id=?`, code.ID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM codes WHERE id=?`, code.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, tx.Commit()
+}
+
+// AddScans adds scans to counters of codes in single transaction. Codes purged meanwhile are skipped
+func (c CodeRepository) AddScans(ctx context.Context, scans map[uint64]uint64) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE codes SET scan_count=scan_count+? WHERE id=?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for id, n := range scans {
+		_, err = stmt.ExecContext(ctx, n, id)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetCampaign moves codes to campaign in single transaction
+func (c CodeRepository) SetCampaign(ctx context.Context, campaignID uint64, codeIDs []uint64) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range codeIDs {
+		result, err := tx.ExecContext(ctx, `UPDATE codes SET campaign_id=? WHERE id=?`, nullID(campaignID), id)
+		if err != nil {
+			return err
+		}
+		n, _ := result.RowsAffected()
+		if n == 0 {
+			return domain.ErrCodeNotFound
+		}
+	}
+	return tx.Commit()
+}
+
+// getOne returns single code with its tags
+func (c CodeRepository) getOne(ctx context.Context, query string, args ...interface{}) (entities.Code, error) {
+	code, err := scanCode(c.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return code, domain.ErrCodeNotFound
+		}
+		return code, err
+	}
+	rows, err := c.db.QueryContext(ctx,
+		`SELECT t.name FROM code_tags ct JOIN tags t ON t.id=ct.tag_id WHERE ct.code_id=? ORDER BY t.name`,
+		code.ID)
+	if err != nil {
+		return code, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return code, err
+		}
+		code.Tags = append(code.Tags, tag)
+	}
+	return code, rows.Err()
+}
+
+// loadUserTags sets tags to codes of user
+func (c CodeRepository) loadUserTags(ctx context.Context, userID uint64, codes []entities.Code) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	byID := make(map[uint64]int, len(codes))
+	for i, code := range codes {
+		byID[code.ID] = i
+	}
+	rows, err := c.db.QueryContext(ctx,
+		`SELECT ct.code_id, t.name FROM code_tags ct JOIN tags t ON t.id=ct.tag_id WHERE t.user_id=? ORDER BY t.name`,
+		userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var codeID uint64
+		var tag string
+		if err := rows.Scan(&codeID, &tag); err != nil {
+			return err
+		}
+		if i, ok := byID[codeID]; ok {
+			codes[i].Tags = append(codes[i].Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+// insertCode inserts code without hash and tags
+func insertCode(ctx context.Context, tx *sql.Tx, code entities.Code) (uint64, error) {
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO codes(type, link, payload, slug, name, description, campaign_id, paused, paused_url, style_id, embed_disabled, user_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		codeTypeOrDefault(code.Type),
+		code.SrcURL,
+		code.Payload,
+		nullString(code.Slug),
+		code.Name,
+		code.Description,
+		nullID(code.CampaignID),
+		code.Paused,
+		code.PausedURL,
+		nullID(code.StyleID),
+		code.EmbedDisabled,
+		code.UserID)
+	if err != nil {
+		return 0, convertError(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+// setTags replaces tags of code. Unknown tags are created for user
+func setTags(ctx context.Context, tx *sql.Tx, userID uint64, codeID uint64, tags []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM code_tags WHERE code_id=?`, codeID)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		_, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO tags(user_id, name) VALUES (?, ?)`, userID, tag)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO code_tags(code_id, tag_id) SELECT ?, id FROM tags WHERE user_id=? AND name=?`,
+			codeID, userID, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanner is implemented by sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCode reads row of codeColumns
+func scanCode(row scanner) (entities.Code, error) {
+	var code entities.Code
+	var codeType string
+	var hash sql.NullString
+	var slug sql.NullString
+	var campaignID sql.NullInt64
+	var deletedAt sql.NullTime
+	var styleID sql.NullInt64
+	err := row.Scan(&code.ID, &code.UserID, &codeType, &code.SrcURL, &code.Payload, &hash, &slug, &code.Name, &code.Description,
+		&campaignID, &code.ScanCount, &deletedAt, &code.Paused, &code.PausedURL, &styleID, &code.EmbedDisabled)
+	if err != nil {
+		return entities.Code{}, err
+	}
+	code.DeletedAt = deletedAt.Time
+	code.CampaignID = uint64(campaignID.Int64)
+	code.StyleID = uint64(styleID.Int64)
+	code.Type = entities.CodeType(codeType)
+	code.Hash = hash.String
+	code.Slug = slug.String
+	return code, nil
+}
+
+func codeTypeOrDefault(t entities.CodeType) entities.CodeType {
+	if t == "" {
+		return entities.CodeTypeURL
+	}
+	return t
+}
+
+// nullString stores empty strings as NULL, so they don't break unique indexes
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullID stores zero id as NULL
+func nullID(id uint64) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// convertError converts constraint violations to domain errors
+func convertError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.Contains(sqliteErr.Error(), "slug") {
+		return domain.ErrSlugAlreadyExists
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pkg/errors"
+)
+
+// SchemaVersion returns version of last applied goose migration.
+// Unlike goose it doesn't create version table, so it is safe for readiness checks.
+func SchemaVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC`)
+	if err != nil {
+		return 0, errors.Wrap(err, "SchemaVersion: ")
+	}
+	defer rows.Close()
+
+	// rolled back migrations have later row with is_applied false
+	rolledBack := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		var applied bool
+		err = rows.Scan(&version, &applied)
+		if err != nil {
+			return 0, errors.Wrap(err, "SchemaVersion: Scan: ")
+		}
+		if !applied {
+			rolledBack[version] = true
+			continue
+		}
+		if !rolledBack[version] {
+			return version, nil
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return 0, errors.Wrap(err, "SchemaVersion: ")
+	}
+	return 0, sql.ErrNoRows
+}
@@ -0,0 +1,223 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/pkg/errors"
+)
+
+// styleColumns are selected by scanStyle
+const styleColumns = `id, user_id, name, foreground, background, shape, quiet_zone, error_correction, logo_id, created_at, updated_at`
+
+// StyleRepository is SQL implementation
+type StyleRepository struct {
+	db *sql.DB
+}
+
+var _ domain.StyleRepository = (*StyleRepository)(nil)
+
+// NewStyleRepository creates new StyleRepository
+func NewStyleRepository(db *sql.DB) StyleRepository {
+	return StyleRepository{
+		db: db,
+	}
+}
+
+// ListStyles returns styles of user
+func (s StyleRepository) ListStyles(ctx context.Context, userID uint64) ([]entities.Style, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+styleColumns+` FROM styles WHERE user_id=? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	styles := make([]entities.Style, 0)
+	for rows.Next() {
+		style, err := scanStyle(rows)
+		if err != nil {
+			return nil, err
+		}
+		styles = append(styles, style)
+	}
+	return styles, rows.Err()
+}
+
+// GetStyle returns style by id
+func (s StyleRepository) GetStyle(ctx context.Context, id uint64) (entities.Style, error) {
+	style, err := scanStyle(s.db.QueryRowContext(ctx, `SELECT `+styleColumns+` FROM styles WHERE id=?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return style, domain.ErrStyleNotFound
+		}
+		return style, err
+	}
+	return style, nil
+}
+
+// CreateStyle creates new style
+func (s StyleRepository) CreateStyle(ctx context.Context, style entities.Style) (uint64, error) {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO styles(user_id, name, foreground, background, shape, quiet_zone, error_correction, logo_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		style.UserID,
+		style.Name,
+		style.Foreground,
+		style.Background,
+		style.Shape,
+		style.QuietZone,
+		style.ErrorCorrection,
+		nullID(style.LogoID))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+// UpdateStyle updates existing style
+func (s StyleRepository) UpdateStyle(ctx context.Context, style entities.Style) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE styles SET name=?, foreground=?, background=?, shape=?, quiet_zone=?, error_correction=?, logo_id=?
+			WHERE id=?`,
+		style.Name,
+		style.Foreground,
+		style.Background,
+		style.Shape,
+		style.QuietZone,
+		style.ErrorCorrection,
+		nullID(style.LogoID),
+		style.ID)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return domain.ErrStyleNotFound
+	}
+	return nil
+}
+
+// DeleteStyle removes style and detaches it from codes and users
+func (s StyleRepository) DeleteStyle(ctx context.Context, id uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM styles WHERE id=?`, id)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return domain.ErrStyleNotFound
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE codes SET style_id=NULL WHERE style_id=?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE users SET default_style_id=NULL WHERE default_style_id=?`, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListLogos returns logos of user without data
+func (s StyleRepository) ListLogos(ctx context.Context, userID uint64) ([]entities.Logo, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, user_id, name, width, height, created_at FROM logos WHERE user_id=? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	logos := make([]entities.Logo, 0)
+	for rows.Next() {
+		var logo entities.Logo
+		err := rows.Scan(&logo.ID, &logo.UserID, &logo.Name, &logo.Width, &logo.Height, &logo.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		logos = append(logos, logo)
+	}
+	return logos, rows.Err()
+}
+
+// GetLogo returns logo with data
+func (s StyleRepository) GetLogo(ctx context.Context, id uint64) (entities.Logo, error) {
+	var logo entities.Logo
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, name, data, width, height, created_at FROM logos WHERE id=?`, id).
+		Scan(&logo.ID, &logo.UserID, &logo.Name, &logo.Data, &logo.Width, &logo.Height, &logo.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Logo{}, domain.ErrLogoNotFound
+		}
+		return entities.Logo{}, err
+	}
+	return logo, nil
+}
+
+// CreateLogo creates new logo
+func (s StyleRepository) CreateLogo(ctx context.Context, logo entities.Logo) (uint64, error) {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO logos(user_id, name, data, width, height) VALUES (?, ?, ?, ?, ?)`,
+		logo.UserID,
+		logo.Name,
+		logo.Data,
+		logo.Width,
+		logo.Height)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+// DeleteLogo removes logo if it is not used by styles
+func (s StyleRepository) DeleteLogo(ctx context.Context, id uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var used bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM styles WHERE logo_id=?)`, id).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used {
+		return domain.ErrLogoInUse
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM logos WHERE id=?`, id)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return domain.ErrLogoNotFound
+	}
+	return tx.Commit()
+}
+
+// scanStyle reads row of styleColumns
+func scanStyle(row scanner) (entities.Style, error) {
+	var style entities.Style
+	var logoID sql.NullInt64
+	err := row.Scan(&style.ID, &style.UserID, &style.Name, &style.Foreground, &style.Background, &style.Shape,
+		&style.QuietZone, &style.ErrorCorrection, &logoID, &style.CreatedAt, &style.UpdatedAt)
+	if err != nil {
+		return entities.Style{}, err
+	}
+	style.LogoID = uint64(logoID.Int64)
+	return style, nil
+}
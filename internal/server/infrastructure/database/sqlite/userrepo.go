@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
 )
 
@@ -27,9 +28,11 @@ func NewUserRepository(db *sql.DB) UserRepository {
 func (u UserRepository) Get(ctx context.Context, id uint64) (entities.User, error) {
 	var user entities.User
 	var username string
-	var email string
-	err := u.db.QueryRowContext(ctx, `SELECT username, email from users WHERE id=?`, id).
-		Scan(&username, &email)
+	var email sql.NullString
+	var domainName, pendingDomain, domainToken sql.NullString
+	var defaultStyleID sql.NullInt64
+	err := u.db.QueryRowContext(ctx, `SELECT username, email, domain, pending_domain, domain_token, default_style_id from users WHERE id=?`, id).
+		Scan(&username, &email, &domainName, &pendingDomain, &domainToken, &defaultStyleID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return user, domain.ErrUserNotFound
@@ -37,9 +40,13 @@ func (u UserRepository) Get(ctx context.Context, id uint64) (entities.User, erro
 		return user, err
 	}
 	return entities.User{
-		ID:       id,
-		Username: username,
-		Email:    email,
+		ID:             id,
+		Username:       username,
+		Email:          email.String,
+		Domain:         domainName.String,
+		PendingDomain:  pendingDomain.String,
+		DomainToken:    domainToken.String,
+		DefaultStyleID: uint64(defaultStyleID.Int64),
 	}, nil
 }
 
@@ -74,3 +81,72 @@ func (u UserRepository) GetByUsernameAndPass(ctx context.Context, user entities.
 	}
 	return id, nil
 }
+
+// GetByDomain returns user by custom domain (case-insensitive)
+func (u UserRepository) GetByDomain(ctx context.Context, domainName string) (entities.User, error) {
+	var id uint64
+	var username string
+	var email sql.NullString
+	var storedDomain string
+	var defaultStyleID sql.NullInt64
+	err := u.db.QueryRowContext(ctx,
+		`SELECT id, username, email, domain, default_style_id from users WHERE domain=? COLLATE NOCASE`, domainName).
+		Scan(&id, &username, &email, &storedDomain, &defaultStyleID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.User{}, domain.ErrUserNotFound
+		}
+		return entities.User{}, err
+	}
+	return entities.User{
+		ID:             id,
+		Username:       username,
+		Email:          email.String,
+		Domain:         storedDomain,
+		DefaultStyleID: uint64(defaultStyleID.Int64),
+	}, nil
+}
+
+// SetDomain sets or removes custom domain of user
+func (u UserRepository) SetDomain(ctx context.Context, id uint64, domainName string) error {
+	result, err := u.db.ExecContext(ctx, `UPDATE users SET domain=? WHERE id=?`, nullString(domainName), id)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return domain.ErrDomainAlreadyExists
+		}
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// SetPendingDomain sets or removes domain which waits for verification
+func (u UserRepository) SetPendingDomain(ctx context.Context, id uint64, domainName string, domainToken string) error {
+	result, err := u.db.ExecContext(ctx, `UPDATE users SET pending_domain=?, domain_token=? WHERE id=?`,
+		nullString(domainName), nullString(domainToken), id)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// SetDefaultStyle sets or removes default style of user
+func (u UserRepository) SetDefaultStyle(ctx context.Context, id uint64, styleID uint64) error {
+	result, err := u.db.ExecContext(ctx, `UPDATE users SET default_style_id=? WHERE id=?`, nullID(styleID), id)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
@@ -9,7 +9,10 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/go-resty/resty/v2"
 	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	"html"
 	"io"
 	"net/http"
@@ -20,6 +23,9 @@ import (
 
 var notNecScript = errors.New("script is not necessary")
 
+// ErrWrongLink is returned for links which are not instagram posts
+var ErrWrongLink = errors.New("link has wrong format")
+
 //const instagramURL = "https://www.instagram.com/p/%v/embed/captioned/"
 const instagramURL = "https://www.instagram.com/p/%v/embed/"
 
@@ -45,6 +51,9 @@ func NewPhotoSource() Source {
 
 // GetPhotos returns links to instagram photos
 func (s Source) GetPhotos(ctx context.Context, link string) (links []string, err error) {
+	ctx, span := tracing.Start(ctx, "PhotoSource.GetPhotos")
+	defer tracing.End(span, &err)
+
 	key, err := s.validateKey(link)
 	if err != nil {
 		return nil, errors.Wrap(err, "link validation: ")
@@ -61,6 +70,7 @@ func (s Source) GetPhotos(ctx context.Context, link string) (links []string, err
 	if err != nil {
 		return nil, errors.Wrap(err, "http request: ")
 	}
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(res.StatusCode())...)
 	if res.StatusCode() != http.StatusOK {
 		return nil, errors.New("http request status not OK")
 	}
@@ -69,13 +79,15 @@ func (s Source) GetPhotos(ctx context.Context, link string) (links []string, err
 
 	err = parseBodyByScript(&embedRes, bytes.NewReader(res.Body()))
 
-	return embedRes.getURLs(), err
+	links = embedRes.getURLs()
+	span.SetAttributes(attribute.Int("photo.links", len(links)))
+	return links, err
 }
 
 func (s Source) validateKey(link string) (string, error) {
 	keys := s.keyValidator.FindStringSubmatch(link)
 	if len(keys) < 2 {
-		return "", errors.New("link has wrong format")
+		return "", ErrWrongLink
 	}
 	return keys[1], nil
 }
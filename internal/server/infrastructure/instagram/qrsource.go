@@ -2,110 +2,269 @@ package instagram
 
 import (
 	"context"
-	"github.com/go-resty/resty/v2"
 	"github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/token"
 	"github.com/hotafrika/griz-backend/internal/server/domain"
 	"github.com/hotafrika/griz-backend/internal/server/infrastructure/instagram/photo"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/safehttp"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/tracing"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	zlog "github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	"sync"
+	"time"
 )
 
+// ImageHosts are CDN hosts of instagram images
+var ImageHosts = []string{"*.cdninstagram.com", "*.fbcdn.net"}
+
+// NewImageClient creates client of instagram images with default limits
+func NewImageClient() *safehttp.Client {
+	return safehttp.NewClient(safehttp.Config{
+		AllowedHosts: ImageHosts,
+		MaxRedirects: 3,
+		MaxBytes:     10 << 20,
+		DialTimeout:  5 * time.Second,
+	})
+}
+
+// Options limit processing of images of posts
+type Options struct {
+	// Workers limits images processed at once by all requests, 0 doesn't limit them
+	Workers int
+	// RequestWorkers limits images processed at once by one request, 0 processes all images at once
+	RequestWorkers int
+	// ImageTimeout limits download and decoding of one image, 0 doesn't limit it
+	ImageTimeout time.Duration
+	// CheckLink accepts decoded link, e.g. only on own domains and custom domains of users.
+	// Nil accepts any link in format of griz link
+	CheckLink func(ctx context.Context, link string) error
+}
+
+// DefaultOptions process 3 images of post at once
+var DefaultOptions = Options{
+	Workers:        16,
+	RequestWorkers: 3,
+	ImageTimeout:   10 * time.Second,
+}
+
 // QRSource is for getting QR codes from instagram post
 type QRSource struct {
 	decoder     domain.QRDecoder
 	photoSource domain.PhotoSourcer
 	logger      *zerolog.Logger
-	client      *resty.Client
+	client      *safehttp.Client
+	opts        Options
+	// workers is semaphore of images processed by all requests
+	workers chan struct{}
 }
 
 // NewQRSource ...
 func NewQRSource() *QRSource {
 	logger := zlog.Level(zerolog.Disabled)
-	return &QRSource{
-		photoSource: photo.NewPhotoSource(),
-		logger:      &logger,
-		client:      resty.New(),
-		decoder:     qrdecoder.Makiuchi{},
-	}
+	return NewCustomQRSource(photo.NewPhotoSource(), qrdecoder.Makiuchi{}, NewImageClient(), DefaultOptions, &logger)
 }
 
 // NewQRSourceWithLogger ...
 func NewQRSourceWithLogger(logger *zerolog.Logger) *QRSource {
+	return NewCustomQRSource(photo.NewPhotoSource(), qrdecoder.Makiuchi{}, NewImageClient(), DefaultOptions, logger)
+}
+
+// NewCustomQRSource creates QRSource with photo source, decoder, client of image downloads and limits of processing
+func NewCustomQRSource(photoSource domain.PhotoSourcer, decoder domain.QRDecoder, client *safehttp.Client, opts Options, logger *zerolog.Logger) *QRSource {
+	var workers chan struct{}
+	if opts.Workers > 0 {
+		workers = make(chan struct{}, opts.Workers)
+	}
 	return &QRSource{
-		photoSource: photo.NewPhotoSource(),
+		photoSource: photoSource,
 		logger:      logger,
-		client:      resty.New(),
-		decoder:     qrdecoder.Makiuchi{},
+		client:      client,
+		decoder:     decoder,
+		opts:        opts,
+		workers:     workers,
 	}
 }
 
-// GetFirstQR returns parsed data from first found code.
-// It could be any kind of data. For our case we need to validate it.
+// GetFirstQR returns griz link of first image of post which contains it.
+// Images are processed in order of post by limited number of workers, their spans are children of GetFirstQR span.
+// When image with griz link is found, images after it are cancelled. Images before it are still awaited,
+// so first image of carousel is preferred.
 func (qs QRSource) GetFirstQR(ctx context.Context, link string) (b []byte, err error) {
+	ctx, span := tracing.Start(ctx, "QRSource.GetFirstQR")
+	defer tracing.End(span, &err)
+
 	links, err := qs.photoSource.GetPhotos(ctx, link)
 	if err != nil {
 		return nil, errors.Wrap(err, "GetPhotos: ")
 	}
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	span.SetAttributes(attribute.Int("qrsource.images", len(links)))
 
-	c := make(chan []byte)
-	var wg sync.WaitGroup
+	index, res := firstResult(ctx, len(links), qs.opts.RequestWorkers, qs.workers, func(ctx context.Context, i int) ([]byte, error) {
+		if qs.opts.ImageTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, qs.opts.ImageTimeout)
+			defer cancel()
+		}
+		return qs.processImage(ctx, i, links[i])
+	})
+	if index < 0 {
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(ctx.Err(), "unable to find QR")
+		}
+		return nil, errors.New("unable to find QR")
+	}
+	span.SetAttributes(attribute.Int("qrsource.found_index", index))
+	return res, nil
+}
 
-	for _, s := range links {
+// firstResult processes n items in order by limited number of workers and returns first successful result and its index.
+// Slots of shared semaphore are taken for every item. Items after successful one are cancelled or skipped,
+// items before it are awaited. Index is -1 if all items failed.
+func firstResult(ctx context.Context, n, workers int, sem chan struct{}, process func(context.Context, int) ([]byte, error)) (int, []byte) {
+	if workers <= 0 || workers > n {
+		workers = n
+	}
+	f := fanout{best: n, cancels: make(map[int]context.CancelFunc)}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		go func(s string) {
+		go func() {
 			defer wg.Done()
-			b, err := qs.processImage(ctx, s)
-			if err != nil {
-				return
+			for {
+				i, itemCtx, ok := f.start(ctx)
+				if !ok {
+					return
+				}
+				b, err := processWithSlot(itemCtx, i, sem, process)
+				f.finish(i, b, err)
 			}
-			select {
-			case c <- b:
-			case <-ctx.Done():
-				return
-			}
-		}(s)
+		}()
 	}
+	wg.Wait()
 
-	go func() {
-		wg.Wait()
-		close(c)
-	}()
+	if f.best == n {
+		return -1, nil
+	}
+	return f.best, f.res
+}
 
-	res, ok := <-c
-	if !ok {
-		return nil, errors.New("unable to find QR")
+// processWithSlot waits for free slot of semaphore. Nil semaphore doesn't limit processing
+func processWithSlot(ctx context.Context, i int, sem chan struct{}, process func(context.Context, int) ([]byte, error)) ([]byte, error) {
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return process(ctx, i)
+}
+
+// fanout is state of items of one firstResult call
+type fanout struct {
+	mu sync.Mutex
+	// next is index of next item
+	next int
+	// best is index of first successful item, number of items if there isn't any
+	best    int
+	res     []byte
+	cancels map[int]context.CancelFunc
+}
+
+// start takes next item. There is nothing to do after successful item
+func (f *fanout) start(ctx context.Context) (int, context.Context, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.next >= f.best || ctx.Err() != nil {
+		return 0, nil, false
+	}
+	i := f.next
+	f.next++
+	itemCtx, cancel := context.WithCancel(ctx)
+	f.cancels[i] = cancel
+	return i, itemCtx, true
+}
+
+// finish records result of item and cancels items after successful one
+func (f *fanout) finish(i int, b []byte, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancels[i]()
+	delete(f.cancels, i)
+	if err != nil || i >= f.best {
+		return
+	}
+	f.best, f.res = i, b
+	for j, cancel := range f.cancels {
+		if j > i {
+			cancel()
+		}
 	}
-	return res, nil
 }
 
-func (qs QRSource) processImage(ctx context.Context, link string) ([]byte, error) {
+// processImage downloads and decodes image of post. Index is position of image in post
+func (qs QRSource) processImage(ctx context.Context, index int, link string) (_ []byte, err error) {
+	ctx, span := tracing.Start(ctx, "QRSource.processImage")
+	span.SetAttributes(attribute.Int("qrsource.image_index", index))
+	defer tracing.End(span, &err)
+
 	b, err := qs.downloadImage(ctx, link)
 	if err != nil {
+		if ctx.Err() == context.Canceled {
+			// code is found in previous image or scan request is gone
+			qs.log(ctx).Debug().Str("link", link).Msg("image download is cancelled")
+			return nil, err
+		}
 		// TODO log cause here
-		qs.logger.Info().Str("link", link).Err(err).Msg("unable to download image")
+		qs.log(ctx).Info().Str("link", link).Err(err).Msg("unable to download image")
 		return nil, err
 	}
 
-	res, err := qs.decoder.Decode(b)
+	res, err := qs.decoder.Decode(ctx, b)
+	if err != nil {
+		qs.log(ctx).Info().Str("link", link).Err(err).Msg("unable to decode")
+		return nil, err
+	}
+	// other codes of post, e.g. of payments, don't stop search
+	err = qs.checkLink(ctx, string(res))
 	if err != nil {
-		qs.logger.Info().Str("link", link).Err(err).Msg("unable to decode")
+		qs.log(ctx).Info().Str("link", link).Err(err).Msg("code is not griz link")
 		return nil, err
 	}
 
 	return res, nil
 }
 
-func (qs QRSource) downloadImage(ctx context.Context, link string) ([]byte, error) {
-	res, err := qs.client.R().SetContext(ctx).Get(link)
+// checkLink returns error if decoded link isn't griz link
+func (qs QRSource) checkLink(ctx context.Context, link string) error {
+	if qs.opts.CheckLink != nil {
+		return qs.opts.CheckLink(ctx, link)
+	}
+	_, _, err := token.ParseLink(link)
+	return err
+}
+
+func (qs QRSource) downloadImage(ctx context.Context, link string) (_ []byte, err error) {
+	ctx, span := tracing.Start(ctx, "QRSource.downloadImage")
+	defer tracing.End(span, &err)
+
+	b, err := qs.client.FetchImage(ctx, link)
 	if err != nil {
-		return nil, errors.Wrap(err, "HTTP Get link: ")
+		return nil, errors.Wrap(err, "downloadImage: ")
 	}
-	if res.StatusCode() != 200 {
-		return nil, errors.New("unable to download image. Broken link")
+	span.SetAttributes(semconv.HTTPResponseContentLengthKey.Int(len(b)))
+	return b, nil
+}
+
+// log returns logger of scan request, so failures of images carry its request ID
+func (qs QRSource) log(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
 	}
-	return res.Body(), nil
+	return qs.logger
 }
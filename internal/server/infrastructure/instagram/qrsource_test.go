@@ -0,0 +1,192 @@
+package instagram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrdecoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/qrencoder"
+	"github.com/hotafrika/griz-backend/internal/server/app/token"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errNoCode = errors.New("no code")
+
+// concurrency counts items processed at once
+type concurrency struct {
+	current int32
+	max     int32
+}
+
+func (c *concurrency) enter() {
+	n := atomic.AddInt32(&c.current, 1)
+	for {
+		max := atomic.LoadInt32(&c.max)
+		if n <= max || atomic.CompareAndSwapInt32(&c.max, max, n) {
+			return
+		}
+	}
+}
+
+func (c *concurrency) leave() {
+	atomic.AddInt32(&c.current, -1)
+}
+
+func TestFirstResult_PrefersFirstImage(t *testing.T) {
+	index, res := firstResult(context.Background(), 3, 3, nil, func(ctx context.Context, i int) ([]byte, error) {
+		if i == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return []byte{byte(i)}, nil
+	})
+	assert.Equal(t, 0, index)
+	assert.Equal(t, []byte{0}, res)
+}
+
+func TestFirstResult_CancelsNextImages(t *testing.T) {
+	var cancelled int32
+	start := time.Now()
+	index, res := firstResult(context.Background(), 10, 3, nil, func(ctx context.Context, i int) ([]byte, error) {
+		if i == 0 {
+			time.Sleep(10 * time.Millisecond)
+			return []byte("found"), nil
+		}
+		select {
+		case <-ctx.Done():
+			atomic.AddInt32(&cancelled, 1)
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil, errNoCode
+		}
+	})
+	assert.Equal(t, 0, index)
+	assert.Equal(t, []byte("found"), res)
+	assert.Equal(t, int32(2), cancelled, "images being processed are cancelled, others are skipped")
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestFirstResult_SkipsNextImages(t *testing.T) {
+	var mu sync.Mutex
+	var processed []int
+	index, _ := firstResult(context.Background(), 10, 1, nil, func(ctx context.Context, i int) ([]byte, error) {
+		mu.Lock()
+		processed = append(processed, i)
+		mu.Unlock()
+		if i == 2 {
+			return []byte("found"), nil
+		}
+		return nil, errNoCode
+	})
+	assert.Equal(t, 2, index)
+	assert.Equal(t, []int{0, 1, 2}, processed)
+}
+
+func TestFirstResult_Workers(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		sem     chan struct{}
+		want    int32
+	}{
+		{"request workers", 3, nil, 3},
+		{"global workers", 5, make(chan struct{}, 2), 2},
+		{"unlimited", 0, nil, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c concurrency
+			index, res := firstResult(context.Background(), 10, tt.workers, tt.sem, func(ctx context.Context, i int) ([]byte, error) {
+				c.enter()
+				defer c.leave()
+				time.Sleep(20 * time.Millisecond)
+				return nil, errNoCode
+			})
+			assert.Equal(t, -1, index)
+			assert.Nil(t, res)
+			assert.Equal(t, tt.want, c.max)
+			if tt.sem != nil {
+				assert.Len(t, tt.sem, 0, "slots are released")
+			}
+		})
+	}
+}
+
+func TestFirstResult_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls int32
+	index, _ := firstResult(ctx, 5, 2, make(chan struct{}, 1), func(ctx context.Context, i int) ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		return []byte("found"), nil
+	})
+	assert.Equal(t, -1, index)
+	assert.Equal(t, int32(0), calls)
+}
+
+// photos returns fixed images of every post
+type photos []string
+
+func (p photos) GetPhotos(context.Context, string) ([]string, error) {
+	return p, nil
+}
+
+// imageServer serves images by URL without network
+type imageServer map[string][]byte
+
+func (s imageServer) RoundTrip(r *http.Request) (*http.Response, error) {
+	b, ok := s[r.URL.String()]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewReader(nil)), Request: r}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(b)), ContentLength: int64(len(b)), Request: r}, nil
+}
+
+func TestQRSource_GetFirstQR_SkipsOtherLinks(t *testing.T) {
+	encoder := qrencoder.DefaultYeqown(qrencoder.WithPNG())
+	images := imageServer{}
+	links := []string{"https://paypal.me/griz", "https://example.com/my-slug", token.DefaultBaseURL + "/my-slug"}
+	var post photos
+	for i, link := range links {
+		b, err := encoder.Encode([]byte(link))
+		assert.NoError(t, err)
+		u := fmt.Sprintf("https://scontent.cdninstagram.com/%d.png", i)
+		images[u] = b
+		post = append(post, u)
+	}
+	client := NewImageClient()
+	client.WrapTransport(func(http.RoundTripper) http.RoundTripper { return images })
+	linker, err := token.NewLinker(token.DefaultBaseURL)
+	assert.NoError(t, err)
+	logger := zerolog.Nop()
+
+	// images are processed one by one, so links are checked in order
+	var checked []string
+	opts := Options{RequestWorkers: 1, CheckLink: func(ctx context.Context, link string) error {
+		checked = append(checked, link)
+		host, _, err := token.ParseLink(link)
+		if err != nil {
+			return err
+		}
+		if !linker.IsOwnDomain(host) {
+			return token.ErrNotGrizLink
+		}
+		return nil
+	}}
+	qs := NewCustomQRSource(post, qrdecoder.Makiuchi{}, client, opts, &logger)
+	b, err := qs.GetFirstQR(context.Background(), "https://www.instagram.com/p/abc/")
+	assert.NoError(t, err)
+	assert.Equal(t, links[2], string(b), "codes of other services are skipped")
+	assert.Equal(t, links, checked)
+
+	qs = NewCustomQRSource(post[:2], qrdecoder.Makiuchi{}, client, opts, &logger)
+	_, err = qs.GetFirstQR(context.Background(), "https://www.instagram.com/p/abc/")
+	assert.Error(t, err, "post without griz link")
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"time"
+)
+
+// Cacher counts hits, misses and errors of wrapped cache by key type
+type Cacher struct {
+	next    domain.Cacher
+	metrics *Metrics
+}
+
+var _ domain.Cacher = Cacher{}
+
+// NewCacher wraps cache
+func (m *Metrics) NewCacher(next domain.Cacher) Cacher {
+	return Cacher{next: next, metrics: m}
+}
+
+// Get ...
+func (c Cacher) Get(ctx context.Context, key fmt.Stringer) (string, error) {
+	value, err := c.next.Get(ctx, key)
+	c.metrics.cacheRequests.WithLabelValues(keyType(key), "get", getResult(err)).Inc()
+	return value, err
+}
+
+// Set ...
+func (c Cacher) Set(ctx context.Context, key fmt.Stringer, value string, ttl time.Duration) error {
+	err := c.next.Set(ctx, key, value, ttl)
+	c.metrics.cacheRequests.WithLabelValues(keyType(key), "set", result(err)).Inc()
+	return err
+}
+
+// Delete ...
+func (c Cacher) Delete(ctx context.Context, key fmt.Stringer) error {
+	err := c.next.Delete(ctx, key)
+	c.metrics.cacheRequests.WithLabelValues(keyType(key), "delete", result(err)).Inc()
+	return err
+}
+
+// ImageCacher counts hits, misses and errors of wrapped image cache by key type
+type ImageCacher struct {
+	next    domain.ImageCacher
+	metrics *Metrics
+}
+
+var _ domain.ImageCacher = ImageCacher{}
+
+// NewImageCacher wraps image cache
+func (m *Metrics) NewImageCacher(next domain.ImageCacher) ImageCacher {
+	return ImageCacher{next: next, metrics: m}
+}
+
+// GetImage ...
+func (c ImageCacher) GetImage(ctx context.Context, key fmt.Stringer) ([]byte, error) {
+	b, err := c.next.GetImage(ctx, key)
+	c.metrics.cacheRequests.WithLabelValues(keyType(key), "get", getResult(err)).Inc()
+	return b, err
+}
+
+// SetImage ...
+func (c ImageCacher) SetImage(ctx context.Context, key fmt.Stringer, b []byte) error {
+	err := c.next.SetImage(ctx, key, b)
+	c.metrics.cacheRequests.WithLabelValues(keyType(key), "set", result(err)).Inc()
+	return err
+}
+
+// DeleteImage ...
+func (c ImageCacher) DeleteImage(ctx context.Context, key fmt.Stringer) error {
+	err := c.next.DeleteImage(ctx, key)
+	c.metrics.cacheRequests.WithLabelValues(keyType(key), "delete", result(err)).Inc()
+	return err
+}
+
+// getResult distinguishes missing key from failure of cache
+func getResult(err error) string {
+	switch {
+	case err == nil:
+		return resultHit
+	case errors.Is(err, domain.ErrCacheNotExist):
+		return resultMiss
+	default:
+		return resultError
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultOK
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+const namespace = "griz"
+
+// Results of operations
+const (
+	resultOK    = "ok"
+	resultError = "error"
+	resultHit   = "hit"
+	resultMiss  = "miss"
+	// resultNotFound is result of repository query for missing entity
+	resultNotFound = "not_found"
+)
+
+// Metrics contains collectors of service. Core types are instrumented by decorators of this package
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	cacheRequests *prometheus.CounterVec
+
+	repoDuration *prometheus.HistogramVec
+
+	imagesFetched  *prometheus.CounterVec
+	decodes        *prometheus.CounterVec
+	upstreamErrors *prometheus.CounterVec
+}
+
+// New creates Metrics with own registry which includes Go runtime and process collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by route pattern and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "requests_total",
+			Help:      "Cache operations by key type, operation and result (hit, miss, ok, error).",
+		}, []string{"key_type", "operation", "result"}),
+		repoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "query_duration_seconds",
+			Help:      "Latency of repository queries by repository, method and result.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"repository", "method", "result"}),
+		imagesFetched: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "qrsource",
+			Name:      "images_fetched_total",
+			Help:      "Images of social posts downloaded for QR search by platform and result.",
+		}, []string{"platform", "result"}),
+		decodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "qrsource",
+			Name:      "decodes_total",
+			Help:      "QR decoding attempts of downloaded images by result.",
+		}, []string{"result"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "qrsource",
+			Name:      "upstream_errors_total",
+			Help:      "Failed requests to social platforms by platform and stage (post, image).",
+		}, []string{"platform", "stage"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.cacheRequests,
+		m.repoDuration,
+		m.imagesFetched,
+		m.decodes,
+		m.upstreamErrors,
+	)
+	return m
+}
+
+// Handler serves metrics in Prometheus format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Registry returns registry of metrics, e.g. to register own collectors
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// notFoundErrors are expected results of queries, not failures
+var notFoundErrors = []error{
+	domain.ErrUserNotFound,
+	domain.ErrCodeNotFound,
+	domain.ErrCampaignNotFound,
+	domain.ErrStyleNotFound,
+	domain.ErrLogoNotFound,
+}
+
+// observeQuery records latency of repository query. It is deferred with pointer to named error
+func (m *Metrics) observeQuery(repository, method string, start time.Time, err *error) {
+	result := resultOK
+	if *err != nil {
+		result = resultError
+		for _, notFound := range notFoundErrors {
+			if errors.Is(*err, notFound) {
+				result = resultNotFound
+				break
+			}
+		}
+	}
+	m.repoDuration.WithLabelValues(repository, method, result).Observe(time.Since(start).Seconds())
+}
+
+// keyType returns name of key type, e.g. AuthToken for cache.AuthToken
+func keyType(key interface{}) string {
+	t := reflect.TypeOf(key)
+	if t == nil {
+		return "unknown"
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
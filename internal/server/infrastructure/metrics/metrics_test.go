@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache/inmemory"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacher(t *testing.T) {
+	m := New()
+	c := m.NewCacher(inmemory.NewCache())
+	ctx := context.Background()
+	key := cache.HealthProbe{Key: "key"}
+
+	_, err := c.Get(ctx, key)
+	assert.ErrorIs(t, err, domain.ErrCacheNotExist)
+	assert.NoError(t, c.Set(ctx, key, "value", time.Minute))
+	_, err = c.Get(ctx, key)
+	assert.NoError(t, err)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheRequests.WithLabelValues("HealthProbe", "get", resultMiss)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheRequests.WithLabelValues("HealthProbe", "get", resultHit)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cacheRequests.WithLabelValues("HealthProbe", "set", resultOK)))
+}
+
+func TestMetrics_observeQuery(t *testing.T) {
+	m := New()
+	query := func(err error) {
+		defer m.observeQuery("code", "Get", time.Now(), &err)
+	}
+	query(nil)
+	query(errors.Wrap(domain.ErrCodeNotFound, "Get: "))
+	query(errors.New("disk I/O error"))
+
+	assert.Equal(t, 3, testutil.CollectAndCount(m.repoDuration))
+	for _, result := range []string{resultOK, resultNotFound, resultError} {
+		assert.True(t, m.repoDuration.DeleteLabelValues("code", "Get", result), result)
+	}
+}
+
+func TestMetrics_HTTPMiddleware(t *testing.T) {
+	m := New()
+	r := chi.NewRouter()
+	r.Use(m.HTTPMiddleware)
+	r.Route("/codes", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+
+	for _, path := range []string{"/codes/1", "/codes/2", "/random"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.httpRequests.WithLabelValues("/codes/{id}", http.MethodGet, "204")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.httpRequests.WithLabelValues(unmatchedRoute, http.MethodGet, "404")))
+}
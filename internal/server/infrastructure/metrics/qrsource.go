@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/instagram/photo"
+	"net/http"
+)
+
+// Stages of QR search in social post
+const (
+	stagePost  = "post"
+	stageImage = "image"
+)
+
+// Results of QR search
+const (
+	resultSuccess  = "success"
+	resultFailure  = "failure"
+	resultCanceled = "canceled"
+)
+
+// PhotoSourcer counts failed requests of posts to social platform
+type PhotoSourcer struct {
+	platform string
+	next     domain.PhotoSourcer
+	metrics  *Metrics
+}
+
+var _ domain.PhotoSourcer = PhotoSourcer{}
+
+// NewPhotoSourcer wraps photo source of platform
+func (m *Metrics) NewPhotoSourcer(platform string, next domain.PhotoSourcer) PhotoSourcer {
+	return PhotoSourcer{platform: platform, next: next, metrics: m}
+}
+
+// GetPhotos ...
+func (p PhotoSourcer) GetPhotos(ctx context.Context, link string) ([]string, error) {
+	links, err := p.next.GetPhotos(ctx, link)
+	// wrong links are mistakes of users and canceled requests are not answered by platform
+	if err != nil && !errors.Is(err, photo.ErrWrongLink) && ctx.Err() == nil {
+		p.metrics.upstreamErrors.WithLabelValues(p.platform, stagePost).Inc()
+	}
+	return links, err
+}
+
+// Decoder counts successful and failed decoding of images
+type Decoder struct {
+	next    domain.QRDecoder
+	metrics *Metrics
+}
+
+var _ domain.QRDecoder = Decoder{}
+
+// NewDecoder wraps QR decoder
+func (m *Metrics) NewDecoder(next domain.QRDecoder) Decoder {
+	return Decoder{next: next, metrics: m}
+}
+
+// Decode ...
+func (d Decoder) Decode(ctx context.Context, b []byte) ([]byte, error) {
+	res, err := d.next.Decode(ctx, b)
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	d.metrics.decodes.WithLabelValues(result).Inc()
+	return res, err
+}
+
+// Transport counts images downloaded from platform. It wraps transport of HTTP client which downloads images
+type Transport struct {
+	platform string
+	next     http.RoundTripper
+	metrics  *Metrics
+}
+
+var _ http.RoundTripper = Transport{}
+
+// NewTransport wraps transport. Nil next is http.DefaultTransport
+func (m *Metrics) NewTransport(platform string, next http.RoundTripper) Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return Transport{platform: platform, next: next, metrics: m}
+}
+
+// RoundTrip ...
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case req.Context().Err() != nil:
+		// other image of post contained QR already
+		t.metrics.imagesFetched.WithLabelValues(t.platform, resultCanceled).Inc()
+	case err != nil || resp.StatusCode != http.StatusOK:
+		t.metrics.imagesFetched.WithLabelValues(t.platform, resultError).Inc()
+		t.metrics.upstreamErrors.WithLabelValues(t.platform, stageImage).Inc()
+	default:
+		t.metrics.imagesFetched.WithLabelValues(t.platform, resultOK).Inc()
+	}
+	return resp, err
+}
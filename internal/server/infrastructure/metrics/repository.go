@@ -0,0 +1,276 @@
+package metrics
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/domain/entities"
+	"time"
+)
+
+// UserRepository records query latencies of wrapped repository
+type UserRepository struct {
+	next    domain.UserRepository
+	metrics *Metrics
+}
+
+var _ domain.UserRepository = UserRepository{}
+
+// NewUserRepository wraps repository
+func (m *Metrics) NewUserRepository(next domain.UserRepository) UserRepository {
+	return UserRepository{next: next, metrics: m}
+}
+
+// Get ...
+func (u UserRepository) Get(ctx context.Context, id uint64) (res entities.User, err error) {
+	defer u.metrics.observeQuery("user", "Get", time.Now(), &err)
+	return u.next.Get(ctx, id)
+}
+
+// Create ...
+func (u UserRepository) Create(ctx context.Context, user entities.User) (res uint64, err error) {
+	defer u.metrics.observeQuery("user", "Create", time.Now(), &err)
+	return u.next.Create(ctx, user)
+}
+
+// GetByUsernameAndPass ...
+func (u UserRepository) GetByUsernameAndPass(ctx context.Context, user entities.User) (res uint64, err error) {
+	defer u.metrics.observeQuery("user", "GetByUsernameAndPass", time.Now(), &err)
+	return u.next.GetByUsernameAndPass(ctx, user)
+}
+
+// GetByDomain ...
+func (u UserRepository) GetByDomain(ctx context.Context, domainName string) (res entities.User, err error) {
+	defer u.metrics.observeQuery("user", "GetByDomain", time.Now(), &err)
+	return u.next.GetByDomain(ctx, domainName)
+}
+
+// SetDomain ...
+func (u UserRepository) SetDomain(ctx context.Context, id uint64, domainName string) (err error) {
+	defer u.metrics.observeQuery("user", "SetDomain", time.Now(), &err)
+	return u.next.SetDomain(ctx, id, domainName)
+}
+
+// SetPendingDomain ...
+func (u UserRepository) SetPendingDomain(ctx context.Context, id uint64, domainName string, domainToken string) (err error) {
+	defer u.metrics.observeQuery("user", "SetPendingDomain", time.Now(), &err)
+	return u.next.SetPendingDomain(ctx, id, domainName, domainToken)
+}
+
+// SetDefaultStyle ...
+func (u UserRepository) SetDefaultStyle(ctx context.Context, id uint64, styleID uint64) (err error) {
+	defer u.metrics.observeQuery("user", "SetDefaultStyle", time.Now(), &err)
+	return u.next.SetDefaultStyle(ctx, id, styleID)
+}
+
+// CodeRepository records query latencies of wrapped repository
+type CodeRepository struct {
+	next    domain.CodeRepository
+	metrics *Metrics
+}
+
+var _ domain.CodeRepository = CodeRepository{}
+
+// NewCodeRepository wraps repository
+func (m *Metrics) NewCodeRepository(next domain.CodeRepository) CodeRepository {
+	return CodeRepository{next: next, metrics: m}
+}
+
+// List ...
+func (c CodeRepository) List(ctx context.Context, userID uint64, offset int64, limit int64) (res []entities.Code, err error) {
+	defer c.metrics.observeQuery("code", "List", time.Now(), &err)
+	return c.next.List(ctx, userID, offset, limit)
+}
+
+// ListAll ...
+func (c CodeRepository) ListAll(ctx context.Context, userID uint64) (res []entities.Code, err error) {
+	defer c.metrics.observeQuery("code", "ListAll", time.Now(), &err)
+	return c.next.ListAll(ctx, userID)
+}
+
+// ListByFilter ...
+func (c CodeRepository) ListByFilter(ctx context.Context, userID uint64, filter domain.CodeFilter) (res []entities.Code, err error) {
+	defer c.metrics.observeQuery("code", "ListByFilter", time.Now(), &err)
+	return c.next.ListByFilter(ctx, userID, filter)
+}
+
+// Get ...
+func (c CodeRepository) Get(ctx context.Context, id uint64) (res entities.Code, err error) {
+	defer c.metrics.observeQuery("code", "Get", time.Now(), &err)
+	return c.next.Get(ctx, id)
+}
+
+// GetByHash ...
+func (c CodeRepository) GetByHash(ctx context.Context, hash string) (res entities.Code, err error) {
+	defer c.metrics.observeQuery("code", "GetByHash", time.Now(), &err)
+	return c.next.GetByHash(ctx, hash)
+}
+
+// GetBySlug ...
+func (c CodeRepository) GetBySlug(ctx context.Context, slug string) (res entities.Code, err error) {
+	defer c.metrics.observeQuery("code", "GetBySlug", time.Now(), &err)
+	return c.next.GetBySlug(ctx, slug)
+}
+
+// Create ...
+func (c CodeRepository) Create(ctx context.Context, code entities.Code) (res uint64, err error) {
+	defer c.metrics.observeQuery("code", "Create", time.Now(), &err)
+	return c.next.Create(ctx, code)
+}
+
+// CreateBatch ...
+func (c CodeRepository) CreateBatch(ctx context.Context, codes []entities.Code, hasher func(uint64) (string, error)) (res []uint64, err error) {
+	defer c.metrics.observeQuery("code", "CreateBatch", time.Now(), &err)
+	return c.next.CreateBatch(ctx, codes, hasher)
+}
+
+// Update ...
+func (c CodeRepository) Update(ctx context.Context, code entities.Code) (err error) {
+	defer c.metrics.observeQuery("code", "Update", time.Now(), &err)
+	return c.next.Update(ctx, code)
+}
+
+// Delete ...
+func (c CodeRepository) Delete(ctx context.Context, id uint64) (err error) {
+	defer c.metrics.observeQuery("code", "Delete", time.Now(), &err)
+	return c.next.Delete(ctx, id)
+}
+
+// Trash ...
+func (c CodeRepository) Trash(ctx context.Context, id uint64, deletedAt time.Time) (err error) {
+	defer c.metrics.observeQuery("code", "Trash", time.Now(), &err)
+	return c.next.Trash(ctx, id, deletedAt)
+}
+
+// Restore ...
+func (c CodeRepository) Restore(ctx context.Context, id uint64) (err error) {
+	defer c.metrics.observeQuery("code", "Restore", time.Now(), &err)
+	return c.next.Restore(ctx, id)
+}
+
+// Purge ...
+func (c CodeRepository) Purge(ctx context.Context, before time.Time) (res []entities.Code, err error) {
+	defer c.metrics.observeQuery("code", "Purge", time.Now(), &err)
+	return c.next.Purge(ctx, before)
+}
+
+// AddScans ...
+func (c CodeRepository) AddScans(ctx context.Context, scans map[uint64]uint64) (err error) {
+	defer c.metrics.observeQuery("code", "AddScans", time.Now(), &err)
+	return c.next.AddScans(ctx, scans)
+}
+
+// SetCampaign ...
+func (c CodeRepository) SetCampaign(ctx context.Context, campaignID uint64, codeIDs []uint64) (err error) {
+	defer c.metrics.observeQuery("code", "SetCampaign", time.Now(), &err)
+	return c.next.SetCampaign(ctx, campaignID, codeIDs)
+}
+
+// CampaignRepository records query latencies of wrapped repository
+type CampaignRepository struct {
+	next    domain.CampaignRepository
+	metrics *Metrics
+}
+
+var _ domain.CampaignRepository = CampaignRepository{}
+
+// NewCampaignRepository wraps repository
+func (m *Metrics) NewCampaignRepository(next domain.CampaignRepository) CampaignRepository {
+	return CampaignRepository{next: next, metrics: m}
+}
+
+// List ...
+func (c CampaignRepository) List(ctx context.Context, userID uint64) (res []entities.Campaign, err error) {
+	defer c.metrics.observeQuery("campaign", "List", time.Now(), &err)
+	return c.next.List(ctx, userID)
+}
+
+// Get ...
+func (c CampaignRepository) Get(ctx context.Context, id uint64) (res entities.Campaign, err error) {
+	defer c.metrics.observeQuery("campaign", "Get", time.Now(), &err)
+	return c.next.Get(ctx, id)
+}
+
+// Create ...
+func (c CampaignRepository) Create(ctx context.Context, campaign entities.Campaign) (res uint64, err error) {
+	defer c.metrics.observeQuery("campaign", "Create", time.Now(), &err)
+	return c.next.Create(ctx, campaign)
+}
+
+// Update ...
+func (c CampaignRepository) Update(ctx context.Context, campaign entities.Campaign) (err error) {
+	defer c.metrics.observeQuery("campaign", "Update", time.Now(), &err)
+	return c.next.Update(ctx, campaign)
+}
+
+// Delete ...
+func (c CampaignRepository) Delete(ctx context.Context, id uint64) (err error) {
+	defer c.metrics.observeQuery("campaign", "Delete", time.Now(), &err)
+	return c.next.Delete(ctx, id)
+}
+
+// StyleRepository records query latencies of wrapped repository
+type StyleRepository struct {
+	next    domain.StyleRepository
+	metrics *Metrics
+}
+
+var _ domain.StyleRepository = StyleRepository{}
+
+// NewStyleRepository wraps repository
+func (m *Metrics) NewStyleRepository(next domain.StyleRepository) StyleRepository {
+	return StyleRepository{next: next, metrics: m}
+}
+
+// ListStyles ...
+func (s StyleRepository) ListStyles(ctx context.Context, userID uint64) (res []entities.Style, err error) {
+	defer s.metrics.observeQuery("style", "ListStyles", time.Now(), &err)
+	return s.next.ListStyles(ctx, userID)
+}
+
+// GetStyle ...
+func (s StyleRepository) GetStyle(ctx context.Context, id uint64) (res entities.Style, err error) {
+	defer s.metrics.observeQuery("style", "GetStyle", time.Now(), &err)
+	return s.next.GetStyle(ctx, id)
+}
+
+// CreateStyle ...
+func (s StyleRepository) CreateStyle(ctx context.Context, style entities.Style) (res uint64, err error) {
+	defer s.metrics.observeQuery("style", "CreateStyle", time.Now(), &err)
+	return s.next.CreateStyle(ctx, style)
+}
+
+// UpdateStyle ...
+func (s StyleRepository) UpdateStyle(ctx context.Context, style entities.Style) (err error) {
+	defer s.metrics.observeQuery("style", "UpdateStyle", time.Now(), &err)
+	return s.next.UpdateStyle(ctx, style)
+}
+
+// DeleteStyle ...
+func (s StyleRepository) DeleteStyle(ctx context.Context, id uint64) (err error) {
+	defer s.metrics.observeQuery("style", "DeleteStyle", time.Now(), &err)
+	return s.next.DeleteStyle(ctx, id)
+}
+
+// ListLogos ...
+func (s StyleRepository) ListLogos(ctx context.Context, userID uint64) (res []entities.Logo, err error) {
+	defer s.metrics.observeQuery("style", "ListLogos", time.Now(), &err)
+	return s.next.ListLogos(ctx, userID)
+}
+
+// GetLogo ...
+func (s StyleRepository) GetLogo(ctx context.Context, id uint64) (res entities.Logo, err error) {
+	defer s.metrics.observeQuery("style", "GetLogo", time.Now(), &err)
+	return s.next.GetLogo(ctx, id)
+}
+
+// CreateLogo ...
+func (s StyleRepository) CreateLogo(ctx context.Context, logo entities.Logo) (res uint64, err error) {
+	defer s.metrics.observeQuery("style", "CreateLogo", time.Now(), &err)
+	return s.next.CreateLogo(ctx, logo)
+}
+
+// DeleteLogo ...
+func (s StyleRepository) DeleteLogo(ctx context.Context, id uint64) (err error) {
+	defer s.metrics.observeQuery("style", "DeleteLogo", time.Now(), &err)
+	return s.next.DeleteLogo(ctx, id)
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies resolves client IP of requests which come through own proxies
+type TrustedProxies struct {
+	nets []*net.IPNet
+}
+
+// ParseTrustedProxies parses IPs and CIDRs of proxies
+func ParseTrustedProxies(specs []string) (TrustedProxies, error) {
+	var p TrustedProxies
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if !strings.Contains(spec, "/") {
+			ip := net.ParseIP(spec)
+			if ip == nil {
+				return TrustedProxies{}, errors.Errorf("invalid proxy IP %q", spec)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			p.nets = append(p.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(spec)
+		if err != nil {
+			return TrustedProxies{}, errors.Errorf("invalid proxy CIDR %q", spec)
+		}
+		p.nets = append(p.nets, n)
+	}
+	return p, nil
+}
+
+// ClientIP returns IP of client. X-Forwarded-For is honored only if request comes from trusted proxy:
+// it is read from right to left and first address which is not trusted proxy is client.
+// Addresses left of it could be forged by client, so they are ignored.
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	ip := remoteIP(r.RemoteAddr)
+	if !p.trusted(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !p.trusted(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// FromProxy reports if request comes directly from trusted proxy, so its headers could be trusted
+func (p TrustedProxies) FromProxy(r *http.Request) bool {
+	return p.trusted(remoteIP(r.RemoteAddr))
+}
+
+func (p TrustedProxies) trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range p.nets {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP strips port of remote address
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
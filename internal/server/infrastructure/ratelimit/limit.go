@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"github.com/pkg/errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is token bucket of Requests tokens refilled evenly during Per.
+// Full bucket allows burst of Requests. Zero Limit doesn't limit.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses limit as "10/m", "100/h" or "5/30s". "0" and "off" disable limit
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" || s == "off" {
+		return Limit{}, nil
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, errors.Errorf("invalid limit %q, expected requests/period", s)
+	}
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n <= 0 {
+		return Limit{}, errors.Errorf("invalid requests of limit %q", s)
+	}
+	var per time.Duration
+	switch parts[1] {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		per, err = time.ParseDuration(parts[1])
+		if err != nil || per <= 0 {
+			return Limit{}, errors.Errorf("invalid period of limit %q", s)
+		}
+	}
+	return Limit{Requests: n, Per: per}, nil
+}
+
+// IsZero reports whether limit is disabled
+func (l Limit) IsZero() bool {
+	return l.Requests <= 0 || l.Per <= 0
+}
+
+// String ...
+func (l Limit) String() string {
+	if l.IsZero() {
+		return "off"
+	}
+	switch l.Per {
+	case time.Second:
+		return strconv.Itoa(l.Requests) + "/s"
+	case time.Minute:
+		return strconv.Itoa(l.Requests) + "/m"
+	case time.Hour:
+		return strconv.Itoa(l.Requests) + "/h"
+	}
+	return strconv.Itoa(l.Requests) + "/" + l.Per.String()
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It is used by configuration
+func (l *Limit) UnmarshalText(b []byte) error {
+	v, err := ParseLimit(string(b))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+// interval is time to refill one token
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// bucket is state of token bucket at time of last request
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills bucket since last request and takes token if there is one
+func (b *bucket) take(l Limit, now time.Time) Result {
+	capacity := float64(l.Requests)
+	if b.updated.IsZero() {
+		b.tokens = capacity
+	} else if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+float64(elapsed)/float64(l.interval()))
+	}
+	b.updated = now
+
+	res := Result{Limit: l}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) * float64(l.interval()))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((capacity - b.tokens) * float64(l.interval()))
+	return res
+}
+
+// full reports whether bucket is refilled at now, so its state could be dropped
+func (b *bucket) full(l Limit, now time.Time) bool {
+	return now.Sub(b.updated) >= time.Duration((float64(l.Requests)-b.tokens)*float64(l.interval()))
+}
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Limiter limits requests of one route group by keys of clients
+type Limiter struct {
+	name  string
+	limit Limit
+	store Store
+	now   func() time.Time
+}
+
+// NewLimiter creates Limiter. Name separates buckets of limiters in shared store.
+// Nil is returned for zero limit, nil Limiter allows everything.
+func NewLimiter(name string, limit Limit, store Store) *Limiter {
+	if limit.IsZero() {
+		return nil
+	}
+	return &Limiter{name: name, limit: limit, store: store, now: time.Now}
+}
+
+// Allow takes token of key
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	if l == nil {
+		return Result{Allowed: true}, nil
+	}
+	return l.store.Take(ctx, l.name+"_"+key, l.limit, l.now())
+}
+
+// WriteHeaders sets RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+// Retry-After is set for denied request. Values are seconds rounded up.
+func WriteHeaders(w http.ResponseWriter, res Result) {
+	if res.Limit.IsZero() {
+		return
+	}
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit.Requests)+";w="+seconds(res.Limit.Per))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", seconds(res.Reset))
+	if !res.Allowed {
+		h.Set("Retry-After", seconds(res.RetryAfter))
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache/inmemory"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Limit
+		wantErr bool
+	}{
+		{s: "10/m", want: Limit{Requests: 10, Per: time.Minute}},
+		{s: "100/h", want: Limit{Requests: 100, Per: time.Hour}},
+		{s: "5/30s", want: Limit{Requests: 5, Per: 30 * time.Second}},
+		{s: "off", want: Limit{}},
+		{s: "0", want: Limit{}},
+		{s: "10", wantErr: true},
+		{s: "-1/m", wantErr: true},
+		{s: "10/week", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			l, err := ParseLimit(tt.s)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, l)
+			}
+		})
+	}
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"cache":  NewCacheStore(inmemory.NewCache()),
+	}
+	limit := Limit{Requests: 3, Per: 3 * time.Second}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Unix(1640000000, 0)
+			take := func(key string) Result {
+				res, err := store.Take(ctx, key, limit, now)
+				assert.NoError(t, err)
+				return res
+			}
+
+			for remaining := 2; remaining >= 0; remaining-- {
+				res := take("client")
+				assert.True(t, res.Allowed, "burst")
+				assert.Equal(t, remaining, res.Remaining)
+			}
+			res := take("client")
+			assert.False(t, res.Allowed)
+			assert.Equal(t, time.Second, res.RetryAfter)
+			assert.Equal(t, 3*time.Second, res.Reset)
+			assert.True(t, take("other").Allowed, "buckets are separated by key")
+
+			now = now.Add(time.Second)
+			assert.True(t, take("client").Allowed, "token is refilled")
+			assert.False(t, take("client").Allowed)
+
+			now = now.Add(time.Hour)
+			assert.Equal(t, 2, take("client").Remaining, "bucket is not refilled over capacity")
+		})
+	}
+}
+
+func TestMemoryStore_sweep(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Requests: 1, Per: time.Second}
+	now := time.Unix(1640000000, 0)
+	_, _ = s.Take(context.Background(), "gone", limit, now)
+	_, _ = s.Take(context.Background(), "active", limit, now.Add(time.Minute))
+	assert.Len(t, s.buckets, 1)
+}
+
+func TestLimiter(t *testing.T) {
+	assert.Nil(t, NewLimiter("off", Limit{}, NewMemoryStore()))
+	var off *Limiter
+	res, err := off.Allow(context.Background(), "client")
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	now := time.Unix(1640000000, 0)
+	l := NewLimiter("token", Limit{Requests: 1, Per: time.Minute}, NewMemoryStore())
+	l.now = func() time.Time { return now }
+	_, _ = l.Allow(context.Background(), "client")
+	res, err = l.Allow(context.Background(), "client")
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+
+	w := httptest.NewRecorder()
+	WriteHeaders(w, res)
+	assert.Equal(t, "1;w=60", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "forwarded by untrusted", remoteAddr: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "forwarded by proxy", remoteAddr: "10.1.2.3:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "chain of proxies", remoteAddr: "192.168.1.1:5000", forwarded: []string{"198.51.100.1, 10.0.0.5"}, want: "198.51.100.1"},
+		{name: "forged by client", remoteAddr: "10.1.2.3:5000", forwarded: []string{"1.1.1.1, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "multiple headers", remoteAddr: "10.1.2.3:5000", forwarded: []string{"1.1.1.1", "198.51.100.1"}, want: "198.51.100.1"},
+		{name: "garbage", remoteAddr: "10.1.2.3:5000", forwarded: []string{"unknown"}, want: "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, f := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", f)
+			}
+			assert.Equal(t, tt.want, proxies.ClientIP(r))
+		})
+	}
+
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestTrustedProxies_FromProxy(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.1.2.3:5000"
+	assert.True(t, proxies.FromProxy(r))
+	r.RemoteAddr = "203.0.113.7:5000"
+	r.Header.Set("X-Forwarded-For", "10.1.2.3")
+	assert.False(t, proxies.FromProxy(r))
+}
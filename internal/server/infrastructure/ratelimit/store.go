@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/hotafrika/griz-backend/internal/server/infrastructure/cache"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result is decision about request
+type Result struct {
+	Limit   Limit
+	Allowed bool
+	// Remaining is number of requests allowed right after this one
+	Remaining int
+	// RetryAfter is time until next token for denied request
+	RetryAfter time.Duration
+	// Reset is time until bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps token buckets by key
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// sweepInterval is interval between drops of refilled buckets of MemoryStore
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets of single instance in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	swept   time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	limit Limit
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take takes token from bucket of key. Refilled buckets are dropped once a minute, so memory follows active clients
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.swept) >= sweepInterval {
+		for k, b := range s.buckets {
+			if b.full(b.limit, now) {
+				delete(s.buckets, k)
+			}
+		}
+		s.swept = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{limit: limit}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	return b.take(limit, now), nil
+}
+
+// CacheStore keeps buckets in shared cache, so instances behind balancer share limits.
+// Cache has no atomic update, so concurrent requests of one key on different instances could pass together.
+type CacheStore struct {
+	// mu serializes updates of this instance
+	mu    sync.Mutex
+	cache domain.Cacher
+}
+
+var _ Store = (*CacheStore)(nil)
+
+// NewCacheStore creates CacheStore
+func NewCacheStore(cache domain.Cacher) *CacheStore {
+	return &CacheStore{cache: cache}
+}
+
+// Take takes token from bucket of key. Bucket is kept until it is refilled
+func (s *CacheStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cacheKey := cache.RateLimit{Key: key}
+	var b bucket
+	value, err := s.cache.Get(ctx, cacheKey)
+	switch {
+	case err == nil:
+		b, err = parseBucket(value)
+		if err != nil {
+			return Result{}, errors.Wrap(err, "Take: ")
+		}
+	case !errors.Is(err, domain.ErrCacheNotExist):
+		return Result{}, errors.Wrap(err, "Take: get cache: ")
+	}
+
+	res := b.take(limit, now)
+	err = s.cache.Set(ctx, cacheKey, formatBucket(b), res.Reset+time.Second)
+	if err != nil {
+		return Result{}, errors.Wrap(err, "Take: set cache: ")
+	}
+	return res, nil
+}
+
+// formatBucket returns bucket as "tokens updated", where updated is unix time in nanoseconds
+func formatBucket(b bucket) string {
+	return fmt.Sprintf("%g %d", b.tokens, b.updated.UnixNano())
+}
+
+func parseBucket(s string) (bucket, error) {
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return bucket{}, errors.Errorf("invalid bucket %q", s)
+	}
+	tokens, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return bucket{}, errors.Errorf("invalid tokens of bucket %q", s)
+	}
+	updated, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return bucket{}, errors.Errorf("invalid time of bucket %q", s)
+	}
+	return bucket{tokens: tokens, updated: time.Unix(0, updated)}, nil
+}
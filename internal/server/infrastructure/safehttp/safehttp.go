@@ -0,0 +1,197 @@
+package safehttp
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Errors of downloads. They are wrapped with details
+var (
+	ErrHostNotAllowed   = errors.New("host is not allowed")
+	ErrAddressForbidden = errors.New("address is forbidden")
+	ErrTooManyRedirects = errors.New("too many redirects")
+	ErrTooLarge         = errors.New("body is too large")
+	ErrNotImage         = errors.New("body is not image")
+)
+
+// imageTypes are sniffed content types of accepted images. They are types which decoder of QR codes registers,
+// other images would be downloaded only to fail decoding
+var imageTypes = map[string]struct{}{
+	"image/jpeg": {},
+	"image/png":  {},
+}
+
+// Config of Client
+type Config struct {
+	// AllowedHosts are host patterns. "*.example.com" matches subdomains of example.com, other patterns match exactly
+	AllowedHosts []string
+	// MaxRedirects is number of followed redirects, each of them has to be allowed too
+	MaxRedirects int
+	// MaxBytes is max size of body
+	MaxBytes int64
+	// DialTimeout limits connection to host
+	DialTimeout time.Duration
+}
+
+// Client downloads images from untrusted URLs, e.g. found in social posts.
+// Only https URLs of allowed hosts are fetched. Resolved IPs are checked at dial time,
+// so hosts resolving to private networks are refused even after DNS rebinding.
+type Client struct {
+	cfg       Config
+	transport http.RoundTripper
+	// allowIP is replaced in tests to reach local servers
+	allowIP func(ip net.IP) bool
+}
+
+// NewClient creates Client. Proxies of environment are not used: proxy would dial instead of Client
+func NewClient(cfg Config) *Client {
+	c := &Client{cfg: cfg, allowIP: publicIP}
+	dialer := &net.Dialer{
+		Timeout: cfg.DialTimeout,
+		Control: c.control,
+	}
+	c.transport = &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	}
+	return c
+}
+
+// WrapTransport wraps transport of Client, e.g. to count downloads
+func (c *Client) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.transport = wrap(c.transport)
+}
+
+// FetchImage downloads image. Body is refused if it is larger than MaxBytes
+// or its sniffed content type isn't image.
+func (c *Client) FetchImage(ctx context.Context, link string) ([]byte, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchImage: parse link: ")
+	}
+	err = c.checkURL(u)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchImage: ")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchImage: create request: ")
+	}
+	req.Header.Set("Accept", "image/*")
+
+	client := &http.Client{Transport: c.transport, CheckRedirect: c.checkRedirect}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchImage: HTTP Get link: ")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("FetchImage: unable to download image, status %d", res.StatusCode)
+	}
+	if res.ContentLength > c.cfg.MaxBytes {
+		return nil, errors.Wrapf(ErrTooLarge, "FetchImage: content length %d", res.ContentLength)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, c.cfg.MaxBytes+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchImage: read body: ")
+	}
+	if int64(len(b)) > c.cfg.MaxBytes {
+		return nil, errors.Wrapf(ErrTooLarge, "FetchImage: more than %d bytes", c.cfg.MaxBytes)
+	}
+	// declared type is not trusted, body is sniffed
+	if sniffed := http.DetectContentType(b); !isImage(sniffed) {
+		return nil, errors.Wrapf(ErrNotImage, "FetchImage: sniffed %s", sniffed)
+	}
+	return b, nil
+}
+
+// checkRedirect limits redirects and checks their targets
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > c.cfg.MaxRedirects {
+		return ErrTooManyRedirects
+	}
+	return c.checkURL(req.URL)
+}
+
+// checkURL allows https URLs of allowed hosts
+func (c *Client) checkURL(u *url.URL) error {
+	if u.Scheme != "https" {
+		return errors.Wrapf(ErrHostNotAllowed, "scheme %q", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range c.cfg.AllowedHosts {
+		if matchHost(strings.ToLower(pattern), host) {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrHostNotAllowed, "host %q", host)
+}
+
+// control checks resolved address right before connection
+func (c *Client) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrap(err, "split address: ")
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !c.allowIP(ip) {
+		return errors.Wrapf(ErrAddressForbidden, "%s", host)
+	}
+	return nil
+}
+
+// matchHost matches host to pattern. "*.example.com" doesn't match example.com itself
+func matchHost(pattern, host string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
+
+// blockedNets are private networks, shared address space of carriers and "this network"
+var blockedNets = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "0.0.0.0/8", "fc00::/7")
+
+// publicIP refuses loopback, private, link-local, multicast and unspecified addresses
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func isImage(contentType string) bool {
+	_, ok := imageTypes[contentType]
+	return ok
+}
@@ -0,0 +1,145 @@
+package safehttp
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func pngImage(t *testing.T) []byte {
+	var b bytes.Buffer
+	err := png.Encode(&b, image.NewGray(image.Rect(0, 0, 10, 10)))
+	assert.NoError(t, err)
+	return b.Bytes()
+}
+
+// newTestClient returns client of local TLS server. Loopback is allowed for it
+func newTestClient(srv *httptest.Server, cfg Config) *Client {
+	c := NewClient(cfg)
+	c.allowIP = func(net.IP) bool { return true }
+	c.transport.(*http.Transport).TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+	return c
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{"*.cdninstagram.com", "scontent.cdninstagram.com", true},
+		{"*.cdninstagram.com", "a.b.cdninstagram.com", true},
+		{"*.cdninstagram.com", "cdninstagram.com", false},
+		{"*.cdninstagram.com", "evilcdninstagram.com", false},
+		{"*.cdninstagram.com", "cdninstagram.com.evil.com", false},
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchHost(tt.pattern, tt.host), "%s %s", tt.pattern, tt.host)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	blocked := []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fd00::1", "fe80::1", "224.0.0.1"}
+	for _, ip := range blocked {
+		assert.False(t, publicIP(net.ParseIP(ip)), ip)
+	}
+	allowed := []string{"157.240.1.63", "2a03:2880:f003:c07:face:b00c::2"}
+	for _, ip := range allowed {
+		assert.True(t, publicIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestClient_FetchImage_URL(t *testing.T) {
+	c := NewClient(Config{AllowedHosts: []string{"*.cdninstagram.com"}, MaxBytes: 1 << 20})
+	links := []string{
+		"http://scontent.cdninstagram.com/a.jpg",
+		"file:///etc/passwd",
+		"https://example.com/a.jpg",
+		"https://169.254.169.254/latest/meta-data",
+	}
+	for _, link := range links {
+		_, err := c.FetchImage(context.Background(), link)
+		assert.True(t, errors.Is(err, ErrHostNotAllowed), "%s: %v", link, err)
+	}
+}
+
+func TestClient_FetchImage_Loopback(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(pngImage(t))
+	}))
+	defer srv.Close()
+
+	// allowed host resolving to loopback is refused at dial time
+	c := NewClient(Config{AllowedHosts: []string{"127.0.0.1"}, MaxBytes: 1 << 20, DialTimeout: time.Second})
+	_, err := c.FetchImage(context.Background(), srv.URL)
+	assert.True(t, errors.Is(err, ErrAddressForbidden), "%v", err)
+}
+
+func TestClient_FetchImage(t *testing.T) {
+	img := pngImage(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(img)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("<html><body>not image</body></html>"))
+	})
+	mux.HandleFunc("/image.gif", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"))
+	})
+	mux.HandleFunc("/large.png", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(append(img, make([]byte, 2<<20)...))
+	})
+	mux.HandleFunc("/missing.png", http.NotFound)
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/image.png", http.StatusFound)
+	})
+	mux.HandleFunc("/redirect2", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/redirect", http.StatusFound)
+	})
+	mux.HandleFunc("/metadata", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	c := newTestClient(srv, Config{AllowedHosts: []string{"127.0.0.1"}, MaxRedirects: 1, MaxBytes: 1 << 20})
+	ctx := context.Background()
+
+	b, err := c.FetchImage(ctx, srv.URL+"/image.png")
+	assert.NoError(t, err)
+	assert.Equal(t, img, b)
+
+	b, err = c.FetchImage(ctx, srv.URL+"/redirect")
+	assert.NoError(t, err)
+	assert.Equal(t, img, b)
+
+	_, err = c.FetchImage(ctx, srv.URL+"/redirect2")
+	assert.True(t, errors.Is(err, ErrTooManyRedirects), "%v", err)
+
+	_, err = c.FetchImage(ctx, srv.URL+"/metadata")
+	assert.True(t, errors.Is(err, ErrHostNotAllowed), "%v", err)
+
+	_, err = c.FetchImage(ctx, srv.URL+"/page.html")
+	assert.True(t, errors.Is(err, ErrNotImage), "%v", err)
+
+	_, err = c.FetchImage(ctx, srv.URL+"/image.gif")
+	assert.True(t, errors.Is(err, ErrNotImage), "image which isn't decoded is rejected: %v", err)
+
+	_, err = c.FetchImage(ctx, srv.URL+"/large.png")
+	assert.True(t, errors.Is(err, ErrTooLarge), "%v", err)
+
+	_, err = c.FetchImage(ctx, srv.URL+"/missing.png")
+	assert.Error(t, err)
+}
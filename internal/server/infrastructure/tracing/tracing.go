@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+)
+
+// instrumentationName is name of tracer of service code
+const instrumentationName = "github.com/hotafrika/griz-backend"
+
+// Config of span export
+type Config struct {
+	// Endpoint is URL of OTLP/HTTP collector. Tracing is no-op if it is empty
+	Endpoint string
+	// SampleRatio is fraction of sampled root spans
+	SampleRatio    float64
+	ServiceName    string
+	ServiceVersion string
+}
+
+// Setup installs global tracer provider and W3C trace context propagator.
+// Without endpoint global no-op provider is kept, so spans cost nothing.
+// Returned shutdown flushes pending spans.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "Setup: parse endpoint: ")
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Setup: create exporter: ")
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(cfg.ServiceName),
+		semconv.ServiceVersionKey.String(cfg.ServiceVersion),
+	))
+	if err != nil {
+		return nil, errors.Wrap(err, "Setup: create resource: ")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts span of service code. Global provider is resolved on every call,
+// so spans of components created before Setup are exported too.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End records error of operation and ends span. It is deferred with pointer to named error.
+// Missing entities are expected results, so they don't mark span as failed.
+func End(span trace.Span, err *error) {
+	if *err != nil && !isNotFound(*err) {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// notFoundErrors are expected results of queries, not failures
+var notFoundErrors = []error{
+	domain.ErrUserNotFound,
+	domain.ErrCodeNotFound,
+	domain.ErrCampaignNotFound,
+	domain.ErrStyleNotFound,
+	domain.ErrLogoNotFound,
+	domain.ErrCacheNotExist,
+}
+
+func isNotFound(err error) bool {
+	for _, notFound := range notFoundErrors {
+		if errors.Is(err, notFound) {
+			return true
+		}
+	}
+	return false
+}
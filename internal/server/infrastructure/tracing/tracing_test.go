@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/hotafrika/griz-backend/internal/server/domain"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// record installs provider which keeps ended spans
+func record(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return recorder
+}
+
+func TestEnd(t *testing.T) {
+	recorder := record(t)
+	operation := func(err error) {
+		_, span := Start(context.Background(), "operation")
+		defer End(span, &err)
+	}
+	operation(nil)
+	operation(errors.Wrap(domain.ErrCodeNotFound, "Get: "))
+	operation(errors.New("disk I/O error"))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 3) {
+		assert.Equal(t, codes.Unset, spans[0].Status().Code)
+		assert.Equal(t, codes.Unset, spans[1].Status().Code, "missing entity is not failure")
+		assert.Equal(t, codes.Error, spans[2].Status().Code)
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	recorder := record(t)
+	_, err := Setup(context.Background(), Config{})
+	assert.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(HTTPMiddleware(func(r *http.Request) bool {
+		return r.RemoteAddr == "10.0.0.1:1234"
+	}))
+	r.Get("/codes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "handler")
+		span.End()
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/codes/1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		handler, server := spans[0], spans[1]
+		assert.Equal(t, "GET /codes/{id}", server.Name())
+		assert.Equal(t, codes.Error, server.Status().Code)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String(), "trace of caller is continued")
+		assert.Equal(t, server.SpanContext().SpanID(), handler.Parent().SpanID())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/codes/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans = recorder.Ended()
+	if assert.Len(t, spans, 4) {
+		server := spans[3]
+		assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String(), "traceparent of client is ignored")
+		assert.False(t, server.Parent().IsValid(), "span of client request is root")
+	}
+}